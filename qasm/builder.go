@@ -0,0 +1,312 @@
+package qasm
+
+import (
+	"qgo/sim"
+)
+
+// Bits of a declared register, as a range of circuit qubits or classical bits
+type register struct {
+	quantum bool
+	offset  int
+	size    int
+}
+
+// Turns parsed statements into gates of a circuit
+type builder struct {
+	qc        *sim.QuantumCircuit
+	registers map[string]register
+	gates     map[string]*gateDecl
+}
+
+// Builds the circuit of a program. Registers are laid out in declaration order.
+func build(stmts []statement) (sim.QuantumCircuit, error) {
+	numQubits := 0
+	for _, s := range stmts {
+		if r, ok := s.(*regDecl); ok && r.quantum {
+			numQubits += r.size
+		}
+	}
+
+	qc := sim.NewQuantumCircuit(numQubits)
+	b := &builder{
+		qc:        &qc,
+		registers: map[string]register{},
+		gates:     map[string]*gateDecl{},
+	}
+	nextQubit := 0
+	for _, s := range stmts {
+		if r, ok := s.(*regDecl); ok {
+			if _, exists := b.registers[r.name]; exists {
+				return sim.QuantumCircuit{}, r.pos.errorf("register %v is already declared", r.name)
+			}
+			reg := register{quantum: r.quantum, size: r.size}
+			if r.quantum {
+				reg.offset = nextQubit
+				nextQubit += r.size
+			} else {
+				reg.offset = qc.AddClbits(r.size)
+			}
+			b.registers[r.name] = reg
+			continue
+		}
+		if err := b.statement(b.qc, s); err != nil {
+			return sim.QuantumCircuit{}, err
+		}
+	}
+	return qc, nil
+}
+
+func (b *builder) statement(qc *sim.QuantumCircuit, s statement) error {
+	switch s := s.(type) {
+	case *gateDecl:
+		return b.declare(s)
+	case *gateCall:
+		return b.call(qc, s)
+	case *measure:
+		qubits, err := b.bits(s.qubit, true)
+		if err != nil {
+			return err
+		}
+		clbits, err := b.bits(s.clbit, false)
+		if err != nil {
+			return err
+		}
+		if len(qubits) != len(clbits) {
+			return s.pos.errorf("cannot measure %v qubits into %v classical bits", len(qubits), len(clbits))
+		}
+		for i := range qubits {
+			qc.Measure(qubits[i], clbits[i])
+		}
+	case *reset:
+		qubits, err := b.bits(s.qubit, true)
+		if err != nil {
+			return err
+		}
+		for _, q := range qubits {
+			qc.Reset(q)
+		}
+	case *barrier:
+		// Barriers only constrain optimization, which the simulator does not do
+		for _, a := range s.args {
+			if _, err := b.bits(a, true); err != nil {
+				return err
+			}
+		}
+	case *ifStmt:
+		reg, ok := b.registers[s.creg]
+		if !ok || reg.quantum {
+			return s.pos.errorf("%v is not a classical register", s.creg)
+		}
+		clbits := make([]int, reg.size)
+		for i := range clbits {
+			clbits[i] = reg.offset + i
+		}
+		return b.statement(qc.IfRegister(clbits, s.value), s.body)
+	}
+	return nil
+}
+
+// Records a gate definition after checking its body against the gates declared so far
+func (b *builder) declare(d *gateDecl) error {
+	if d.name == "U" || d.name == "CX" {
+		return d.pos.errorf("gate %v is built in and cannot be redefined", d.name)
+	}
+	if _, exists := b.gates[d.name]; exists {
+		return d.pos.errorf("gate %v is already defined", d.name)
+	}
+
+	params := map[string]float64{}
+	for _, p := range d.params {
+		if _, dup := params[p]; dup {
+			return d.pos.errorf("parameter %v of gate %v is declared twice", p, d.name)
+		}
+		params[p] = 0
+	}
+	qargs := map[string]bool{}
+	for _, q := range d.qargs {
+		if qargs[q] {
+			return d.pos.errorf("argument %v of gate %v is declared twice", q, d.name)
+		}
+		qargs[q] = true
+	}
+
+	for _, call := range d.body {
+		for _, a := range call.args {
+			if a.index != -1 {
+				return a.pos.errorf("gate arguments cannot be indexed")
+			}
+			if !qargs[a.name] {
+				return a.pos.errorf("%v is not an argument of gate %v", a.name, d.name)
+			}
+		}
+		for _, e := range call.params {
+			if _, err := e.eval(params); err != nil {
+				return err
+			}
+		}
+		if err := b.checkCall(call, len(call.args)); err != nil {
+			return err
+		}
+	}
+
+	b.gates[d.name] = d
+	return nil
+}
+
+// Checks that the called gate exists and takes the given number of parameters and qubits
+func (b *builder) checkCall(call *gateCall, numQubits int) error {
+	wantParams, wantQubits := 0, 0
+	switch call.name {
+	case "U":
+		wantParams, wantQubits = 3, 1
+	case "CX":
+		wantQubits = 2
+	default:
+		d, ok := b.gates[call.name]
+		if !ok {
+			return call.pos.errorf("undefined gate %v", call.name)
+		}
+		wantParams, wantQubits = len(d.params), len(d.qargs)
+	}
+	if len(call.params) != wantParams {
+		return call.pos.errorf("gate %v takes %v parameters, got %v", call.name, wantParams, len(call.params))
+	}
+	if numQubits != wantQubits {
+		return call.pos.errorf("gate %v takes %v qubits, got %v", call.name, wantQubits, numQubits)
+	}
+	return nil
+}
+
+// Applies a gate to registers or single qubits. Whole registers are broadcast:
+// the gate is applied once per index, pairing up the same index of every register.
+func (b *builder) call(qc *sim.QuantumCircuit, call *gateCall) error {
+	if err := b.checkCall(call, len(call.args)); err != nil {
+		return err
+	}
+	params := make([]float64, len(call.params))
+	for i, e := range call.params {
+		x, err := e.eval(nil)
+		if err != nil {
+			return err
+		}
+		params[i] = x
+	}
+
+	args := make([][]int, len(call.args))
+	width := 1
+	for i, a := range call.args {
+		qubits, err := b.bits(a, true)
+		if err != nil {
+			return err
+		}
+		if len(qubits) > 1 {
+			if width > 1 && len(qubits) != width {
+				return a.pos.errorf("register %v has %v qubits, other arguments have %v", a.name, len(qubits), width)
+			}
+			width = len(qubits)
+		}
+		args[i] = qubits
+	}
+
+	for k := 0; k < width; k++ {
+		qubits := make([]int, len(args))
+		for i, a := range args {
+			if len(a) == 1 {
+				qubits[i] = a[0]
+			} else {
+				qubits[i] = a[k]
+			}
+		}
+		for i := range qubits {
+			for j := 0; j < i; j++ {
+				if qubits[i] == qubits[j] {
+					return call.args[i].pos.errorf("gate %v is applied to qubit %v twice", call.name, qubits[i])
+				}
+			}
+		}
+		if err := b.apply(qc, call, params, qubits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adds one application of a gate, expanding its definition unless it maps to a sim gate
+func (b *builder) apply(qc *sim.QuantumCircuit, call *gateCall, params []float64, qubits []int) error {
+	switch call.name {
+	case "U":
+		qc.U(params[0], params[1], params[2], qubits[0])
+		return nil
+	case "CX":
+		qc.CX(qubits[0], qubits[1])
+		return nil
+	}
+
+	d := b.gates[call.name]
+	if d.opaque {
+		return call.pos.errorf("opaque gate %v has no definition to simulate", call.name)
+	}
+	if native, ok := nativeGates[d.name]; ok && d.standard {
+		native(qc, params, qubits)
+		return nil
+	}
+
+	env := map[string]float64{}
+	for i, p := range d.params {
+		env[p] = params[i]
+	}
+	bound := map[string]int{}
+	for i, q := range d.qargs {
+		bound[q] = qubits[i]
+	}
+	for _, inner := range d.body {
+		innerParams := make([]float64, len(inner.params))
+		for i, e := range inner.params {
+			x, err := e.eval(env)
+			if err != nil {
+				return err
+			}
+			innerParams[i] = x
+		}
+		innerQubits := make([]int, len(inner.args))
+		for i, a := range inner.args {
+			innerQubits[i] = bound[a.name]
+		}
+		for i := range innerQubits {
+			for j := 0; j < i; j++ {
+				if innerQubits[i] == innerQubits[j] {
+					return call.pos.errorf("gate %v applies %v to one qubit twice", call.name, inner.name)
+				}
+			}
+		}
+		if err := b.apply(qc, inner, innerParams, innerQubits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Qubits or classical bits referred to by an argument
+func (b *builder) bits(a argument, quantum bool) ([]int, error) {
+	reg, ok := b.registers[a.name]
+	if !ok {
+		return nil, a.pos.errorf("undeclared register %v", a.name)
+	}
+	if reg.quantum != quantum {
+		if quantum {
+			return nil, a.pos.errorf("%v is not a quantum register", a.name)
+		}
+		return nil, a.pos.errorf("%v is not a classical register", a.name)
+	}
+	if a.index >= reg.size {
+		return nil, a.pos.errorf("index %v is out of range for register %v of size %v", a.index, a.name, reg.size)
+	}
+	if a.index != -1 {
+		return []int{reg.offset + a.index}, nil
+	}
+	bits := make([]int, reg.size)
+	for i := range bits {
+		bits[i] = reg.offset + i
+	}
+	return bits, nil
+}
@@ -0,0 +1,142 @@
+package qasm
+
+import (
+	"fmt"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokReal
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  position
+}
+
+// Line and column of a token, counted from 1
+type position struct {
+	line, col int
+}
+
+func (p position) errorf(format string, args ...interface{}) *Error {
+	return &Error{Line: p.line, Col: p.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Splits OpenQASM source into tokens, dropping whitespace and // comments
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	line, col := 1, 1
+
+	advance := func(n int) {
+		for i := 0; i < n; i++ {
+			if runes[0] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		pos := position{line, col}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+
+		case r == '/' && len(runes) > 1 && runes[1] == '/':
+			for len(runes) > 0 && runes[0] != '\n' {
+				advance(1)
+			}
+
+		case r == '_' || unicode.IsLetter(r):
+			n := 1
+			for n < len(runes) && (runes[n] == '_' || unicode.IsLetter(runes[n]) || unicode.IsDigit(runes[n])) {
+				n++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[:n]), pos: pos})
+			advance(n)
+
+		case unicode.IsDigit(r) || r == '.' && len(runes) > 1 && unicode.IsDigit(runes[1]):
+			n, kind := scanNumber(runes)
+			tokens = append(tokens, token{kind: kind, text: string(runes[:n]), pos: pos})
+			advance(n)
+
+		case r == '"':
+			n := 1
+			for n < len(runes) && runes[n] != '"' && runes[n] != '\n' {
+				n++
+			}
+			if n == len(runes) || runes[n] != '"' {
+				return nil, pos.errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokString, text: string(runes[1:n]), pos: pos})
+			advance(n + 1)
+
+		case r == '-' && len(runes) > 1 && runes[1] == '>', r == '=' && len(runes) > 1 && runes[1] == '=':
+			tokens = append(tokens, token{kind: tokSymbol, text: string(runes[:2]), pos: pos})
+			advance(2)
+
+		case r < unicode.MaxASCII && containsRune(";,()[]{}+-*/^", r):
+			tokens = append(tokens, token{kind: tokSymbol, text: string(r), pos: pos})
+			advance(1)
+
+		default:
+			return nil, pos.errorf("unexpected character %q", r)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, pos: position{line, col}})
+	return tokens, nil
+}
+
+// Length of the number at the start of runes, and whether it is an integer or real
+func scanNumber(runes []rune) (int, tokenKind) {
+	n, kind := 0, tokInt
+	for n < len(runes) && unicode.IsDigit(runes[n]) {
+		n++
+	}
+	if n < len(runes) && runes[n] == '.' {
+		kind = tokReal
+		n++
+		for n < len(runes) && unicode.IsDigit(runes[n]) {
+			n++
+		}
+	}
+	if n < len(runes) && (runes[n] == 'e' || runes[n] == 'E') {
+		m := n + 1
+		if m < len(runes) && (runes[m] == '+' || runes[m] == '-') {
+			m++
+		}
+		if m < len(runes) && unicode.IsDigit(runes[m]) {
+			kind = tokReal
+			for m < len(runes) && unicode.IsDigit(runes[m]) {
+				m++
+			}
+			n = m
+		}
+	}
+	return n, kind
+}
+
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
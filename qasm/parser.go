@@ -0,0 +1,622 @@
+package qasm
+
+import (
+	"math"
+	"strconv"
+)
+
+// Statements of a parsed program, in order
+type statement interface{}
+
+type regDecl struct {
+	quantum bool
+	name    string
+	size    int
+	pos     position
+}
+
+type gateDecl struct {
+	name   string
+	params []string
+	qargs  []string
+	body   []*gateCall
+	opaque bool
+	pos    position
+
+	// Declared by qelib1.inc rather than the program
+	standard bool
+}
+
+// Application of a gate, including the built-in U and CX
+type gateCall struct {
+	name   string
+	params []expr
+	args   []argument
+	pos    position
+}
+
+type measure struct {
+	qubit, clbit argument
+	pos          position
+}
+
+type reset struct {
+	qubit argument
+	pos   position
+}
+
+type barrier struct {
+	args []argument
+}
+
+// Statement that only runs when a classical register equals value
+type ifStmt struct {
+	creg  string
+	value int
+	body  statement
+	pos   position
+}
+
+// A whole register, or one bit of it when index is not -1
+type argument struct {
+	name  string
+	index int
+	pos   position
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// Is the next token the given symbol or identifier?
+func (p *parser) at(text string) bool {
+	t := p.peek()
+	return (t.kind == tokSymbol || t.kind == tokIdent) && t.text == text
+}
+
+func describe(t token) string {
+	if t.kind == tokEOF {
+		return "end of file"
+	}
+	return strconv.Quote(t.text)
+}
+
+func (p *parser) expect(text string) (token, error) {
+	if !p.at(text) {
+		t := p.peek()
+		return t, t.pos.errorf("expected %q, found %v", text, describe(t))
+	}
+	return p.take(), nil
+}
+
+func (p *parser) ident() (token, error) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return t, t.pos.errorf("expected an identifier, found %v", describe(t))
+	}
+	return p.take(), nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.peek()
+	if t.kind != tokInt {
+		return 0, t.pos.errorf("expected a non-negative integer, found %v", describe(t))
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, t.pos.errorf("integer %v is too large", t.text)
+	}
+	p.take()
+	return n, nil
+}
+
+// Parses a whole program, starting with its OPENQASM 2.0 header.
+// include statements are resolved by the include function.
+func parseProgram(tokens []token, include func(name string, pos position) ([]statement, error)) ([]statement, error) {
+	p := &parser{tokens: tokens}
+
+	if _, err := p.expect("OPENQASM"); err != nil {
+		return nil, err
+	}
+	version := p.peek()
+	if version.kind != tokReal && version.kind != tokInt {
+		return nil, version.pos.errorf("expected a version number, found %v", describe(version))
+	}
+	if v, _ := strconv.ParseFloat(version.text, 64); v != 2 {
+		return nil, version.pos.errorf("OpenQASM version %v is not supported, only 2.0", version.text)
+	}
+	p.take()
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+
+	return p.statements(include)
+}
+
+// Parses statements until the end of the file
+func (p *parser) statements(include func(name string, pos position) ([]statement, error)) ([]statement, error) {
+	var stmts []statement
+	for p.peek().kind != tokEOF {
+		if p.at("include") {
+			t := p.take()
+			name := p.peek()
+			if name.kind != tokString {
+				return nil, name.pos.errorf("expected a file name, found %v", describe(name))
+			}
+			p.take()
+			if _, err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			included, err := include(name.text, t.pos)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, included...)
+			continue
+		}
+
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+func (p *parser) statement() (statement, error) {
+	t := p.peek()
+	switch {
+	case p.at("qreg"), p.at("creg"):
+		return p.regDecl()
+	case p.at("gate"), p.at("opaque"):
+		return p.gateDecl()
+	case p.at("if"):
+		return p.ifStmt()
+	case p.at("OPENQASM"):
+		return nil, t.pos.errorf("OPENQASM header must come first")
+	case t.kind == tokIdent:
+		return p.qop()
+	}
+	return nil, t.pos.errorf("expected a statement, found %v", describe(t))
+}
+
+func (p *parser) regDecl() (statement, error) {
+	t := p.take()
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("["); err != nil {
+		return nil, err
+	}
+	size, err := p.integer()
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, name.pos.errorf("register %v has no bits", name.text)
+	}
+	if _, err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return &regDecl{quantum: t.text == "qreg", name: name.text, size: size, pos: name.pos}, nil
+}
+
+func (p *parser) gateDecl() (statement, error) {
+	t := p.take()
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	decl := &gateDecl{name: name.text, opaque: t.text == "opaque", pos: name.pos}
+
+	if p.at("(") {
+		p.take()
+		if !p.at(")") {
+			if decl.params, err = p.idents(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if decl.qargs, err = p.idents(); err != nil {
+		return nil, err
+	}
+
+	if decl.opaque {
+		_, err := p.expect(";")
+		return decl, err
+	}
+
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.at("}") {
+		if p.at("barrier") {
+			if _, err := p.barrier(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t := p.peek()
+		if t.kind != tokIdent || t.text == "measure" || t.text == "reset" {
+			return nil, t.pos.errorf("expected a gate in the body of gate %v, found %v", decl.name, describe(t))
+		}
+		call, err := p.gateCall()
+		if err != nil {
+			return nil, err
+		}
+		decl.body = append(decl.body, call)
+	}
+	p.take()
+	return decl, nil
+}
+
+// Comma separated identifiers
+func (p *parser) idents() ([]string, error) {
+	var names []string
+	for {
+		t, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, t.text)
+		if !p.at(",") {
+			return names, nil
+		}
+		p.take()
+	}
+}
+
+func (p *parser) ifStmt() (statement, error) {
+	t := p.take()
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	creg, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("=="); err != nil {
+		return nil, err
+	}
+	value, err := p.integer()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	body, err := p.qop()
+	if err != nil {
+		return nil, err
+	}
+	return &ifStmt{creg: creg.text, value: value, body: body, pos: t.pos}, nil
+}
+
+// Parses a quantum operation: a gate, measurement, reset or barrier
+func (p *parser) qop() (statement, error) {
+	t := p.peek()
+	switch {
+	case p.at("measure"):
+		p.take()
+		q, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("->"); err != nil {
+			return nil, err
+		}
+		c, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		return &measure{qubit: q, clbit: c, pos: t.pos}, nil
+	case p.at("reset"):
+		p.take()
+		q, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		return &reset{qubit: q, pos: t.pos}, nil
+	case p.at("barrier"):
+		return p.barrier()
+	case t.kind == tokIdent && !p.at("qreg") && !p.at("creg") && !p.at("gate") && !p.at("opaque") && !p.at("if"):
+		return p.gateCall()
+	}
+	return nil, t.pos.errorf("expected a gate, measure, reset or barrier, found %v", describe(t))
+}
+
+func (p *parser) barrier() (statement, error) {
+	p.take()
+	args, err := p.arguments()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return &barrier{args: args}, nil
+}
+
+func (p *parser) gateCall() (*gateCall, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	call := &gateCall{name: name.text, pos: name.pos}
+
+	if p.at("(") {
+		p.take()
+		for !p.at(")") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.params = append(call.params, e)
+			if !p.at(",") {
+				break
+			}
+			p.take()
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if call.args, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// Comma separated registers or bits
+func (p *parser) arguments() ([]argument, error) {
+	var args []argument
+	for {
+		a, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if !p.at(",") {
+			return args, nil
+		}
+		p.take()
+	}
+}
+
+func (p *parser) argument() (argument, error) {
+	name, err := p.ident()
+	if err != nil {
+		return argument{}, err
+	}
+	a := argument{name: name.text, index: -1, pos: name.pos}
+	if p.at("[") {
+		p.take()
+		if a.index, err = p.integer(); err != nil {
+			return argument{}, err
+		}
+		if _, err := p.expect("]"); err != nil {
+			return argument{}, err
+		}
+	}
+	return a, nil
+}
+
+// Real-valued parameter expression
+type expr interface {
+	eval(env map[string]float64) (float64, error)
+}
+
+type number float64
+
+type variable struct {
+	name string
+	pos  position
+}
+
+type unary struct {
+	op  string
+	arg expr
+}
+
+type binary struct {
+	op          string
+	left, right expr
+}
+
+func (n number) eval(env map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (v variable) eval(env map[string]float64) (float64, error) {
+	x, ok := env[v.name]
+	if !ok {
+		return 0, v.pos.errorf("undefined parameter %v", v.name)
+	}
+	return x, nil
+}
+
+func (u unary) eval(env map[string]float64) (float64, error) {
+	x, err := u.arg.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch u.op {
+	case "-":
+		return -x, nil
+	case "sin":
+		return math.Sin(x), nil
+	case "cos":
+		return math.Cos(x), nil
+	case "tan":
+		return math.Tan(x), nil
+	case "exp":
+		return math.Exp(x), nil
+	case "ln":
+		return math.Log(x), nil
+	case "sqrt":
+		return math.Sqrt(x), nil
+	}
+	return x, nil
+}
+
+func (b binary) eval(env map[string]float64) (float64, error) {
+	l, err := b.left.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := b.right.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	}
+	return math.Pow(l, r), nil
+}
+
+var unaryFunctions = map[string]bool{"sin": true, "cos": true, "tan": true, "exp": true, "ln": true, "sqrt": true}
+
+// expr := term (('+' | '-') term)*
+func (p *parser) expr() (expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.at("+") || p.at("-") {
+		op := p.take().text
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// term := factor (('*' | '/') factor)*
+func (p *parser) term() (expr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.at("*") || p.at("/") {
+		op := p.take().text
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// factor := ('-' | '+') factor | primary ('^' factor)?
+func (p *parser) factor() (expr, error) {
+	if p.at("-") || p.at("+") {
+		op := p.take().text
+		arg, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return arg, nil
+		}
+		return unary{op: "-", arg: arg}, nil
+	}
+
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.at("^") {
+		p.take()
+		exponent, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return binary{op: "^", left: base, right: exponent}, nil
+	}
+	return base, nil
+}
+
+// primary := number | pi | identifier | function '(' expr ')' | '(' expr ')'
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokInt || t.kind == tokReal:
+		p.take()
+		x, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, t.pos.errorf("invalid number %v", t.text)
+		}
+		return number(x), nil
+	case p.at("pi"):
+		p.take()
+		return number(math.Pi), nil
+	case t.kind == tokIdent && unaryFunctions[t.text]:
+		p.take()
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return unary{op: t.text, arg: arg}, nil
+	case t.kind == tokIdent:
+		p.take()
+		return variable{name: t.text, pos: t.pos}, nil
+	case p.at("("):
+		p.take()
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return nil, t.pos.errorf("expected an expression, found %v", describe(t))
+}
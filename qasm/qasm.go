@@ -0,0 +1,81 @@
+// Package qasm reads OpenQASM 2.0 programs into circuits of the sim package.
+//
+// Programs may declare quantum and classical registers, include "qelib1.inc",
+// define gates and use measure, reset, barrier and if. Quantum registers are laid
+// out on the circuit's qubits in declaration order, and classical registers on its
+// classical bits likewise. Gates of qelib1.inc become the matching sim gates where
+// there is one; all other gates are expanded into their definitions.
+package qasm
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"qgo/sim"
+)
+
+// Problem with a program, at the line and column where it was found
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v:%v: %v", e.Line, e.Col, e.Msg)
+}
+
+// Parses an OpenQASM 2.0 program into a circuit.
+// Returns an *Error for syntax errors and for constructs the simulator does not support,
+// such as opaque gates or includes other than qelib1.inc.
+func Parse(src string) (sim.QuantumCircuit, error) {
+	return parse(src)
+}
+
+// Reads and parses an OpenQASM 2.0 file, as Parse
+func ParseFile(path string) (sim.QuantumCircuit, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return sim.QuantumCircuit{}, err
+	}
+	return parse(string(src))
+}
+
+func parse(src string) (sim.QuantumCircuit, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return sim.QuantumCircuit{}, err
+	}
+	included := false
+	stmts, err := parseProgram(tokens, func(name string, pos position) ([]statement, error) {
+		if name != "qelib1.inc" {
+			return nil, pos.errorf("cannot include %q, only qelib1.inc is supported", name)
+		}
+		if included {
+			return nil, pos.errorf("qelib1.inc is already included")
+		}
+		included = true
+		return standardLibrary(), nil
+	})
+	if err != nil {
+		return sim.QuantumCircuit{}, err
+	}
+	return build(stmts)
+}
+
+// Gate declarations of qelib1.inc
+func standardLibrary() []statement {
+	tokens, err := tokenize(qelib1)
+	if err != nil {
+		panic(err)
+	}
+	p := &parser{tokens: tokens}
+	stmts, err := p.statements(nil)
+	if err != nil {
+		panic(err)
+	}
+	for _, s := range stmts {
+		s.(*gateDecl).standard = true
+	}
+	return stmts
+}
@@ -0,0 +1,189 @@
+package qasm
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"qgo/sim"
+)
+
+const header = "OPENQASM 2.0;\ninclude \"qelib1.inc\";\n"
+
+func TestParse_NativeGatesMatchDefinitions(t *testing.T) {
+	for _, s := range standardLibrary() {
+		d := s.(*gateDecl)
+		if _, ok := nativeGates[d.name]; !ok {
+			continue
+		}
+		t.Run(d.name, func(t *testing.T) {
+			params := make([]string, len(d.params))
+			for i := range params {
+				params[i] = fmt.Sprint(0.3 + 0.7*float64(i))
+			}
+			qubits := make([]string, len(d.qargs))
+			for i := range qubits {
+				qubits[i] = fmt.Sprintf("q[%v]", len(qubits)-1-i)
+			}
+			body := fmt.Sprintf("qreg q[%v];\n%v(%v) %v;\n", len(qubits), d.name, strings.Join(params, ","), strings.Join(qubits, ","))
+
+			native, err := Parse(header + body)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			// Written out rather than included, the library gates are user gates and expanded
+			expanded, err := Parse("OPENQASM 2.0;\n" + qelib1 + body)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := native.Operator()
+			if err != nil {
+				t.Fatalf("Operator() error = %v", err)
+			}
+			want, err := expanded.Operator()
+			if err != nil {
+				t.Fatalf("Operator() error = %v", err)
+			}
+			if !sim.EqualUpToGlobalPhase(got, want, 1e-9) {
+				t.Errorf("%v = %v, definition gives %v", d.name, got, want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Bell state", func(t *testing.T) {
+		qc, err := Parse(header + `
+// Bell pair
+qreg q[2];
+creg c[2];
+h q[0];
+cx q[0], q[1];
+barrier q;
+measure q -> c;
+`)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if qc.NumQubits() != 2 || qc.NumClbits() != 2 {
+			t.Fatalf("Parse() has %v qubits and %v clbits, want 2 and 2", qc.NumQubits(), qc.NumClbits())
+		}
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 20; i++ {
+			c := qc.Exec([]sim.Ket{sim.ZeroKet, sim.ZeroKet}, sim.WithRand(rng)).Clbits()
+			if c[0] != c[1] {
+				t.Fatalf("Clbits() = %v, want equal bits", c)
+			}
+		}
+	})
+
+	t.Run("Registers, user gates and if", func(t *testing.T) {
+		qc, err := Parse(header + `
+qreg a[1];
+qreg b[2];
+creg flag[1];
+creg out[2];
+gate flip(theta) x, y { U(theta, 0, pi) x; CX x, y; }
+flip(pi) a[0], b[1];
+measure a[0] -> flag[0];
+if (flag == 1) x b;
+reset a;
+measure b -> out;
+`)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if qc.NumQubits() != 3 || qc.NumClbits() != 3 {
+			t.Fatalf("Parse() has %v qubits and %v clbits, want 3 and 3", qc.NumQubits(), qc.NumClbits())
+		}
+		qce := qc.Exec([]sim.Ket{sim.ZeroKet, sim.ZeroKet, sim.ZeroKet})
+		if got, want := qce.Clbits(), []int{1, 1, 0}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Clbits() = %v, want %v", got, want)
+		}
+		if p := qce.MeasureProbabilityOn(0); p > 1e-9 {
+			t.Errorf("MeasureProbabilityOn(0) = %v after reset, want 0", p)
+		}
+	})
+
+	t.Run("Parameter expressions", func(t *testing.T) {
+		qc, err := Parse(header + "qreg q[1];\nrx(-2*pi/4 + sqrt(4)^2 - 4*cos(0) + ln(exp(pi/2))) q[0];\n")
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		want := sim.NewQuantumCircuit(1)
+		want.RX(0, 0)
+		if !sim.Equivalent(qc, want, 1e-9) {
+			t.Errorf("rx expression does not evaluate to 0")
+		}
+	})
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	qc := sim.NewQuantumCircuit(5)
+	qc.H([]int{0, 1, 2, 3, 4})
+	qc.Y(1)
+	qc.Sdg(2)
+	qc.Tdg(3)
+	qc.RX(0.3, 0)
+	qc.RY(-1.2, 1)
+	qc.U(0.1, 0.2, 0.3, 4)
+	qc.CY(0, 1)
+	qc.CH(sim.NegControl(2), 3)
+	qc.CRY(0.9, 1, 4)
+	qc.CRZ(-0.4, 3, 0)
+	qc.CSWAP(4, 0, 2)
+	qc.ISWAP(1, 3)
+	qc.MCX([]int{0, 1, 2, 3}, 4)
+	qc.MCU([]int{sim.NegControl(4)}, sim.Sdg, []int{2})
+	qc.MCU([]int{1}, sim.Rx(0.8), []int{0})
+
+	src, err := qc.ToQASM(sim.QASM2)
+	if err != nil {
+		t.Fatalf("ToQASM() error = %v", err)
+	}
+	got, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse() error = %v in\n%v", err, src)
+	}
+	if !sim.Equivalent(got, qc, 1e-9) {
+		t.Errorf("Parse(ToQASM()) is not equivalent to the circuit:\n%v", src)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		line, col int
+		msg       string
+	}{
+		{name: "Missing header", src: "qreg q[1];", line: 1, col: 1, msg: "OPENQASM"},
+		{name: "Version 3", src: "OPENQASM 3.0;", line: 1, col: 10, msg: "not supported"},
+		{name: "Other include", src: "OPENQASM 2.0;\ninclude \"stdgates.inc\";", line: 2, col: 1, msg: "only qelib1.inc"},
+		{name: "Missing semicolon", src: header + "qreg q[1]\nh q[0];", line: 4, col: 1, msg: `expected ";"`},
+		{name: "Undefined gate", src: header + "qreg q[1];\nfoo q[0];", line: 4, col: 1, msg: "undefined gate foo"},
+		{name: "Out of range", src: header + "qreg q[1];\nh q[1];", line: 4, col: 3, msg: "out of range"},
+		{name: "Undeclared register", src: header + "h r;", line: 3, col: 3, msg: "undeclared register r"},
+		{name: "Register sizes", src: header + "qreg a[2];\nqreg b[3];\ncx a, b;", line: 5, col: 7, msg: "has 3 qubits"},
+		{name: "Repeated qubit", src: header + "qreg q[2];\ncx q[1], q[1];", line: 4, col: 10, msg: "twice"},
+		{name: "Wrong parameter count", src: header + "qreg q[1];\nrx q[0];", line: 4, col: 1, msg: "takes 1 parameters"},
+		{name: "Opaque gate", src: header + "opaque magic a;\nqreg q[1];\nmagic q[0];", line: 5, col: 1, msg: "opaque gate magic"},
+		{name: "Unknown parameter", src: header + "gate g a { U(theta, 0, 0) a; }", line: 3, col: 14, msg: "undefined parameter theta"},
+		{name: "Measure sizes", src: header + "qreg q[2];\ncreg c[1];\nmeasure q -> c;", line: 5, col: 1, msg: "cannot measure"},
+		{name: "If on a quantum register", src: header + "qreg q[1];\nif (q == 1) x q[0];", line: 4, col: 1, msg: "not a classical register"},
+		{name: "Unexpected character", src: header + "qreg q[1];\nh q[0] @;", line: 4, col: 8, msg: "unexpected character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Parse() error = %v, want an *Error", err)
+			}
+			if e.Line != tt.line || e.Col != tt.col || !strings.Contains(e.Msg, tt.msg) {
+				t.Errorf("Parse() error = %v, want %v:%v containing %q", e, tt.line, tt.col, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package quil
+
+import (
+	"math"
+
+	"qgo/sim"
+)
+
+// Bits of a DECLARE, as a range of the circuit's classical bits
+type memory struct {
+	offset int
+	size   int
+}
+
+// Jump past the following instructions, taken when bit equals value
+type pendingJump struct {
+	label string
+	bit   int
+	value int
+	pos   position
+}
+
+// Turns parsed instructions into gates of a circuit
+type builder struct {
+	qc       *sim.QuantumCircuit
+	memory   map[string]memory
+	defgates map[string]*defgate
+	jumps    []pendingJump
+	labels   map[string]bool
+}
+
+// Builds the circuit of a program, on as many qubits as its largest qubit index needs.
+// Memory is laid out in declaration order, followed by a bit for each measurement
+// whose outcome is discarded.
+func build(insts []instruction) (sim.QuantumCircuit, error) {
+	numQubits := 0
+	use := func(q qubitArg) {
+		if q.index >= numQubits {
+			numQubits = q.index + 1
+		}
+	}
+	for _, inst := range insts {
+		switch inst := inst.(type) {
+		case *gateInst:
+			for _, q := range inst.qubits {
+				use(q)
+			}
+		case *measure:
+			use(inst.qubit)
+		case *reset:
+			if inst.qubit != nil {
+				use(*inst.qubit)
+			}
+		}
+	}
+
+	qc := sim.NewQuantumCircuit(numQubits)
+	b := &builder{
+		qc:       &qc,
+		memory:   map[string]memory{},
+		defgates: map[string]*defgate{},
+		labels:   map[string]bool{},
+	}
+	for _, inst := range insts {
+		switch inst := inst.(type) {
+		case *declare:
+			if _, exists := b.memory[inst.name]; exists {
+				return sim.QuantumCircuit{}, inst.pos.errorf("memory %v is already declared", inst.name)
+			}
+			b.memory[inst.name] = memory{offset: qc.AddClbits(inst.size), size: inst.size}
+		case *defgate:
+			if err := b.define(inst); err != nil {
+				return sim.QuantumCircuit{}, err
+			}
+		}
+	}
+
+	for _, inst := range insts {
+		if _, ok := inst.(halt); ok {
+			return qc, nil
+		}
+		if err := b.instruction(inst); err != nil {
+			return sim.QuantumCircuit{}, err
+		}
+	}
+	if len(b.jumps) > 0 {
+		j := b.jumps[0]
+		return sim.QuantumCircuit{}, j.pos.errorf("label @%v is never defined", j.label)
+	}
+	return qc, nil
+}
+
+// Records a DEFGATE after checking its matrix is square with a power of two size
+func (b *builder) define(d *defgate) error {
+	if _, exists := standardGates[d.name]; exists {
+		return d.pos.errorf("gate %v is a standard gate and cannot be redefined", d.name)
+	}
+	if _, exists := b.defgates[d.name]; exists {
+		return d.pos.errorf("gate %v is already defined", d.name)
+	}
+	n := len(d.matrix)
+	if n < 2 || n&(n-1) != 0 {
+		return d.pos.errorf("matrix of gate %v has %v rows, which is not a power of two", d.name, n)
+	}
+	for _, row := range d.matrix {
+		if len(row) != n {
+			return d.pos.errorf("matrix of gate %v is not square", d.name)
+		}
+	}
+	b.defgates[d.name] = d
+	return nil
+}
+
+// Circuit gates are added to, conditioned on the jumps that skip over them not being taken
+func (b *builder) target() *sim.QuantumCircuit {
+	if len(b.jumps) == 0 {
+		return b.qc
+	}
+	bits := make([]int, len(b.jumps))
+	value := 0
+	for i, j := range b.jumps {
+		bits[i] = j.bit
+		value |= (1 - j.value) << uint(i)
+	}
+	return b.qc.IfRegister(bits, value)
+}
+
+func (b *builder) instruction(inst instruction) error {
+	switch inst := inst.(type) {
+	case *gateInst:
+		return b.gate(inst)
+
+	case *measure:
+		var bit int
+		if inst.addr == nil {
+			bit = b.qc.AddClbits(1)
+		} else {
+			var err error
+			if bit, err = b.bit(*inst.addr); err != nil {
+				return err
+			}
+			for _, j := range b.jumps {
+				if j.bit == bit {
+					return inst.pos.errorf("measuring into %v[%v], which a pending jump to @%v reads, is not supported", inst.addr.name, inst.addr.index, j.label)
+				}
+			}
+		}
+		b.target().Measure(inst.qubit.index, bit)
+
+	case *reset:
+		qc := b.target()
+		if inst.qubit != nil {
+			qc.Reset(inst.qubit.index)
+			return nil
+		}
+		for q := 0; q < b.qc.NumQubits(); q++ {
+			qc.Reset(q)
+		}
+
+	case *jump:
+		if b.labels[inst.label] {
+			return inst.pos.errorf("jumping back to @%v is not supported", inst.label)
+		}
+		bit, err := b.bit(inst.addr)
+		if err != nil {
+			return err
+		}
+		value := 0
+		if inst.when {
+			value = 1
+		}
+		b.jumps = append(b.jumps, pendingJump{label: inst.label, bit: bit, value: value, pos: inst.pos})
+
+	case *label:
+		if b.labels[inst.name] {
+			return inst.pos.errorf("label @%v is already defined", inst.name)
+		}
+		b.labels[inst.name] = true
+		remaining := b.jumps[:0]
+		for _, j := range b.jumps {
+			if j.label != inst.name {
+				remaining = append(remaining, j)
+			}
+		}
+		b.jumps = remaining
+	}
+	return nil
+}
+
+// Classical bit a memory reference refers to
+func (b *builder) bit(ref memRef) (int, error) {
+	mem, ok := b.memory[ref.name]
+	if !ok {
+		return 0, ref.pos.errorf("undeclared memory %v", ref.name)
+	}
+	if ref.index >= mem.size {
+		return 0, ref.pos.errorf("index %v is out of range for memory %v of size %v", ref.index, ref.name, mem.size)
+	}
+	return mem.offset + ref.index, nil
+}
+
+// Adds a gate, as the matching sim gate where there is one and as a matrix otherwise
+func (b *builder) gate(g *gateInst) error {
+	numControls := 0
+	for _, m := range g.modifiers {
+		if m == "CONTROLLED" {
+			numControls++
+		}
+	}
+
+	params := make([]complex128, len(g.params))
+	for i, e := range g.params {
+		x, err := e.eval(nil)
+		if err != nil {
+			return err
+		}
+		params[i] = x
+	}
+
+	qubits := make([]int, len(g.qubits))
+	for i, q := range g.qubits {
+		for _, prev := range qubits[:i] {
+			if prev == q.index {
+				return q.pos.errorf("qubit %v is used twice by %v", q.index, g.name)
+			}
+		}
+		qubits[i] = q.index
+	}
+	if numControls >= len(qubits) {
+		return g.pos.errorf("gate %v has %v CONTROLLED modifiers but only %v qubits", g.name, numControls, len(qubits))
+	}
+	controls, targets := qubits[:numControls], qubits[numControls:]
+
+	if std, ok := standardGates[g.name]; ok {
+		angles, err := realParams(g, params)
+		if err != nil {
+			return err
+		}
+		if len(angles) != std.params {
+			return g.pos.errorf("gate %v takes %v parameters, got %v", g.name, std.params, len(angles))
+		}
+		if len(targets) != std.qubits {
+			return g.pos.errorf("gate %v takes %v qubits, got %v", g.name, std.qubits, len(targets))
+		}
+
+		qc := b.target()
+		switch {
+		case len(g.modifiers) == 0:
+			if err := std.native(qc, angles, targets); err != nil {
+				return g.pos.errorf("%v", err)
+			}
+			return nil
+		case numControls == len(g.modifiers) && g.name == "X":
+			qc.MCX(controls, targets[0])
+			return nil
+		case numControls == 1 && len(g.modifiers) == 1 && controlledNative[g.name] != nil:
+			controlledNative[g.name](qc, angles, controls[0], targets[0])
+			return nil
+		case numControls == 0 && len(g.modifiers)%2 == 1 && g.name == "S":
+			qc.Sdg(targets[0])
+			return nil
+		case numControls == 0 && len(g.modifiers)%2 == 1 && g.name == "T":
+			qc.Tdg(targets[0])
+			return nil
+		}
+		return b.matrixGate(g, std.matrix(angles), controls, targets, g.name)
+	}
+
+	d, ok := b.defgates[g.name]
+	if !ok {
+		return g.pos.errorf("undefined gate %v", g.name)
+	}
+	if len(params) != len(d.params) {
+		return g.pos.errorf("gate %v takes %v parameters, got %v", g.name, len(d.params), len(params))
+	}
+	env := map[string]complex128{}
+	for i, p := range d.params {
+		env[p] = params[i]
+	}
+	n := len(d.matrix)
+	m := sim.Identity(n)
+	for r, row := range d.matrix {
+		for c, e := range row {
+			x, err := e.eval(env)
+			if err != nil {
+				return err
+			}
+			m.Data[r*n+c] = x
+		}
+	}
+	if 1<<uint(len(targets)) != n {
+		return g.pos.errorf("gate %v takes %v qubits, got %v", g.name, bitLength(n), len(targets))
+	}
+	if len(g.modifiers) == 0 {
+		if err := b.target().Unitary(m, targets, g.name); err != nil {
+			return g.pos.errorf("%v", err)
+		}
+		return nil
+	}
+	return b.matrixGate(g, m, controls, targets, g.name)
+}
+
+// Adds a gate under modifiers as its matrix, inverted by each DAGGER and controlled
+// by the leading qubits
+func (b *builder) matrixGate(g *gateInst, m sim.Matrix, controls, targets []int, name string) error {
+	if !sim.IsUnitary(m) {
+		return g.pos.errorf("matrix of gate %v is not unitary", name)
+	}
+	for _, mod := range g.modifiers {
+		if mod == "DAGGER" {
+			m = *m.Dagger()
+		}
+	}
+	b.target().MCU(controls, m, targets)
+	return nil
+}
+
+// Gate parameters, which must be real for the standard gates
+func realParams(g *gateInst, params []complex128) ([]float64, error) {
+	out := make([]float64, len(params))
+	for i, p := range params {
+		if math.Abs(imag(p)) > 1e-12 {
+			return nil, g.pos.errorf("parameter %v of %v is not real", i, g.name)
+		}
+		out[i] = real(p)
+	}
+	return out, nil
+}
+
+// Number of qubits a 2^k x 2^k matrix acts on
+func bitLength(n int) int {
+	k := 0
+	for n > 1 {
+		n >>= 1
+		k++
+	}
+	return k
+}
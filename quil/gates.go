@@ -0,0 +1,115 @@
+package quil
+
+import (
+	"math/cmplx"
+
+	"qgo/sim"
+)
+
+// Gate of Quil's standard set. The matrix is used under modifiers and the
+// native function, which adds the matching sim gate, otherwise.
+type standardGate struct {
+	params int
+	qubits int
+	matrix func(p []float64) sim.Matrix
+	native func(qc *sim.QuantumCircuit, p []float64, q []int) error
+}
+
+func fixed(m sim.Matrix) func(p []float64) sim.Matrix {
+	return func(p []float64) sim.Matrix { return m }
+}
+
+// Block diagonal matrix applying u when a new most significant control qubit is |1>
+func controlled(u sim.Matrix) sim.Matrix {
+	n := 2 * u.Rows
+	m := sim.Identity(n)
+	for r := 0; r < u.Rows; r++ {
+		for c := 0; c < u.Cols; c++ {
+			m.Data[(u.Rows+r)*n+u.Rows+c] = u.Data[r*u.Stride+c]
+		}
+	}
+	return m
+}
+
+// Diagonal matrix on two qubits with a phase of e^(i*theta) on the given basis state
+func phaseOn(basis int) func(p []float64) sim.Matrix {
+	return func(p []float64) sim.Matrix {
+		m := sim.Identity(4)
+		m.Data[basis*4+basis] = cmplx.Exp(complex(0, p[0]))
+		return m
+	}
+}
+
+// Phase of e^(i*theta) on |0>, the opposite of sim.Phase
+func phaseOnZero(theta float64) sim.Matrix {
+	m := sim.Identity(2)
+	m.Data[0] = cmplx.Exp(complex(0, theta))
+	return m
+}
+
+func pswap(p []float64) sim.Matrix {
+	out := sim.Identity(4)
+	copy(out.Data, sim.Swap.Data)
+	out.Data[1*4+2] = cmplx.Exp(complex(0, p[0]))
+	out.Data[2*4+1] = cmplx.Exp(complex(0, p[0]))
+	return out
+}
+
+var standardGates = map[string]standardGate{
+	"I": {0, 1, fixed(sim.I), func(qc *sim.QuantumCircuit, p []float64, q []int) error { return nil }},
+	"X": {0, 1, fixed(sim.X), func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.X(q[0]); return nil }},
+	"Y": {0, 1, fixed(sim.Y), func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.Y(q[0]); return nil }},
+	"Z": {0, 1, fixed(sim.Z), func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.Z(q[0]); return nil }},
+	"H": {0, 1, fixed(sim.H), func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.H(q[:1]); return nil }},
+	"S": {0, 1, fixed(sim.S), func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.S(q[0]); return nil }},
+	"T": {0, 1, fixed(sim.T), func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.T(q[0]); return nil }},
+	"PHASE": {1, 1, func(p []float64) sim.Matrix { return sim.Phase(p[0]) },
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.P(p[0], q[0]); return nil }},
+	"RX": {1, 1, func(p []float64) sim.Matrix { return sim.Rx(p[0]) },
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.RX(p[0], q[0]); return nil }},
+	"RY": {1, 1, func(p []float64) sim.Matrix { return sim.Ry(p[0]) },
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.RY(p[0], q[0]); return nil }},
+	"RZ": {1, 1, func(p []float64) sim.Matrix { return sim.Rz(p[0]) },
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.RZ(p[0], q[0]); return nil }},
+
+	"CZ": {0, 2, fixed(controlled(sim.Z)),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.CZ(q[0], q[1]); return nil }},
+	"CNOT": {0, 2, fixed(controlled(sim.X)),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.CX(q[0], q[1]); return nil }},
+	"CCNOT": {0, 3, fixed(controlled(controlled(sim.X))),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.CCX(q[0], q[1], q[2]); return nil }},
+	"CPHASE": {1, 2, phaseOn(3),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.CP(p[0], q[0], q[1]); return nil }},
+	"CPHASE00": {1, 2, phaseOn(0), func(qc *sim.QuantumCircuit, p []float64, q []int) error {
+		qc.MCU([]int{sim.NegControl(q[0])}, phaseOnZero(p[0]), q[1:])
+		return nil
+	}},
+	"CPHASE01": {1, 2, phaseOn(1), func(qc *sim.QuantumCircuit, p []float64, q []int) error {
+		qc.MCU([]int{sim.NegControl(q[0])}, sim.Phase(p[0]), q[1:])
+		return nil
+	}},
+	"CPHASE10": {1, 2, phaseOn(2), func(qc *sim.QuantumCircuit, p []float64, q []int) error {
+		qc.MCU(q[:1], phaseOnZero(p[0]), q[1:])
+		return nil
+	}},
+	"SWAP": {0, 2, fixed(sim.Swap),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.SWAP(q[0], q[1]); return nil }},
+	"CSWAP": {0, 3, fixed(controlled(sim.Swap)),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.CSWAP(q[0], q[1], q[2]); return nil }},
+	"ISWAP": {0, 2, fixed(sim.ISwap),
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { qc.ISWAP(q[0], q[1]); return nil }},
+	"PSWAP": {1, 2, pswap,
+		func(qc *sim.QuantumCircuit, p []float64, q []int) error { return qc.Unitary(pswap(p), q, "PSWAP") }},
+}
+
+// Gates with one control that have a matching sim gate, used for CONTROLLED modifiers
+var controlledNative = map[string]func(qc *sim.QuantumCircuit, p []float64, control, target int){
+	"X":     func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CX(c, t) },
+	"Y":     func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CY(c, t) },
+	"Z":     func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CZ(c, t) },
+	"H":     func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CH(c, t) },
+	"PHASE": func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CP(p[0], c, t) },
+	"RX":    func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CRX(p[0], c, t) },
+	"RY":    func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CRY(p[0], c, t) },
+	"RZ":    func(qc *sim.QuantumCircuit, p []float64, c, t int) { qc.CRZ(p[0], c, t) },
+}
@@ -0,0 +1,179 @@
+package quil
+
+import (
+	"fmt"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNewline
+	tokIdent
+	tokInt
+	tokReal
+	tokImag  // Imaginary number such as 1.5i
+	tokParam // Gate parameter such as %theta, without the %
+	tokLabel // Jump target such as @end, without the @
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  position
+}
+
+// Line and column of a token, counted from 1
+type position struct {
+	line, col int
+}
+
+func (p position) errorf(format string, args ...interface{}) *Error {
+	return &Error{Line: p.line, Col: p.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Splits Quil source into tokens, dropping spaces and # comments.
+// Line breaks are kept as tokens, since Quil has one instruction per line.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	line, col := 1, 1
+
+	advance := func(n int) {
+		for i := 0; i < n; i++ {
+			if runes[0] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		pos := position{line, col}
+
+		switch {
+		case r == '\n':
+			tokens = append(tokens, token{kind: tokNewline, pos: pos})
+			advance(1)
+
+		case unicode.IsSpace(r):
+			advance(1)
+
+		case r == '#':
+			for len(runes) > 0 && runes[0] != '\n' {
+				advance(1)
+			}
+
+		case isIdentStart(r):
+			n := scanIdent(runes)
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[:n]), pos: pos})
+			advance(n)
+
+		case (r == '%' || r == '@') && len(runes) > 1 && isIdentStart(runes[1]):
+			n := scanIdent(runes[1:])
+			kind := tokParam
+			if r == '@' {
+				kind = tokLabel
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[1 : n+1]), pos: pos})
+			advance(n + 1)
+
+		case unicode.IsDigit(r) || r == '.' && len(runes) > 1 && unicode.IsDigit(runes[1]):
+			n, kind := scanNumber(runes)
+			text := string(runes[:n])
+			if n < len(runes) && runes[n] == 'i' && (n+1 == len(runes) || !isIdentPart(runes[n+1])) {
+				kind = tokImag
+				n++
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: pos})
+			advance(n)
+
+		case r == '"':
+			n := 1
+			for n < len(runes) && runes[n] != '"' && runes[n] != '\n' {
+				n++
+			}
+			if n == len(runes) || runes[n] != '"' {
+				return nil, pos.errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokString, text: string(runes[1:n]), pos: pos})
+			advance(n + 1)
+
+		case r < unicode.MaxASCII && containsRune("()[],:+-*/^", r):
+			tokens = append(tokens, token{kind: tokSymbol, text: string(r), pos: pos})
+			advance(1)
+
+		default:
+			return nil, pos.errorf("unexpected character %q", r)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, pos: position{line, col}})
+	return tokens, nil
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r < unicode.MaxASCII && unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || r == '-' || unicode.IsDigit(r)
+}
+
+// Length of the identifier at the start of runes. Identifiers may contain dashes,
+// as in JUMP-WHEN, but not end with one.
+func scanIdent(runes []rune) int {
+	n := 1
+	for n < len(runes) && isIdentPart(runes[n]) {
+		n++
+	}
+	for runes[n-1] == '-' {
+		n--
+	}
+	return n
+}
+
+// Length of the number at the start of runes, and whether it is an integer or real
+func scanNumber(runes []rune) (int, tokenKind) {
+	n, kind := 0, tokInt
+	for n < len(runes) && unicode.IsDigit(runes[n]) {
+		n++
+	}
+	if n < len(runes) && runes[n] == '.' {
+		kind = tokReal
+		n++
+		for n < len(runes) && unicode.IsDigit(runes[n]) {
+			n++
+		}
+	}
+	if n < len(runes) && (runes[n] == 'e' || runes[n] == 'E') {
+		m := n + 1
+		if m < len(runes) && (runes[m] == '+' || runes[m] == '-') {
+			m++
+		}
+		if m < len(runes) && unicode.IsDigit(runes[m]) {
+			kind = tokReal
+			for m < len(runes) && unicode.IsDigit(runes[m]) {
+				m++
+			}
+			n = m
+		}
+	}
+	return n, kind
+}
+
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,652 @@
+package quil
+
+import (
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// Instructions of a parsed program, in order
+type instruction interface{}
+
+// Classical memory declaration, DECLARE name BIT[size]
+type declare struct {
+	name string
+	size int
+	pos  position
+}
+
+// Gate defined by a matrix, whose entries may use the parameters
+type defgate struct {
+	name   string
+	params []string
+	matrix [][]expr
+	pos    position
+}
+
+// Application of a gate, under CONTROLLED and DAGGER modifiers in order
+type gateInst struct {
+	modifiers []string
+	name      string
+	params    []expr
+	qubits    []qubitArg
+	pos       position
+}
+
+type qubitArg struct {
+	index int
+	pos   position
+}
+
+// Measurement, discarding the outcome when addr is nil
+type measure struct {
+	qubit qubitArg
+	addr  *memRef
+	pos   position
+}
+
+// Reset of one qubit, or of every qubit when qubit is nil
+type reset struct {
+	qubit *qubitArg
+	pos   position
+}
+
+// JUMP-WHEN or JUMP-UNLESS
+type jump struct {
+	when  bool
+	label string
+	addr  memRef
+	pos   position
+}
+
+type label struct {
+	name string
+	pos  position
+}
+
+type halt struct{}
+
+// Bit of classical memory
+type memRef struct {
+	name  string
+	index int
+	pos   position
+}
+
+// Instructions that are valid Quil but have no meaning for the simulator
+var unsupported = map[string]bool{
+	"JUMP": true, "WAIT": true, "DEFCIRCUIT": true, "INCLUDE": true,
+	"MOVE": true, "EXCHANGE": true, "CONVERT": true, "LOAD": true, "STORE": true,
+	"NEG": true, "NOT": true, "AND": true, "IOR": true, "XOR": true,
+	"ADD": true, "SUB": true, "MUL": true, "DIV": true, "EQ": true, "GT": true, "GE": true, "LT": true, "LE": true,
+	"DEFFRAME": true, "DEFWAVEFORM": true, "DEFCAL": true, "PULSE": true, "CAPTURE": true, "RAW-CAPTURE": true,
+	"DELAY": true, "FENCE": true, "SET-FREQUENCY": true, "SHIFT-FREQUENCY": true, "SET-PHASE": true,
+	"SHIFT-PHASE": true, "SWAP-PHASES": true, "SET-SCALE": true,
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// Is the next token the given symbol or identifier?
+func (p *parser) at(text string) bool {
+	t := p.peek()
+	return (t.kind == tokSymbol || t.kind == tokIdent) && t.text == text
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokNewline:
+		return "end of line"
+	case tokParam:
+		return strconv.Quote("%" + t.text)
+	case tokLabel:
+		return strconv.Quote("@" + t.text)
+	case tokImag:
+		return strconv.Quote(t.text + "i")
+	}
+	return strconv.Quote(t.text)
+}
+
+func (p *parser) expect(text string) (token, error) {
+	if !p.at(text) {
+		t := p.peek()
+		return t, t.pos.errorf("expected %q, found %v", text, describe(t))
+	}
+	return p.take(), nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.peek()
+	if t.kind != tokInt {
+		return 0, t.pos.errorf("expected a non-negative integer, found %v", describe(t))
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, t.pos.errorf("integer %v is too large", t.text)
+	}
+	p.take()
+	return n, nil
+}
+
+// Consumes the end of an instruction
+func (p *parser) endOfLine() error {
+	t := p.peek()
+	if t.kind != tokNewline && t.kind != tokEOF {
+		return t.pos.errorf("expected end of line, found %v", describe(t))
+	}
+	p.take()
+	return nil
+}
+
+// Skips the rest of the line
+func (p *parser) skipLine() {
+	for t := p.take(); t.kind != tokNewline && t.kind != tokEOF; t = p.take() {
+	}
+}
+
+// Parses a whole program
+func parseProgram(tokens []token) ([]instruction, error) {
+	p := &parser{tokens: tokens}
+	var insts []instruction
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return insts, nil
+		case t.kind == tokNewline:
+			p.take()
+			continue
+		case t.kind != tokIdent:
+			return nil, t.pos.errorf("expected an instruction, found %v", describe(t))
+		}
+
+		var inst instruction
+		var err error
+		switch {
+		case t.text == "PRAGMA" || t.text == "NOP":
+			p.skipLine()
+			continue
+		case t.text == "HALT":
+			p.take()
+			inst = halt{}
+		case t.text == "DECLARE":
+			inst, err = p.declare()
+		case t.text == "DEFGATE":
+			inst, err = p.defgate()
+		case t.text == "MEASURE":
+			inst, err = p.measure()
+		case t.text == "RESET":
+			inst, err = p.reset()
+		case t.text == "JUMP-WHEN" || t.text == "JUMP-UNLESS":
+			inst, err = p.jump()
+		case t.text == "LABEL":
+			p.take()
+			l := p.peek()
+			if l.kind != tokLabel {
+				return nil, l.pos.errorf("expected a label, found %v", describe(l))
+			}
+			p.take()
+			inst = &label{name: l.text, pos: l.pos}
+		case unsupported[t.text]:
+			return nil, t.pos.errorf("%v is not supported", t.text)
+		default:
+			inst, err = p.gate()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := inst.(*defgate); !ok {
+			if err := p.endOfLine(); err != nil {
+				return nil, err
+			}
+		}
+		insts = append(insts, inst)
+	}
+}
+
+func (p *parser) declare() (instruction, error) {
+	p.take()
+	name := p.peek()
+	if name.kind != tokIdent {
+		return nil, name.pos.errorf("expected a memory name, found %v", describe(name))
+	}
+	p.take()
+	typ := p.peek()
+	if typ.kind != tokIdent {
+		return nil, typ.pos.errorf("expected a memory type, found %v", describe(typ))
+	}
+	if typ.text != "BIT" {
+		return nil, typ.pos.errorf("memory of type %v is not supported, only BIT", typ.text)
+	}
+	p.take()
+
+	size := 1
+	if p.at("[") {
+		p.take()
+		var err error
+		if size, err = p.integer(); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, name.pos.errorf("memory %v has no bits", name.text)
+		}
+		if _, err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	if p.at("SHARING") {
+		return nil, p.peek().pos.errorf("SHARING is not supported")
+	}
+	return &declare{name: name.text, size: size, pos: name.pos}, nil
+}
+
+// Parses DEFGATE and its matrix, given on the following indented lines
+func (p *parser) defgate() (instruction, error) {
+	p.take()
+	name := p.peek()
+	if name.kind != tokIdent {
+		return nil, name.pos.errorf("expected a gate name, found %v", describe(name))
+	}
+	p.take()
+	d := &defgate{name: name.text, pos: name.pos}
+
+	if p.at("(") {
+		p.take()
+		for !p.at(")") {
+			t := p.peek()
+			if t.kind != tokParam {
+				return nil, t.pos.errorf("expected a parameter, found %v", describe(t))
+			}
+			d.params = append(d.params, p.take().text)
+			if !p.at(",") {
+				break
+			}
+			p.take()
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.at("AS") {
+		p.take()
+		kind := p.take()
+		if kind.text != "MATRIX" {
+			return nil, kind.pos.errorf("DEFGATE AS %v is not supported, only MATRIX", kind.text)
+		}
+	}
+	if _, err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if err := p.endOfLine(); err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind == tokNewline {
+			p.take()
+			continue
+		}
+		if t.kind == tokEOF || t.pos.col == 1 {
+			break
+		}
+		var row []expr
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, e)
+			if !p.at(",") {
+				break
+			}
+			p.take()
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+		d.matrix = append(d.matrix, row)
+	}
+
+	if len(d.matrix) == 0 {
+		return nil, name.pos.errorf("gate %v has no matrix", d.name)
+	}
+	return d, nil
+}
+
+func (p *parser) gate() (instruction, error) {
+	g := &gateInst{pos: p.peek().pos}
+	for p.at("CONTROLLED") || p.at("DAGGER") || p.at("FORKED") {
+		t := p.take()
+		if t.text == "FORKED" {
+			return nil, t.pos.errorf("FORKED is not supported")
+		}
+		g.modifiers = append(g.modifiers, t.text)
+	}
+
+	name := p.peek()
+	if name.kind != tokIdent {
+		return nil, name.pos.errorf("expected a gate name, found %v", describe(name))
+	}
+	p.take()
+	g.name = name.text
+
+	if p.at("(") {
+		p.take()
+		for !p.at(")") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			g.params = append(g.params, e)
+			if !p.at(",") {
+				break
+			}
+			p.take()
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.peek().kind == tokInt {
+		q, err := p.qubit()
+		if err != nil {
+			return nil, err
+		}
+		g.qubits = append(g.qubits, q)
+	}
+	if len(g.qubits) == 0 {
+		t := p.peek()
+		return nil, t.pos.errorf("expected a qubit, found %v", describe(t))
+	}
+	return g, nil
+}
+
+func (p *parser) qubit() (qubitArg, error) {
+	pos := p.peek().pos
+	n, err := p.integer()
+	if err != nil {
+		return qubitArg{}, err
+	}
+	return qubitArg{index: n, pos: pos}, nil
+}
+
+func (p *parser) measure() (instruction, error) {
+	m := &measure{pos: p.take().pos}
+	var err error
+	if m.qubit, err = p.qubit(); err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokIdent {
+		addr, err := p.memRef()
+		if err != nil {
+			return nil, err
+		}
+		m.addr = &addr
+	}
+	return m, nil
+}
+
+func (p *parser) reset() (instruction, error) {
+	r := &reset{pos: p.take().pos}
+	if p.peek().kind == tokInt {
+		q, err := p.qubit()
+		if err != nil {
+			return nil, err
+		}
+		r.qubit = &q
+	}
+	return r, nil
+}
+
+func (p *parser) jump() (instruction, error) {
+	t := p.take()
+	l := p.peek()
+	if l.kind != tokLabel {
+		return nil, l.pos.errorf("expected a label, found %v", describe(l))
+	}
+	p.take()
+	addr, err := p.memRef()
+	if err != nil {
+		return nil, err
+	}
+	return &jump{when: t.text == "JUMP-WHEN", label: l.text, addr: addr, pos: t.pos}, nil
+}
+
+// Memory reference, name[index] or name for its first bit
+func (p *parser) memRef() (memRef, error) {
+	name := p.peek()
+	if name.kind != tokIdent {
+		return memRef{}, name.pos.errorf("expected a memory reference, found %v", describe(name))
+	}
+	p.take()
+	ref := memRef{name: name.text, pos: name.pos}
+	if p.at("[") {
+		p.take()
+		var err error
+		if ref.index, err = p.integer(); err != nil {
+			return memRef{}, err
+		}
+		if _, err := p.expect("]"); err != nil {
+			return memRef{}, err
+		}
+	}
+	return ref, nil
+}
+
+// Complex-valued expression, in gate parameters and DEFGATE matrices
+type expr interface {
+	eval(env map[string]complex128) (complex128, error)
+}
+
+type number complex128
+
+type param struct {
+	name string
+	pos  position
+}
+
+type unary struct {
+	op  string
+	arg expr
+}
+
+type binary struct {
+	op          string
+	left, right expr
+}
+
+func (n number) eval(env map[string]complex128) (complex128, error) {
+	return complex128(n), nil
+}
+
+func (v param) eval(env map[string]complex128) (complex128, error) {
+	x, ok := env[v.name]
+	if !ok {
+		return 0, v.pos.errorf("undefined parameter %%%v", v.name)
+	}
+	return x, nil
+}
+
+func (u unary) eval(env map[string]complex128) (complex128, error) {
+	x, err := u.arg.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch u.op {
+	case "-":
+		return -x, nil
+	case "sin":
+		return cmplx.Sin(x), nil
+	case "cos":
+		return cmplx.Cos(x), nil
+	case "sqrt":
+		return cmplx.Sqrt(x), nil
+	case "exp":
+		return cmplx.Exp(x), nil
+	case "cis":
+		return cmplx.Exp(1i * x), nil
+	}
+	return x, nil
+}
+
+func (b binary) eval(env map[string]complex128) (complex128, error) {
+	l, err := b.left.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := b.right.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	}
+	return cmplx.Pow(l, r), nil
+}
+
+var functions = map[string]bool{"sin": true, "cos": true, "sqrt": true, "exp": true, "cis": true}
+
+// expr := term (('+' | '-') term)*
+func (p *parser) expr() (expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.at("+") || p.at("-") {
+		op := p.take().text
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// term := factor (('*' | '/') factor)*
+func (p *parser) term() (expr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.at("*") || p.at("/") {
+		op := p.take().text
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// factor := ('-' | '+') factor | primary ('^' factor)?
+func (p *parser) factor() (expr, error) {
+	if p.at("-") || p.at("+") {
+		op := p.take().text
+		arg, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return arg, nil
+		}
+		return unary{op: "-", arg: arg}, nil
+	}
+
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.at("^") {
+		p.take()
+		exponent, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return binary{op: "^", left: base, right: exponent}, nil
+	}
+	return base, nil
+}
+
+// primary := number | imaginary | pi | i | %param | function '(' expr ')' | '(' expr ')'
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokInt, tokReal, tokImag:
+		p.take()
+		x, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, t.pos.errorf("invalid number %v", t.text)
+		}
+		if t.kind == tokImag {
+			return number(complex(0, x)), nil
+		}
+		return number(complex(x, 0)), nil
+	case tokParam:
+		p.take()
+		return param{name: t.text, pos: t.pos}, nil
+	case tokIdent:
+		name := strings.ToLower(t.text)
+		switch {
+		case name == "pi":
+			p.take()
+			return number(math.Pi), nil
+		case t.text == "i":
+			p.take()
+			return number(1i), nil
+		case functions[name]:
+			p.take()
+			if _, err := p.expect("("); err != nil {
+				return nil, err
+			}
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return unary{op: name, arg: arg}, nil
+		}
+		return nil, t.pos.errorf("unknown identifier %v in expression", t.text)
+	}
+	if p.at("(") {
+		p.take()
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return nil, t.pos.errorf("expected an expression, found %v", describe(t))
+}
@@ -0,0 +1,50 @@
+// Package quil reads Quil programs into circuits of the sim package.
+//
+// Programs may use Quil's standard gates under CONTROLLED and DAGGER modifiers,
+// gates defined by DEFGATE matrices, DECLARE of BIT memory, MEASURE and RESET.
+// Qubits keep their indices, and the circuit has as many qubits as the largest
+// index needs. Forward JUMP-WHEN and JUMP-UNLESS to a later LABEL become
+// conditions on the gates they skip, which is how QuantumCircuit.ToQuil writes them.
+package quil
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"qgo/sim"
+)
+
+// Problem with a program, at the line and column where it was found
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v:%v: %v", e.Line, e.Col, e.Msg)
+}
+
+// Parses a Quil program into a circuit.
+// Returns an *Error for syntax errors and for instructions the simulator does not support,
+// such as classical arithmetic, backward jumps and memory types other than BIT.
+func Parse(src string) (sim.QuantumCircuit, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return sim.QuantumCircuit{}, err
+	}
+	insts, err := parseProgram(tokens)
+	if err != nil {
+		return sim.QuantumCircuit{}, err
+	}
+	return build(insts)
+}
+
+// Reads and parses a Quil file, as Parse
+func ParseFile(path string) (sim.QuantumCircuit, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return sim.QuantumCircuit{}, err
+	}
+	return Parse(string(src))
+}
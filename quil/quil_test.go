@@ -0,0 +1,219 @@
+package quil
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"qgo/sim"
+)
+
+func TestParse_StandardGates(t *testing.T) {
+	for name, std := range standardGates {
+		t.Run(name, func(t *testing.T) {
+			params := make([]float64, std.params)
+			args := make([]string, std.params)
+			for i := range params {
+				params[i] = 0.3 + 0.7*float64(i)
+				args[i] = fmt.Sprint(params[i])
+			}
+			qubits := make([]string, std.qubits)
+			for i := range qubits {
+				qubits[i] = fmt.Sprint(i)
+			}
+			src := name + " " + strings.Join(qubits, " ")
+			if len(args) > 0 {
+				src = fmt.Sprintf("%v(%v) %v", name, strings.Join(args, ", "), strings.Join(qubits, " "))
+			}
+
+			qc, err := Parse(src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := qc.Operator()
+			if err != nil {
+				t.Fatalf("Operator() error = %v", err)
+			}
+			if want := std.matrix(params); !got.Equals(want, complex(1e-9, 1e-9)) {
+				t.Errorf("%v = %v, want %v", src, got, want)
+			}
+		})
+	}
+}
+
+func TestParse_Modifiers(t *testing.T) {
+	tests := []struct {
+		src   string
+		build func(qc *sim.QuantumCircuit)
+	}{
+		{"DAGGER S 0", func(qc *sim.QuantumCircuit) { qc.Sdg(0) }},
+		{"DAGGER DAGGER T 0", func(qc *sim.QuantumCircuit) { qc.T(0) }},
+		{"CONTROLLED RX(0.3) 1 0", func(qc *sim.QuantumCircuit) { qc.CRX(0.3, 1, 0) }},
+		{"CONTROLLED CONTROLLED X 2 0 1", func(qc *sim.QuantumCircuit) { qc.CCX(2, 0, 1) }},
+		{"DAGGER CONTROLLED PHASE(0.4) 0 1", func(qc *sim.QuantumCircuit) { qc.CP(-0.4, 0, 1) }},
+		{"CONTROLLED SWAP 2 1 0", func(qc *sim.QuantumCircuit) { qc.CSWAP(2, 1, 0) }},
+		{"CONTROLLED ISWAP 0 1 2", func(qc *sim.QuantumCircuit) { qc.MCU([]int{0}, sim.ISwap, []int{1, 2}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			want := sim.NewQuantumCircuit(got.NumQubits())
+			tt.build(&want)
+			if !sim.Equivalent(got, want, 1e-9) {
+				t.Errorf("Parse(%q) is not equivalent to the expected circuit", tt.src)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("DEFGATE with parameters", func(t *testing.T) {
+		qc, err := Parse(`
+# Rotation about Z written out
+DEFGATE MYRZ(%t):
+    cis(-%t/2), 0
+    0, cis(%t/2)
+
+MYRZ(0.7) 0
+CONTROLLED MYRZ(-0.2) 1 0
+`)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		want := sim.NewQuantumCircuit(2)
+		want.RZ(0.7, 0)
+		want.CRZ(-0.2, 1, 0)
+		if !sim.Equivalent(qc, want, 1e-9) {
+			t.Errorf("Parse() is not equivalent to RZ(0.7) and CRZ(-0.2)")
+		}
+	})
+
+	t.Run("Memory, measurements and jumps", func(t *testing.T) {
+		qc, err := Parse(`DECLARE ro BIT[3]
+DECLARE flag BIT
+X 0
+MEASURE 0 flag
+JUMP-UNLESS @skip flag[0]
+X 1
+LABEL @skip
+JUMP-WHEN @end flag
+X 2
+LABEL @end
+MEASURE 0 ro[0]
+MEASURE 1 ro[1]
+MEASURE 2 ro[2]
+MEASURE 0
+HALT
+`)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if qc.NumQubits() != 3 || qc.NumClbits() != 5 {
+			t.Fatalf("Parse() has %v qubits and %v clbits, want 3 and 5", qc.NumQubits(), qc.NumClbits())
+		}
+		got := qc.Exec([]sim.Ket{sim.ZeroKet, sim.ZeroKet, sim.ZeroKet}).Clbits()
+		if want := []int{1, 1, 0, 1, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Clbits() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	t.Run("Gates", func(t *testing.T) {
+		qc := sim.NewQuantumCircuit(4)
+		qc.H([]int{0, 1, 2, 3})
+		qc.Y(1)
+		qc.Sdg(2)
+		qc.Tdg(3)
+		qc.RX(0.3, 0)
+		qc.U(0.1, 0.2, 0.3, 2)
+		qc.CY(0, 1)
+		qc.CH(sim.NegControl(2), 3)
+		qc.CP(math.Pi/3, 1, 2)
+		qc.CRZ(-0.4, 3, 0)
+		qc.CSWAP(3, 0, 2)
+		qc.ISWAP(1, 3)
+		qc.MCX([]int{0, sim.NegControl(1), 2}, 3)
+		qc.MCU([]int{1, 3}, sim.Rx(0.8), []int{0})
+		if err := qc.Unitary(sim.Swap, []int{2, 0}, "my swap"); err != nil {
+			t.Fatal(err)
+		}
+
+		src, err := qc.ToQuil()
+		if err != nil {
+			t.Fatalf("ToQuil() error = %v", err)
+		}
+		got, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse() error = %v in\n%v", err, src)
+		}
+		if !sim.Equivalent(got, qc, 1e-9) {
+			t.Errorf("Parse(ToQuil()) is not equivalent to the circuit:\n%v", src)
+		}
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		qc := sim.NewQuantumCircuit(3)
+		qc.X(0)
+		qc.Measure(0, 0)
+		qc.Measure(1, 1)
+		qc.IfRegister([]int{0, 1}, 1).X(1)
+		qc.IfRegister([]int{0, 1}, 3).X(2)
+		qc.Measure(1, 2)
+
+		src, err := qc.ToQuil()
+		if err != nil {
+			t.Fatalf("ToQuil() error = %v", err)
+		}
+		got, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse() error = %v in\n%v", err, src)
+		}
+		kets := []sim.Ket{sim.ZeroKet, sim.ZeroKet, sim.ZeroKet}
+		if got, want := got.Exec(kets).MeasureProbabilities(), qc.Exec(kets).MeasureProbabilities(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(ToQuil()) gives probabilities %v, want %v", got, want)
+		}
+	})
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		line, col int
+		msg       string
+	}{
+		{name: "Undefined gate", src: "H 0\nFOO 1", line: 2, col: 1, msg: "undefined gate FOO"},
+		{name: "Wrong qubit count", src: "CNOT 0", line: 1, col: 1, msg: "takes 2 qubits"},
+		{name: "Repeated qubit", src: "CNOT 1 1", line: 1, col: 8, msg: "used twice"},
+		{name: "Complex angle", src: "RX(1+2i) 0", line: 1, col: 1, msg: "not real"},
+		{name: "Classical arithmetic", src: "DECLARE ro BIT\nADD ro 1", line: 2, col: 1, msg: "ADD is not supported"},
+		{name: "Memory type", src: "DECLARE theta REAL[2]", line: 1, col: 15, msg: "only BIT"},
+		{name: "Undeclared memory", src: "MEASURE 0 ro[0]", line: 1, col: 11, msg: "undeclared memory ro"},
+		{name: "Out of range", src: "DECLARE ro BIT[1]\nMEASURE 0 ro[1]", line: 2, col: 11, msg: "out of range"},
+		{name: "Backward jump", src: "DECLARE ro BIT\nLABEL @top\nJUMP-WHEN @top ro", line: 3, col: 1, msg: "jumping back"},
+		{name: "Missing label", src: "DECLARE ro BIT\nJUMP-WHEN @end ro\nX 0", line: 2, col: 1, msg: "never defined"},
+		{name: "Not unitary", src: "DEFGATE A:\n    1, 1\n    0, 1\nA 0", line: 4, col: 1, msg: "not unitary"},
+		{name: "Not unitary with modifiers", src: "DEFGATE A:\n    1, 1\n    0, 1\nX 1\nCONTROLLED A 1 0", line: 5, col: 1, msg: "not unitary"},
+		{name: "Not square", src: "DEFGATE A:\n    1, 0\n    0", line: 1, col: 9, msg: "not square"},
+		{name: "Permutation", src: "DEFGATE A AS PERMUTATION:\n    1, 0", line: 1, col: 14, msg: "only MATRIX"},
+		{name: "Trailing tokens", src: "H 0 )", line: 1, col: 5, msg: "expected end of line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Parse() error = %v, want an *Error", err)
+			}
+			if e.Line != tt.line || e.Col != tt.col || !strings.Contains(e.Msg, tt.msg) {
+				t.Errorf("Parse() error = %v, want %v:%v containing %q", e, tt.line, tt.col, tt.msg)
+			}
+		})
+	}
+}
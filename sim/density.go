@@ -0,0 +1,165 @@
+package sim
+
+// Register state a circuit can be run on, one shot at a time.
+// u is a uniform random number in [0, 1) for operations with random outcomes.
+type register interface {
+	apply(g *Gate)
+	measure(qubit int, u float64) int
+	reset(qubit int, u float64)
+	channel(ops []Matrix, targets []int, u float64)
+}
+
+// Pure register state held as a vector of 2^n amplitudes
+type statevector struct {
+	state     ColVec
+	numQubits int
+}
+
+func (sv *statevector) apply(g *Gate) {
+	applyGate(sv.state, sv.numQubits, g)
+}
+
+func (sv *statevector) measure(qubit int, u float64) int {
+	return measureQubit(sv.state, sv.numQubits, qubit, u)
+}
+
+func (sv *statevector) reset(qubit int, u float64) {
+	resetQubit(sv.state, sv.numQubits, qubit, u)
+}
+
+// Noise on a pure state follows a single stochastic trajectory
+func (sv *statevector) channel(ops []Matrix, targets []int, u float64) {
+	applyKrausTrajectory(sv.state, sv.numQubits, ops, targets, u)
+}
+
+// Mixed register state held as a 2^n x 2^n density matrix rho.
+// Its entries rho[r][c] are laid out as the amplitudes of a 2n-qubit state vector,
+// the row qubits followed by the column qubits, so U rho U† is computed by the
+// statevector engine as U on the row qubits and the conjugate of U on the column qubits.
+type densityMatrix struct {
+	rho       Matrix
+	numQubits int
+}
+
+// Creates the density matrix |psi><psi| of a pure state
+func newDensityMatrix(psi ColVec, numQubits int) *densityMatrix {
+	return &densityMatrix{
+		rho:       *Matrix(psi).Mul(*Matrix(psi).Dagger()),
+		numQubits: numQubits,
+	}
+}
+
+// The density matrix viewed as a state vector of twice as many qubits
+func (dm *densityMatrix) vec() ColVec {
+	return NewColVec(dm.rho)
+}
+
+func (dm *densityMatrix) apply(g *Gate) {
+	rows, cols := g.onDensityMatrix(dm.numQubits)
+	applyGate(dm.vec(), 2*dm.numQubits, &rows)
+	applyGate(dm.vec(), 2*dm.numQubits, &cols)
+}
+
+// Measures the qubit in the standard basis, keeping only the block of rho
+// consistent with the outcome and renormalizing it.
+func (dm *densityMatrix) measure(qubit int, u float64) int {
+	mask := qubitMask(qubit, dm.numQubits)
+	size := dm.rho.Rows
+
+	probOn := 0.0
+	for i := 0; i < size; i++ {
+		if i&mask != 0 {
+			probOn += real(dm.rho.Data[i*dm.rho.Stride+i])
+		}
+	}
+
+	outcome, prob := 0, 1-probOn
+	if u < probOn {
+		outcome, prob = 1, probOn
+	}
+
+	for r := 0; r < size; r++ {
+		for c := 0; c < size; c++ {
+			keep := (r&mask != 0) == (outcome == 1) && (c&mask != 0) == (outcome == 1)
+			if keep {
+				dm.rho.Data[r*dm.rho.Stride+c] /= complex(prob, 0)
+			} else {
+				dm.rho.Data[r*dm.rho.Stride+c] = 0
+			}
+		}
+	}
+
+	return outcome
+}
+
+// Resets the qubit to |0> without sampling, by applying the channel with
+// Kraus operators |0><0| and |0><1|
+func (dm *densityMatrix) reset(qubit int, u float64) {
+	dm.applyKraus(resetKraus, []int{qubit})
+}
+
+func (dm *densityMatrix) channel(ops []Matrix, targets []int, u float64) {
+	dm.applyKraus(ops, targets)
+}
+
+// Kraus operators of the channel resetting a qubit to |0>
+var resetKraus = []Matrix{
+	{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{1, 0, 0, 0}},
+	{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{0, 1, 0, 0}},
+}
+
+// Applies the channel rho -> sum K rho K† over the Kraus operators K,
+// each acting jointly on the target qubits
+func (dm *densityMatrix) applyKraus(ops []Matrix, targets []int) {
+	sum := make([]complex128, len(dm.rho.Data))
+	term := &densityMatrix{
+		rho: Matrix{
+			Rows:   dm.rho.Rows,
+			Cols:   dm.rho.Cols,
+			Stride: dm.rho.Stride,
+			Data:   make([]complex128, len(dm.rho.Data)),
+		},
+		numQubits: dm.numQubits,
+	}
+
+	for _, k := range ops {
+		copy(term.rho.Data, dm.rho.Data)
+		term.apply(&Gate{Matrix: k, targets: targets})
+		for i, v := range term.rho.Data {
+			sum[i] += v
+		}
+	}
+
+	copy(dm.rho.Data, sum)
+}
+
+// Splits a gate into the two gates that apply it to a density matrix viewed as a
+// state vector: the gate itself on the row qubits and its conjugate on the column qubits
+func (g *Gate) onDensityMatrix(numQubits int) (Gate, Gate) {
+	rows := *g
+	if rows.targets == nil {
+		rows.targets = make([]int, numQubits)
+		for i := range rows.targets {
+			rows.targets[i] = i
+		}
+	}
+
+	shift := func(qubits []int) []int {
+		if qubits == nil {
+			return nil
+		}
+		out := make([]int, len(qubits))
+		for i, q := range qubits {
+			out[i] = q + numQubits
+		}
+		return out
+	}
+
+	cols := rows
+	cols.Matrix = *rows.Matrix.Conj()
+	cols.targets = shift(rows.targets)
+	cols.controls = shift(rows.controls)
+	cols.negControls = shift(rows.negControls)
+
+	return rows, cols
+}
@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQuantumCircuit_ExecDensityMatrix(t *testing.T) {
+	t.Run("Matches the state vector for unitary circuits", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 2})
+		qc.CX(0, 1)
+		qc.RY(0.4, 1)
+		qc.CCX(0, 2, 1)
+		qc.S(2)
+		qc.CP(0.9, 2, 0)
+		qc.ISWAP(1, 2)
+		in := []Ket{ZeroKet, OneKet, HMinusKet}
+
+		pure := qc.Exec(in)
+		mixed := qc.Exec(in, WithDensityMatrix())
+
+		if got, want := mixed.DensityMatrix(), pure.DensityMatrix(); !got.Equals(want, StdEpsilon) {
+			t.Errorf("DensityMatrix() = %v, want %v", got, want)
+		}
+		if got, want := mixed.MeasureProbabilities(), pure.MeasureProbabilities(); !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+		for q := 0; q < 3; q++ {
+			if got, want := mixed.MeasureProbabilityOn(q), pure.MeasureProbabilityOn(q); !floatEqual(got, want, FloatEpsilon) {
+				t.Errorf("MeasureProbabilityOn(%v) = %v, want %v", q, got, want)
+			}
+		}
+		basis := []Ket{OneKet, ZeroKet, OneKet}
+		if got, want := mixed.MeasureProbability(basis), pure.MeasureProbability(basis); !floatEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbability() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Whole register gate", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		inner := NewQuantumCircuit(2)
+		inner.H([]int{0})
+		inner.CY(0, 1)
+		addCompiled(&qc, inner)
+
+		got := qc.Exec([]Ket{ZeroKet, ZeroKet}, WithDensityMatrix()).DensityMatrix()
+		want := qc.Exec([]Ket{ZeroKet, ZeroKet}).DensityMatrix()
+		if !got.Equals(want, StdEpsilon) {
+			t.Errorf("DensityMatrix() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Resetting half a Bell pair leaves the other half mixed", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.CX(0, 1)
+		qc.Reset(1)
+
+		got := qc.Exec([]Ket{ZeroKet, ZeroKet}, WithDensityMatrix()).DensityMatrix()
+		want := Matrix{
+			Rows:   4,
+			Cols:   4,
+			Stride: 4,
+			Data: []complex128{
+				0.5, 0, 0, 0,
+				0, 0, 0, 0,
+				0, 0, 0.5, 0,
+				0, 0, 0, 0,
+			},
+		}
+		if !got.Equals(want, StdEpsilon) {
+			t.Errorf("DensityMatrix() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Measurement collapses rho and drives conditions", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.Measure(0, 0)
+		qc.If(0, 1).X(1)
+
+		rng := rand.New(rand.NewSource(2))
+		for shot := 0; shot < 20; shot++ {
+			qce := qc.Exec([]Ket{ZeroKet, ZeroKet}, WithDensityMatrix(), WithRand(rng))
+			c := float64(qce.Clbits()[0])
+			want := []float64{1 - c, 0, 0, c}
+			if got := qce.MeasureProbabilities(); !floatsEqual(got, want, FloatEpsilon) {
+				t.Fatalf("MeasureProbabilities() = %v with clbit %v, want %v", got, c, want)
+			}
+		}
+	})
+
+	t.Run("Sampling a mixed state", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.CX(0, 1)
+		qc.Reset(1)
+
+		counts := qc.Exec([]Ket{ZeroKet, ZeroKet}, WithDensityMatrix()).Sample(4000, rand.New(rand.NewSource(1)))
+		if counts["00"]+counts["10"] != 4000 || math.Abs(float64(counts["00"])/4000-0.5) > 0.05 {
+			t.Errorf("Sample() = %v, want 00 and 10 about equally often", counts)
+		}
+	})
+}
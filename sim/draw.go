@@ -0,0 +1,258 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Labels of gates drawn as a box, as applied to their targets without controls
+var drawLabels = map[GateName]string{
+	HADAMARD: "H", CH: "H", PAULIY: "Y", CY: "Y", PAULIZ: "Z", CZ: "Z",
+	SGATE: "S", SDAGGER: "S†", TGATE: "T", TDAGGER: "T†",
+	ROTX: "RX", CROTX: "RX", ROTY: "RY", CROTY: "RY", ROTZ: "RZ", CROTZ: "RZ",
+	PHASE: "P", CPHASE: "P", U3: "U", ISWAP: "iSWAP", MCU: "U", RESET: "|0⟩",
+	DEPOLARIZING: "Depol", AMPLITUDEDAMPING: "AmpDamp", PHASEDAMPING: "PhaseDamp",
+	BITFLIP: "BitFlip", PHASEFLIP: "PhaseFlip", KRAUS: "Kraus",
+}
+
+// Gate as drawn in one column: the symbol on each qubit it touches, joined by
+// a vertical connector from its first to its last row
+type drawnGate struct {
+	symbols map[int]string
+	first   int
+	last    int
+	clbits  []int // Classical bits read or written, which order it against other gates
+}
+
+// Draws the circuit as text, with one wire per qubit running left to right.
+// Single-qubit gates are boxes such as ┤H├, controls are ● (○ for negative controls),
+// X targets are ⊕, SWAP targets are × and multi-qubit gates are joined by vertical
+// connectors. Gates that share no qubits or classical bits are packed into the same column.
+// Measurements are labelled with the classical bit they write, and conditioned gates
+// with the condition they need, e.g. X if c0=1.
+func (qc *QuantumCircuit) Draw() string {
+	if qc.numQubits == 0 {
+		return ""
+	}
+
+	// Column of each gate: the first one after every earlier gate it overlaps
+	var columns [][]drawnGate
+	nextQubit := make([]int, qc.numQubits)
+	nextClbit := make([]int, qc.numClbits)
+	for i := range qc.gates {
+		for _, d := range drawGate(&qc.gates[i], qc.numQubits) {
+			col := 0
+			for q := d.first; q <= d.last; q++ {
+				col = maxInt(col, nextQubit[q])
+			}
+			for _, c := range d.clbits {
+				col = maxInt(col, nextClbit[c])
+			}
+			for q := d.first; q <= d.last; q++ {
+				nextQubit[q] = col + 1
+			}
+			for _, c := range d.clbits {
+				nextClbit[c] = col + 1
+			}
+			if col == len(columns) {
+				columns = append(columns, nil)
+			}
+			columns[col] = append(columns[col], d)
+		}
+	}
+
+	prefixes := make([]string, qc.numQubits)
+	prefixWidth := 0
+	for q := range prefixes {
+		prefixes[q] = fmt.Sprintf("q%v: ", q)
+		prefixWidth = maxInt(prefixWidth, len(prefixes[q]))
+	}
+
+	// Wire rows for the qubits, with rows for connectors between them
+	wires := make([]strings.Builder, qc.numQubits)
+	gaps := make([]strings.Builder, qc.numQubits-1)
+	for q := range wires {
+		wires[q].WriteString(prefixes[q] + strings.Repeat(" ", prefixWidth-len(prefixes[q])) + "─")
+	}
+	for q := range gaps {
+		gaps[q].WriteString(strings.Repeat(" ", prefixWidth+1))
+	}
+
+	for _, column := range columns {
+		width := 1
+		for _, d := range column {
+			for _, s := range d.symbols {
+				width = maxInt(width, utf8.RuneCountInString(s))
+			}
+		}
+		center := (width - 1) / 2
+
+		symbols := map[int]string{}
+		crossed := map[int]bool{}
+		for _, d := range column {
+			for q, s := range d.symbols {
+				symbols[q] = s
+			}
+			for q := d.first; q < d.last; q++ {
+				crossed[q] = true
+			}
+		}
+
+		for q := range wires {
+			s, ok := symbols[q]
+			if !ok && crossed[q] && q > 0 && crossed[q-1] {
+				s, ok = "┼", true
+			}
+			if !ok {
+				s = "─"
+			}
+			start := (width - utf8.RuneCountInString(s)) / 2
+			if utf8.RuneCountInString(s) == 1 {
+				start = center
+			}
+			wires[q].WriteString("─" + strings.Repeat("─", start) + s +
+				strings.Repeat("─", width-start-utf8.RuneCountInString(s)) + "─")
+		}
+		for q := range gaps {
+			if crossed[q] {
+				gaps[q].WriteString(" " + strings.Repeat(" ", center) + "│" + strings.Repeat(" ", width-center-1) + " ")
+			} else {
+				gaps[q].WriteString(strings.Repeat(" ", width+2))
+			}
+		}
+	}
+
+	var b strings.Builder
+	for q := range wires {
+		b.WriteString(wires[q].String() + "\n")
+		if q < len(gaps) {
+			b.WriteString(strings.TrimRight(gaps[q].String(), " ") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Splits a gate into the parts drawn in a column. A single-qubit operator applied
+// to several targets, like H on many qubits, is drawn as a box on each of them.
+func drawGate(g *Gate, numQubits int) []drawnGate {
+	if g.name == WIRE && g.targets == nil {
+		return nil
+	}
+
+	label := drawLabel(g)
+	var clbits []int
+	if g.cond != nil {
+		clbits = append(clbits, g.cond.clbits...)
+		label += " if " + drawCondition(g.cond)
+	}
+	clbits = append(clbits, g.clbits...)
+
+	targets := g.targets
+	if targets == nil {
+		targets = make([]int, numQubits)
+		for q := range targets {
+			targets[q] = q
+		}
+	}
+	if g.kraus == nil && g.targets != nil && g.Rows == 2 && len(g.targets) > 1 && len(g.Qubits()) == len(g.targets) {
+		var out []drawnGate
+		for _, t := range g.targets {
+			out = append(out, drawnGate{symbols: map[int]string{t: "┤" + label + "├"}, first: t, last: t, clbits: clbits})
+		}
+		return out
+	}
+
+	d := drawnGate{symbols: map[int]string{}, first: numQubits, last: -1, clbits: clbits}
+	for _, q := range g.controls {
+		d.symbols[q] = "●"
+	}
+	for _, q := range g.negControls {
+		d.symbols[q] = "○"
+	}
+	for i, t := range targets {
+		switch {
+		case (g.name == CUSTOM || g.name == MCU) && len(targets) > 1:
+			// The operator acts jointly on its targets, the first most significant
+			d.symbols[t] = fmt.Sprintf("┤%v:%v├", label, i)
+		case g.cond == nil && (g.name == PAULIX || g.name == CX || g.name == CCX || g.name == MCX):
+			d.symbols[t] = "⊕"
+		case g.cond == nil && (g.name == SWAP || g.name == CSWAP):
+			d.symbols[t] = "×"
+		default:
+			d.symbols[t] = "┤" + label + "├"
+		}
+	}
+	for q := range d.symbols {
+		d.first = minInt(d.first, q)
+		d.last = maxInt(d.last, q)
+	}
+	return []drawnGate{d}
+}
+
+// Text in the box of a gate: its symbol, followed by its angles
+func drawLabel(g *Gate) string {
+	switch g.name {
+	case PAULIX, CX, CCX, MCX:
+		return "X"
+	case SWAP, CSWAP:
+		return "SWAP"
+	case MEASURE:
+		return fmt.Sprintf("M→c%v", g.clbits[0])
+	case CUSTOM:
+		if g.label != "" {
+			return g.label
+		}
+	}
+	label, ok := drawLabels[g.name]
+	if !ok || g.name == KRAUS && g.label != "" {
+		label = g.Name()
+	}
+	if len(g.params) > 0 {
+		angles := make([]string, len(g.params))
+		for i, p := range g.params {
+			angles[i] = drawAngle(p)
+		}
+		label += "(" + strings.Join(angles, ",") + ")"
+	}
+	return label
+}
+
+// Formats an angle briefly, as a fraction of π where it is one
+func drawAngle(x float64) string {
+	if s := formatAngle(x); strings.Contains(s, "pi") {
+		return strings.Replace(strings.Replace(s, "*pi", "π", 1), "pi", "π", 1)
+	}
+	if x == math.Trunc(x) {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(x, 'g', 4, 64)
+}
+
+// Formats a condition as c0=1 for a single bit, or as c[0,1]=2 for several
+func drawCondition(c *condition) string {
+	if len(c.clbits) == 1 {
+		return fmt.Sprintf("c%v=%v", c.clbits[0], c.value)
+	}
+	bits := make([]string, len(c.clbits))
+	for i, b := range c.clbits {
+		bits[i] = strconv.Itoa(b)
+	}
+	return fmt.Sprintf("c[%v]=%v", strings.Join(bits, ","), c.value)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
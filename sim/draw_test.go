@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantumCircuit_Draw(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() QuantumCircuit
+		want  string
+	}{
+		{"Bell state", func() QuantumCircuit {
+			qc := NewQuantumCircuit(2)
+			qc.H([]int{0})
+			qc.CX(0, 1)
+			return qc
+		}, `q0: ──┤H├──●─
+           │
+q1: ───────⊕─
+`},
+		{"Packed columns", func() QuantumCircuit {
+			qc := NewQuantumCircuit(3)
+			qc.H([]int{0, 1, 2})
+			qc.RZ(math.Pi/4, 0)
+			qc.CZ(1, 2)
+			qc.Y(0)
+			return qc
+		}, `q0: ──┤H├──┤RZ(π/4)├──┤Y├─
+
+q1: ──┤H├──────●──────────
+               │
+q2: ──┤H├─────┤Z├─────────
+`},
+		{"Connectors cross idle wires", func() QuantumCircuit {
+			qc := NewQuantumCircuit(3)
+			qc.MCX([]int{NegControl(2)}, 0)
+			qc.CSWAP(1, 0, 2)
+			qc.CP(0.5, 0, 2)
+			return qc
+		}, `q0: ──⊕──×─────●─────
+      │  │     │
+q1: ──┼──●─────┼─────
+      │  │     │
+q2: ──○──×──┤P(0.5)├─
+`},
+		{"Measurements and conditions", func() QuantumCircuit {
+			qc := NewQuantumCircuit(2)
+			qc.Measure(0, 0)
+			qc.If(0, 1).X(1)
+			qc.Sdg(0)
+			qc.Reset(0)
+			return qc
+		}, `q0: ──┤M→c0├─────┤S†├──────┤|0⟩├─
+
+q1: ──────────┤X if c0=1├────────
+`},
+		{"Multi-qubit boxes", func() QuantumCircuit {
+			qc := NewQuantumCircuit(3)
+			qc.Unitary(Swap, []int{2, 0}, "sw")
+			qc.ISWAP(1, 2)
+			qc.BitFlip(0.1, 0)
+			return qc
+		}, `q0: ──┤sw:1├──┤BitFlip(0.1)├─
+        │
+q1: ────┼────────┤iSWAP├─────
+        │           │
+q2: ──┤sw:0├─────┤iSWAP├─────
+`},
+		{"Empty circuit", func() QuantumCircuit { return NewQuantumCircuit(2) }, `q0: ─
+
+q1: ─
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := tt.build()
+			if got := qc.Draw(); got != tt.want {
+				t.Errorf("Draw() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package sim
 
 import (
+	"fmt"
 	"math"
+	"math/cmplx"
 	"sort"
 )
 
@@ -12,15 +14,227 @@ const (
 	WIRE     = iota
 	PAULIX   = iota
 	CX       = iota
+	PAULIY   = iota
+	PAULIZ   = iota
+	SGATE    = iota
+	SDAGGER  = iota
+	TGATE    = iota
+	TDAGGER  = iota
+	ROTX     = iota
+	ROTY     = iota
+	ROTZ     = iota
+	PHASE    = iota
+	U3       = iota
+	CZ       = iota
+	CY       = iota
+	CH       = iota
+	SWAP     = iota
+	ISWAP    = iota
+	CPHASE   = iota
+	CROTX    = iota
+	CROTY    = iota
+	CROTZ    = iota
+	CCX      = iota
+	CSWAP    = iota
+	MCX      = iota
+	MCU      = iota
+	CUSTOM   = iota
+	MEASURE  = iota
+	RESET    = iota
+
+	// Noise channels
+	DEPOLARIZING     = iota
+	AMPLITUDEDAMPING = iota
+	PHASEDAMPING     = iota
+	BITFLIP          = iota
+	PHASEFLIP        = iota
+	KRAUS            = iota
 )
 
+// Tolerance used when checking that a user-supplied matrix is unitary
+const unitaryTolerance = 1e-8
+
 func (g *Gate) Name() string {
-	return [...]string{"Hadamard", "Identity", "Pauli-X", "C-X"}[g.name]
+	if (g.name == CUSTOM || g.name == KRAUS) && g.label != "" {
+		return g.label
+	}
+	names := [...]string{"Hadamard", "Identity", "Pauli-X", "C-X", "Pauli-Y", "Pauli-Z", "S", "S-dagger", "T", "T-dagger",
+		"R-X", "R-Y", "R-Z", "Phase", "U3",
+		"C-Z", "C-Y", "C-H", "SWAP", "iSWAP", "C-Phase", "C-R-X", "C-R-Y", "C-R-Z",
+		"Toffoli", "Fredkin", "Multi-C-X", "Multi-C-U", "Custom",
+		"Measure", "Reset",
+		"Depolarizing", "Amplitude damping", "Phase damping", "Bit flip", "Phase flip", "Kraus"}
+
+	// Gates combined from others, such as compiled circuits, may carry any name
+	if g.name < 0 || int(g.name) >= len(names) {
+		return fmt.Sprintf("Gate %v", g.name)
+	}
+	return names[g.name]
 }
 
+// A gate stores only the small operator it applies, together with the qubits
+// it applies to. A single-qubit operator given several targets is applied to
+// each of them; a larger operator acts jointly on its targets, the first target
+// being the most significant. The operator only fires on basis states where all
+// the control qubits are ON and all the negative control qubits are OFF.
+// A gate without targets acts on the whole register.
 type Gate struct {
 	Matrix
-	name GateName
+	name        GateName
+	label       string
+	params      []float64
+	targets     []int
+	controls    []int
+	negControls []int
+	clbits      []int
+	cond        *condition
+	kraus       []Matrix // Set instead of the operator for noise channels
+}
+
+// Classical condition on a gate: the gate only fires when the classical bits,
+// read as a binary number with the first bit least significant, equal value
+type condition struct {
+	clbits []int
+	value  int
+}
+
+// Does the classical register satisfy the condition?
+func (c *condition) holds(clbits []int) bool {
+	v := 0
+	for i, b := range c.clbits {
+		v |= clbits[b] << uint(i)
+	}
+	return v == c.value
+}
+
+// Combines two conditions into one that holds only when both do
+func (c *condition) and(other *condition) *condition {
+	if other == nil {
+		return c
+	}
+	return &condition{
+		clbits: append(append([]int{}, c.clbits...), other.clbits...),
+		value:  c.value | other.value<<uint(len(c.clbits)),
+	}
+}
+
+// Classical bits and value the gate is conditioned on, with the first bit
+// least significant. Returns no bits if the gate always fires.
+func (g *Gate) Condition() ([]int, int) {
+	if g.cond == nil {
+		return nil, 0
+	}
+	return g.cond.clbits, g.cond.value
+}
+
+// Angles the gate was built from, empty for gates without parameters
+func (g *Gate) Params() []float64 {
+	return g.params
+}
+
+// Qubits the gate's operator is applied to
+func (g *Gate) Targets() []int {
+	return g.targets
+}
+
+// Qubits that must be ON for the gate to fire
+func (g *Gate) Controls() []int {
+	return g.controls
+}
+
+// Classical bits a measurement writes its outcomes to
+func (g *Gate) Clbits() []int {
+	return g.clbits
+}
+
+// Is the gate a unitary operation, rather than a measurement, reset, noise
+// channel or gate conditioned on classical bits?
+func (g *Gate) IsUnitary() bool {
+	return g.name != MEASURE && g.name != RESET && g.kraus == nil && g.cond == nil
+}
+
+// Kraus operators of a noise channel, empty for other gates
+func (g *Gate) Kraus() []Matrix {
+	return g.kraus
+}
+
+// Qubits that must be OFF for the gate to fire
+func (g *Gate) NegControls() []int {
+	return g.negControls
+}
+
+// Offset NegControl adds to a qubit. It puts marked qubits far below zero, so that
+// a mistyped negative index such as -3 is rejected rather than read as a negative control.
+const negControlOffset = math.MinInt32
+
+// Marks a qubit as a negative control when passed in a list of controls,
+// so the gate fires when that qubit is |0> rather than |1>.
+func NegControl(qubit int) int {
+	if qubit < 0 || qubit >= -(negControlOffset/2) {
+		panic(fmt.Sprintf("Cannot mark qubit %v as a negative control", qubit))
+	}
+	return negControlOffset + qubit
+}
+
+// Qubit a control refers to, and whether it was marked by NegControl.
+// Panics for negative controls that NegControl did not produce.
+func controlQubit(c int) (int, bool) {
+	if c >= 0 {
+		return c, false
+	}
+	q := c - negControlOffset
+	if q < 0 || q >= -(negControlOffset/2) {
+		panic(fmt.Sprintf("Control qubit %v is negative; wrap negative controls in NegControl", c))
+	}
+	return q, true
+}
+
+// All qubits the gate touches: its controls, then negative controls, then targets
+func (g *Gate) Qubits() []int {
+	qubits := append([]int{}, g.controls...)
+	qubits = append(qubits, g.negControls...)
+	return append(qubits, g.targets...)
+}
+
+// Expands the gate to the full 2^n x 2^n operator it applies to a register of n qubits.
+// Panics for non-unitary gates, which have no such operator.
+func (g *Gate) Expand(numQubits int) Matrix {
+	if !g.IsUnitary() {
+		panic(fmt.Sprintf("Cannot expand non-unitary %v operation to a matrix", g.Name()))
+	}
+	if g.targets == nil {
+		return g.Matrix
+	}
+
+	size := 1 << uint(numQubits)
+	out := Matrix{
+		Rows:   size,
+		Cols:   size,
+		Stride: size,
+		Data:   make([]complex128, size*size),
+	}
+
+	// Column j of the operator is the gate applied to the jth basis state
+	col := NewColVec(Matrix{
+		Rows:   size,
+		Cols:   1,
+		Stride: 1,
+		Data:   make([]complex128, size),
+	})
+	for j := 0; j < size; j++ {
+		for i := range col.Data {
+			col.Data[i] = 0
+		}
+		col.Data[j] = 1
+
+		applyGate(col, numQubits, g)
+
+		for i := 0; i < size; i++ {
+			out.Data[i*size+j] = col.Data[i]
+		}
+	}
+
+	return out
 }
 
 var (
@@ -88,98 +302,276 @@ var (
 		Stride: 2,
 		Data:   []complex128{0, 1, 1, 0},
 	})
+
+	Y = Matrix(Matrix{ // Pauli-Y Matrix
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{0, -1i, 1i, 0},
+	})
+
+	Z = Matrix(Matrix{ // Pauli-Z Matrix
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 0, 0, -1},
+	})
+
+	S = Matrix(Matrix{ // Phase Matrix, square root of Z
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 0, 0, 1i},
+	})
+
+	Sdg = Matrix(Matrix{ // Conjugate transpose of S
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 0, 0, -1i},
+	})
+
+	T = Matrix(Matrix{ // pi/8 Matrix, square root of S
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 0, 0, complex(1/math.Sqrt2, 1/math.Sqrt2)},
+	})
+
+	Tdg = Matrix(Matrix{ // Conjugate transpose of T
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 0, 0, complex(1/math.Sqrt2, -1/math.Sqrt2)},
+	})
+
+	Swap = Matrix(Matrix{ // Exchanges the states of two qubits
+		Rows:   4,
+		Cols:   4,
+		Stride: 4,
+		Data: []complex128{
+			1, 0, 0, 0,
+			0, 0, 1, 0,
+			0, 1, 0, 0,
+			0, 0, 0, 1,
+		},
+	})
+
+	ISwap = Matrix(Matrix{ // Exchanges the states of two qubits, adding a phase of i to |01> and |10>
+		Rows:   4,
+		Cols:   4,
+		Stride: 4,
+		Data: []complex128{
+			1, 0, 0, 0,
+			0, 0, 1i, 0,
+			0, 1i, 0, 0,
+			0, 0, 0, 1,
+		},
+	})
 )
 
-// Creates a multi-qubit Hadamard gate across the qubits specified here
-func createH(qubits []int, numQubits int) *Gate {
-	sort.Ints(qubits)
+// Rotation matrix about the X axis of the Bloch sphere by theta
+func Rx(theta float64) Matrix {
+	c, s := math.Cos(theta/2), math.Sin(theta/2)
+	return Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{complex(c, 0), complex(0, -s), complex(0, -s), complex(c, 0)},
+	}
+}
 
-	mat := Matrix{
-		Rows:   1,
-		Cols:   1,
-		Stride: 1,
-		Data:   []complex128{1},
+// Rotation matrix about the Y axis of the Bloch sphere by theta
+func Ry(theta float64) Matrix {
+	c, s := math.Cos(theta/2), math.Sin(theta/2)
+	return Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{complex(c, 0), complex(-s, 0), complex(s, 0), complex(c, 0)},
 	}
+}
 
-	qubitCount := 0
-	for i := 0; i < numQubits; i++ {
-		if i == qubits[qubitCount] {
-			mat = *mat.Kronecker(H)
+// Rotation matrix about the Z axis of the Bloch sphere by theta
+func Rz(theta float64) Matrix {
+	return Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{cmplx.Exp(complex(0, -theta/2)), 0, 0, cmplx.Exp(complex(0, theta/2))},
+	}
+}
 
-			if qubitCount < len(qubits)-1 {
-				qubitCount++
-			}
-		} else {
-			mat = *mat.Kronecker(I)
-		}
+// Phase matrix, which leaves |0> alone and multiplies |1> by e^(i*lambda)
+func Phase(lambda float64) Matrix {
+	return Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 0, 0, cmplx.Exp(complex(0, lambda))},
+	}
+}
+
+// General single-qubit matrix given by its three Euler angles,
+// using the same convention as OpenQASM's U gate
+func U(theta, phi, lambda float64) Matrix {
+	c, s := complex(math.Cos(theta/2), 0), complex(math.Sin(theta/2), 0)
+	return Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data: []complex128{
+			c, -cmplx.Exp(complex(0, lambda)) * s,
+			cmplx.Exp(complex(0, phi)) * s, cmplx.Exp(complex(0, phi+lambda)) * c,
+		},
 	}
+}
+
+// Creates a multi-qubit Hadamard gate across the qubits specified here
+func createH(qubits []int) *Gate {
+	targets := append([]int{}, qubits...)
+	sort.Ints(targets)
 
 	return &Gate{
-		Matrix: mat,
-		name:   HADAMARD,
+		Matrix:  H,
+		name:    HADAMARD,
+		targets: targets,
 	}
 }
 
 // Creates a single Pauli-X gate operating on the given qubit.
-// Will not fail if given multiple qubits to apply X gate on.
-func createX(qubit, numQubits int) *Gate {
-	mat := Matrix{
-		Rows:   1,
-		Cols:   1,
-		Stride: 1,
-		Data:   []complex128{1},
+func createX(qubit int) *Gate {
+	return createSingleQubit(X, PAULIX, qubit)
+}
+
+// Creates a gate applying the given 2x2 matrix to a single qubit.
+// Params are the angles the matrix was built from, if any.
+func createSingleQubit(mat Matrix, name GateName, qubit int, params ...float64) *Gate {
+	return &Gate{
+		Matrix:  mat,
+		name:    name,
+		params:  params,
+		targets: []int{qubit},
 	}
+}
 
-	for i := 0; i < numQubits; i++ {
-		if i == qubit {
-			mat = *mat.Kronecker(X)
+// Creates a controlled-X gate, given the number of the qubit that acts as the control
+// and the qubit that acts as the target.
+func createCX(control, target int) *Gate {
+	return createControlled(X, CX, []int{control}, []int{target})
+}
+
+// Creates a gate applying the matrix u to the target qubits only when the control
+// qubits are ON. With one control this is |0><0| ⊗ I + |1><1| ⊗ U: if the control
+// qubit is |0> the targets are left alone, and if it is |1> U is applied to them.
+// Controls wrapped in NegControl fire on |0> instead, swapping the two projectors.
+// Params are the angles u was built from, if any.
+// Reference: http://www.sakkaris.com/tutorials/quantum_control_gates.html
+func createControlled(u Matrix, name GateName, controls, targets []int, params ...float64) *Gate {
+	if u.Rows != u.Cols || u.Rows != 1<<uint(len(targets)) {
+		panic(fmt.Sprintf("Cannot apply %vx%v matrix to %v target qubits", u.Rows, u.Cols, len(targets)))
+	}
+
+	g := &Gate{
+		Matrix:  u,
+		name:    name,
+		params:  params,
+		targets: append([]int{}, targets...),
+	}
+	for _, c := range controls {
+		if q, neg := controlQubit(c); neg {
+			g.negControls = append(g.negControls, q)
 		} else {
-			mat = *mat.Kronecker(I)
+			g.controls = append(g.controls, q)
 		}
 	}
 
-	return &Gate{
-		Matrix: mat,
-		name:   PAULIX,
+	seen := map[int]bool{}
+	for _, q := range g.Qubits() {
+		if seen[q] {
+			panic(fmt.Sprintf("Qubit %v used more than once in gate", q))
+		}
+		seen[q] = true
 	}
+
+	return g
 }
 
-// Creates a controlled-X gate, given the number of the qubit that acts as the control,
-// the qubit that acts as the target, and the total number of qubits.
-// Reference: http://www.sakkaris.com/tutorials/quantum_control_gates.html
-func createCX(control, target, numQubits int) *Gate {
-	controlMatrix := Matrix{
-		Rows:   1,
-		Cols:   1,
-		Stride: 1,
-		Data:   []complex128{1},
+// Creates a gate applying a user-supplied matrix jointly to the given qubits,
+// the first of which is the most significant. Returns an error unless the matrix
+// is a square unitary of size 2^len(qubits) and the qubits are distinct and in range.
+func createCustom(m Matrix, qubits []int, label string, numQubits int) (*Gate, error) {
+	if m.Rows != m.Cols {
+		return nil, fmt.Errorf("matrix for gate %q is %vx%v, not square", label, m.Rows, m.Cols)
 	}
-	targetMatrix := Matrix{
-		Rows:   1,
-		Cols:   1,
-		Stride: 1,
-		Data:   []complex128{1},
-	}
-
-	// If the control qubit is |0> leave the target qubit alone
-	// If the control qubit is |1>, apply X to the target qubit
-	for i := 0; i < numQubits; i++ {
-		if i == control {
-			controlMatrix = *controlMatrix.Kronecker(*(Matrix)(ZeroKet).Mul((Matrix)(ZeroBra)))
-			targetMatrix = *targetMatrix.Kronecker(*(Matrix)(OneKet).Mul((Matrix)(OneBra)))
-		} else if i == target {
-			controlMatrix = *controlMatrix.Kronecker(I)
-			targetMatrix = *targetMatrix.Kronecker(X)
-		} else {
-			controlMatrix = *controlMatrix.Kronecker(I)
-			targetMatrix = *targetMatrix.Kronecker(I)
+	if len(qubits) == 0 || m.Rows != 1<<uint(len(qubits)) {
+		return nil, fmt.Errorf("matrix for gate %q is %vx%v, which does not act on %v qubits", label, m.Rows, m.Cols, len(qubits))
+	}
+
+	seen := map[int]bool{}
+	for _, q := range qubits {
+		if q < 0 || q >= numQubits {
+			return nil, fmt.Errorf("qubit %v of gate %q is out of range for %v qubits", q, label, numQubits)
+		}
+		if seen[q] {
+			return nil, fmt.Errorf("qubit %v used more than once in gate %q", q, label)
 		}
+		seen[q] = true
 	}
 
-	combinedMatrix := controlMatrix.Add(targetMatrix)
+	if !isUnitary(m, unitaryTolerance) {
+		return nil, fmt.Errorf("matrix for gate %q is not unitary", label)
+	}
+
+	return &Gate{
+		Matrix:  m,
+		name:    CUSTOM,
+		label:   label,
+		targets: append([]int{}, qubits...),
+	}, nil
+}
+
+// Is the matrix unitary, within the tolerance QuantumCircuit.Unitary checks custom gates against?
+func IsUnitary(m Matrix) bool {
+	return isUnitary(m, unitaryTolerance)
+}
+
+// Is the matrix unitary, i.e. does U†U equal the identity, within tolerance?
+func isUnitary(m Matrix, tolerance float64) bool {
+	if m.Rows != m.Cols {
+		return false
+	}
+	return m.Dagger().Mul(m).Equals(Identity(m.Rows), complex(tolerance, tolerance))
+}
+
+// Creates a measurement of the qubit in the standard basis, writing to classical bit cbit
+func createMeasure(qubit, cbit int) *Gate {
 	return &Gate{
-		Matrix: *combinedMatrix,
-		name:   CX,
+		name:    MEASURE,
+		targets: []int{qubit},
+		clbits:  []int{cbit},
+	}
+}
+
+// Creates a reset of the qubit to |0>
+func createReset(qubit int) *Gate {
+	return &Gate{
+		name:    RESET,
+		targets: []int{qubit},
+	}
+}
+
+// Creates a gate applying the given 4x4 matrix jointly to two qubits,
+// the first of which is the most significant
+func createTwoQubit(mat Matrix, name GateName, a, b int) *Gate {
+	if a == b {
+		panic(fmt.Sprintf("Two-qubit gate given qubit %v twice", a))
+	}
+
+	return &Gate{
+		Matrix:  mat,
+		name:    name,
+		targets: []int{a, b},
 	}
 }
 
@@ -205,11 +597,12 @@ func createWire(numQubits int) *Gate {
 	return &g
 }
 
-// Combines a set of gates into one using matrix multiplication
-func Combine(name GateName, matrices ...Gate) *Gate {
-	outMatrix := matrices[len(matrices)-1].Matrix
-	for i := len(matrices) - 2; i >= 0; i-- {
-		outMatrix = *outMatrix.Mul(matrices[i].Matrix)
+// Combines a set of gates into one using matrix multiplication.
+// Each gate is first expanded to act on the full register of numQubits qubits.
+func Combine(name GateName, numQubits int, gates ...Gate) *Gate {
+	outMatrix := gates[len(gates)-1].Expand(numQubits)
+	for i := len(gates) - 2; i >= 0; i-- {
+		outMatrix = *outMatrix.Mul(gates[i].Expand(numQubits))
 	}
 	return &Gate{
 		Matrix: outMatrix,
@@ -218,12 +611,37 @@ func Combine(name GateName, matrices ...Gate) *Gate {
 }
 
 // Determines equality between two gates, using epsilon as a complex number.
-// Two gates are Equals if their names are Equals and their matrix representations
+// Two gates are Equals if their names and qubits are Equals and their matrix representations
 // are also Equals, given a complex epsilon. Two complex numbers a+bi and c+di
 // are considered to be Equals if abs(a-c) < real(epsilon) and abs(b-d) < imag(epsilon).
 func (g *Gate) Equals(b *Gate, epsilon complex128) bool {
-	if g.name != b.name {
+	if g.name != b.name || !intsEqual(g.targets, b.targets) || !intsEqual(g.clbits, b.clbits) ||
+		!intsEqual(g.controls, b.controls) || !intsEqual(g.negControls, b.negControls) {
+		return false
+	}
+	if len(g.kraus) != len(b.kraus) {
+		return false
+	}
+	for i := range g.kraus {
+		if !g.kraus[i].Equals(b.kraus[i], epsilon) {
+			return false
+		}
+	}
+	if (g.cond == nil) != (b.cond == nil) ||
+		g.cond != nil && (g.cond.value != b.cond.value || !intsEqual(g.cond.clbits, b.cond.clbits)) {
 		return false
 	}
 	return g.Matrix.Equals(b.Matrix, epsilon)
 }
+
+func intsEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package sim
 
 import (
 	"math"
+	"math/cmplx"
 	"reflect"
 	"testing"
 )
@@ -44,6 +45,22 @@ func TestGate_Name(t *testing.T) {
 			},
 			want: "Identity",
 		},
+		{
+			name: "Pauli-Y Gate",
+			fields: fields{
+				Matrix: Y,
+				name:   PAULIY,
+			},
+			want: "Pauli-Y",
+		},
+		{
+			name: "T-dagger Gate",
+			fields: fields{
+				Matrix: Tdg,
+				name:   TDAGGER,
+			},
+			want: "T-dagger",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -58,6 +75,62 @@ func TestGate_Name(t *testing.T) {
 	}
 }
 
+func TestSingleQubitMatrices(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Matrix
+		want Matrix
+	}{
+		{name: "S S = Z", got: *S.Mul(S), want: Z},
+		{name: "T T = S", got: *T.Mul(T), want: S},
+		{name: "S Sdg = I", got: *S.Mul(Sdg), want: I},
+		{name: "T Tdg = I", got: *T.Mul(Tdg), want: I},
+		{name: "Y Y = I", got: *Y.Mul(Y), want: I},
+		{name: "Z X = iY", got: *Z.Mul(X), want: *(Matrix{Rows: 1, Cols: 1, Stride: 1, Data: []complex128{1i}}).Kronecker(Y)},
+		{name: "H Z H = X", got: *H.Mul(*Z.Mul(H)), want: X},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.got.Equals(tt.want, StdEpsilon) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotationMatrices(t *testing.T) {
+	scale := func(c complex128, m Matrix) Matrix {
+		return *(Matrix{Rows: 1, Cols: 1, Stride: 1, Data: []complex128{c}}).Kronecker(m)
+	}
+	tests := []struct {
+		name string
+		got  Matrix
+		want Matrix
+	}{
+		{name: "RX(pi) = -iX", got: Rx(math.Pi), want: scale(-1i, X)},
+		{name: "RY(pi) = -iY", got: Ry(math.Pi), want: scale(-1i, Y)},
+		{name: "RZ(pi) = -iZ", got: Rz(math.Pi), want: scale(-1i, Z)},
+		{name: "RX(0) = I", got: Rx(0), want: I},
+		{name: "RZ(2pi) = -I", got: Rz(2 * math.Pi), want: scale(-1, I)},
+		{name: "P(pi) = Z", got: Phase(math.Pi), want: Z},
+		{name: "P(pi/2) = S", got: Phase(math.Pi / 2), want: S},
+		{name: "P(pi/4) = T", got: Phase(math.Pi / 4), want: T},
+		{name: "U(pi/2, 0, pi) = H", got: U(math.Pi/2, 0, math.Pi), want: H},
+		{name: "U(pi, 0, pi) = X", got: U(math.Pi, 0, math.Pi), want: X},
+		{name: "U(theta, -pi/2, pi/2) = RX(theta)", got: U(0.3, -math.Pi/2, math.Pi/2), want: Rx(0.3)},
+		{name: "U(theta, 0, 0) = RY(theta)", got: U(1.1, 0, 0), want: Ry(1.1)},
+		{name: "U(0, 0, lambda) = P(lambda)", got: U(0, 0, 0.7), want: Phase(0.7)},
+		{name: "RZ(lambda) = P(lambda) up to phase", got: Rz(0.7), want: scale(cmplx.Exp(-0.35i), Phase(0.7))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.got.Equals(tt.want, StdEpsilon) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCreateH(t *testing.T) {
 	type args struct {
 		qubits    []int
@@ -176,7 +249,8 @@ func TestCreateH(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := createH(tt.args.qubits, tt.args.numQubits); !got.Equals(tt.want, StdEpsilon) {
+			got := createH(tt.args.qubits)
+			if got.name != tt.want.name || !got.Expand(tt.args.numQubits).Equals(tt.want.Matrix, StdEpsilon) {
 				t.Errorf("createH() = %v, want %v", got, tt.want)
 			}
 		})
@@ -545,7 +619,8 @@ func Test_createX(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := createX(tt.args.qubit, tt.args.numQubits); !got.Equals(tt.want, StdEpsilon) {
+			got := createX(tt.args.qubit)
+			if got.name != tt.want.name || !got.Expand(tt.args.numQubits).Equals(tt.want.Matrix, StdEpsilon) {
 				t.Errorf("createX() = %v, want %v", got, tt.want)
 			}
 		})
@@ -644,13 +719,123 @@ func Test_createCX(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := createCX(tt.args.control, tt.args.target, tt.args.numQubits); !got.Equals(tt.want, StdEpsilon) {
+			got := createCX(tt.args.control, tt.args.target)
+			if got.name != tt.want.name || !got.Expand(tt.args.numQubits).Equals(tt.want.Matrix, StdEpsilon) {
 				t.Errorf("createCX() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func Test_createControlled(t *testing.T) {
+	diag := func(d ...complex128) Matrix {
+		m := Identity(len(d))
+		for i := range d {
+			m.Data[i*len(d)+i] = d[i]
+		}
+		return m
+	}
+	tests := []struct {
+		name      string
+		gate      *Gate
+		numQubits int
+		want      Matrix
+	}{
+		{
+			name:      "CX matches the projector sum",
+			gate:      createControlled(X, CX, []int{0}, []int{1}),
+			numQubits: 2,
+			want: *Matrix(ZeroKet).Mul(Matrix(ZeroBra)).Kronecker(I).Add(
+				*Matrix(OneKet).Mul(Matrix(OneBra)).Kronecker(X)),
+		},
+		{
+			name:      "CZ is symmetric in its qubits",
+			gate:      createControlled(Z, CZ, []int{1}, []int{0}),
+			numQubits: 2,
+			want:      diag(1, 1, 1, -1),
+		},
+		{
+			name:      "Controlled phase skipping a qubit",
+			gate:      createControlled(Phase(math.Pi/2), CPHASE, []int{0}, []int{2}),
+			numQubits: 3,
+			want:      diag(1, 1, 1, 1, 1, 1i, 1, 1i),
+		},
+		{
+			name:      "Controlled H below its target",
+			gate:      createControlled(H, CH, []int{1}, []int{0}),
+			numQubits: 2,
+			want: Matrix{
+				Rows:   4,
+				Cols:   4,
+				Stride: 4,
+				Data: []complex128{
+					1, 0, 0, 0,
+					0, 1 / math.Sqrt2, 0, 1 / math.Sqrt2,
+					0, 0, 1, 0,
+					0, 1 / math.Sqrt2, 0, -1 / math.Sqrt2,
+				},
+			},
+		},
+		{
+			name:      "SWAP across a qubit",
+			gate:      createTwoQubit(Swap, SWAP, 0, 2),
+			numQubits: 3,
+			want: Matrix{
+				Rows:   8,
+				Cols:   8,
+				Stride: 8,
+				Data: []complex128{
+					1, 0, 0, 0, 0, 0, 0, 0,
+					0, 0, 0, 0, 1, 0, 0, 0,
+					0, 0, 1, 0, 0, 0, 0, 0,
+					0, 0, 0, 0, 0, 0, 1, 0,
+					0, 1, 0, 0, 0, 0, 0, 0,
+					0, 0, 0, 0, 0, 1, 0, 0,
+					0, 0, 0, 1, 0, 0, 0, 0,
+					0, 0, 0, 0, 0, 0, 0, 1,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.gate.Expand(tt.numQubits); !got.Equals(tt.want, StdEpsilon) {
+				t.Errorf("Expand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Panic on control equal to target", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Does not panic on control equal to target")
+			}
+		}()
+		createControlled(X, CX, []int{1}, []int{1})
+	})
+}
+
+func TestIsUnitary(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Matrix
+		want bool
+	}{
+		{"Hadamard", H, true},
+		{"Rotation", Rx(0.3), true},
+		{"Within tolerance", Matrix{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{1, 1e-10, 0, 1}}, true},
+		{"Not unitary", Matrix{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{1, 1, 0, 1}}, false},
+		{"Not square", Matrix{Rows: 1, Cols: 2, Stride: 2, Data: []complex128{1, 0}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnitary(tt.m); got != tt.want {
+				t.Errorf("IsUnitary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_createWire(t *testing.T) {
 	type args struct {
 		numQubits int
@@ -733,8 +918,9 @@ func Test_createWire(t *testing.T) {
 
 func TestCombine(t *testing.T) {
 	type args struct {
-		name     GateName
-		matrices []Gate
+		name      GateName
+		numQubits int
+		matrices  []Gate
 	}
 	tests := []struct {
 		name string
@@ -744,8 +930,9 @@ func TestCombine(t *testing.T) {
 		{
 			name: "H H",
 			args: args{
-				name:     WIRE,
-				matrices: []Gate{*createH([]int{0}, 1), *createH([]int{0}, 1)},
+				name:      WIRE,
+				numQubits: 1,
+				matrices:  []Gate{*createH([]int{0}), *createH([]int{0})},
 			},
 			want: &Gate{
 				Matrix: I,
@@ -755,8 +942,9 @@ func TestCombine(t *testing.T) {
 		{
 			name: "H(0) CX",
 			args: args{
-				name:     55,
-				matrices: []Gate{*createH([]int{0}, 2), *createCX(0, 1, 2)},
+				name:      55,
+				numQubits: 2,
+				matrices:  []Gate{*createH([]int{0}), *createCX(0, 1)},
 			},
 			want: &Gate{
 				Matrix: Matrix{
@@ -777,8 +965,9 @@ func TestCombine(t *testing.T) {
 		{
 			name: "CX H",
 			args: args{
-				name:     55,
-				matrices: []Gate{*createCX(0, 1, 2), *createH([]int{0}, 2)},
+				name:      55,
+				numQubits: 2,
+				matrices:  []Gate{*createCX(0, 1), *createH([]int{0})},
 			},
 			want: &Gate{
 				Matrix: Matrix{
@@ -798,9 +987,61 @@ func TestCombine(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := Combine(tt.args.name, tt.args.matrices...); !got.Equals(tt.want, StdEpsilon) {
+			if got := Combine(tt.args.name, tt.args.numQubits, tt.args.matrices...); !got.Equals(tt.want, StdEpsilon) {
 				t.Errorf("Combine() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestGate_Expand(t *testing.T) {
+	cxKernel := Matrix{
+		Rows:   4,
+		Cols:   4,
+		Stride: 4,
+		Data: []complex128{
+			1, 0, 0, 0,
+			0, 1, 0, 0,
+			0, 0, 0, 1,
+			0, 0, 1, 0,
+		},
+	}
+	tests := []struct {
+		name      string
+		gate      Gate
+		numQubits int
+		want      Matrix
+	}{
+		{
+			name:      "Whole register gate is returned as is",
+			gate:      *createWire(2),
+			numQubits: 2,
+			want:      Identity(4),
+		},
+		{
+			name: "Two-qubit operator on reversed targets",
+			gate: Gate{
+				Matrix:  cxKernel,
+				targets: []int{1, 0},
+			},
+			numQubits: 2,
+			want:      createCX(1, 0).Expand(2),
+		},
+		{
+			name: "Two-qubit operator on a wider register",
+			gate: Gate{
+				Matrix:  cxKernel,
+				targets: []int{0, 1},
+			},
+			numQubits: 3,
+			want:      *cxKernel.Kronecker(I),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.gate.Expand(tt.numQubits); !got.Equals(tt.want, StdEpsilon) {
+				t.Errorf("Expand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,566 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Schema version written to the JSON of circuits and execution results.
+// Files of this version and earlier can be read; later versions are rejected.
+const JSONVersion = 1
+
+// Complex number in JSON, as a [re, im] pair
+type jsonComplex complex128
+
+func (z jsonComplex) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]float64{real(z), imag(z)})
+}
+
+func (z *jsonComplex) UnmarshalJSON(data []byte) error {
+	var pair []float64
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("complex number has %v parts, want [re, im]", len(pair))
+	}
+	*z = jsonComplex(complex(pair[0], pair[1]))
+	return nil
+}
+
+func toJSONComplex(data []complex128) []jsonComplex {
+	out := make([]jsonComplex, len(data))
+	for i, z := range data {
+		out[i] = jsonComplex(z)
+	}
+	return out
+}
+
+func fromJSONComplex(data []jsonComplex) []complex128 {
+	out := make([]complex128, len(data))
+	for i, z := range data {
+		out[i] = complex128(z)
+	}
+	return out
+}
+
+// Checks the schema version of a JSON document
+func checkVersion(version int) error {
+	if version < 1 || version > JSONVersion {
+		return fmt.Errorf("unsupported schema version %v, want 1 to %v", version, JSONVersion)
+	}
+	return nil
+}
+
+// Matrix in JSON, as a list of rows each a list of [re, im] pairs. Only the encodings
+// of gates and executions use it; Matrix itself has no JSON methods.
+type jsonMatrix Matrix
+
+func (m jsonMatrix) MarshalJSON() ([]byte, error) {
+	rows := make([][]jsonComplex, m.Rows)
+	for r := range rows {
+		rows[r] = toJSONComplex(m.Data[r*m.Stride : r*m.Stride+m.Cols])
+	}
+	return json.Marshal(rows)
+}
+
+// Decodes a matrix written by MarshalJSON. Returns an error if the rows differ in length.
+func (m *jsonMatrix) UnmarshalJSON(data []byte) error {
+	var rows [][]jsonComplex
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return err
+	}
+	out := Matrix{Rows: len(rows), Data: []complex128{}}
+	if len(rows) > 0 {
+		out.Cols = len(rows[0])
+		out.Stride = out.Cols
+	}
+	for r, row := range rows {
+		if len(row) != out.Cols {
+			return fmt.Errorf("row %v of matrix has %v entries, want %v", r, len(row), out.Cols)
+		}
+		out.Data = append(out.Data, fromJSONComplex(row)...)
+	}
+	*m = jsonMatrix(out)
+	return nil
+}
+
+func toJSONMatrices(ms []Matrix) []jsonMatrix {
+	if ms == nil {
+		return nil
+	}
+	out := make([]jsonMatrix, len(ms))
+	for i, m := range ms {
+		out[i] = jsonMatrix(m)
+	}
+	return out
+}
+
+func fromJSONMatrices(ms []jsonMatrix) []Matrix {
+	out := make([]Matrix, len(ms))
+	for i, m := range ms {
+		out[i] = Matrix(m)
+	}
+	return out
+}
+
+// Gate names in JSON, which stay fixed however the GateName constants change
+var jsonGateNames = map[GateName]string{
+	HADAMARD: "h", WIRE: "id", PAULIX: "x", CX: "cx", PAULIY: "y", PAULIZ: "z",
+	SGATE: "s", SDAGGER: "sdg", TGATE: "t", TDAGGER: "tdg",
+	ROTX: "rx", ROTY: "ry", ROTZ: "rz", PHASE: "p", U3: "u",
+	CZ: "cz", CY: "cy", CH: "ch", SWAP: "swap", ISWAP: "iswap",
+	CPHASE: "cp", CROTX: "crx", CROTY: "cry", CROTZ: "crz",
+	CCX: "ccx", CSWAP: "cswap", MCX: "mcx", MCU: "mcu", CUSTOM: "unitary",
+	MEASURE: "measure", RESET: "reset",
+	DEPOLARIZING: "depolarizing", AMPLITUDEDAMPING: "amplitude_damping", PHASEDAMPING: "phase_damping",
+	BITFLIP: "bit_flip", PHASEFLIP: "phase_flip", KRAUS: "kraus",
+}
+
+// Name in JSON of gates combined under a GateName of their own, such as compiled circuits
+const jsonCombinedName = "combined"
+
+// Operators of the named gates, which are rebuilt from their parameters when read
+var jsonGateMatrices = map[GateName]struct {
+	params int
+	matrix func(p []float64) Matrix
+}{
+	HADAMARD: {0, func(p []float64) Matrix { return H }},
+	PAULIX:   {0, func(p []float64) Matrix { return X }},
+	CX:       {0, func(p []float64) Matrix { return X }},
+	PAULIY:   {0, func(p []float64) Matrix { return Y }},
+	PAULIZ:   {0, func(p []float64) Matrix { return Z }},
+	SGATE:    {0, func(p []float64) Matrix { return S }},
+	SDAGGER:  {0, func(p []float64) Matrix { return Sdg }},
+	TGATE:    {0, func(p []float64) Matrix { return T }},
+	TDAGGER:  {0, func(p []float64) Matrix { return Tdg }},
+	ROTX:     {1, func(p []float64) Matrix { return Rx(p[0]) }},
+	ROTY:     {1, func(p []float64) Matrix { return Ry(p[0]) }},
+	ROTZ:     {1, func(p []float64) Matrix { return Rz(p[0]) }},
+	PHASE:    {1, func(p []float64) Matrix { return Phase(p[0]) }},
+	U3:       {3, func(p []float64) Matrix { return U(p[0], p[1], p[2]) }},
+	CZ:       {0, func(p []float64) Matrix { return Z }},
+	CY:       {0, func(p []float64) Matrix { return Y }},
+	CH:       {0, func(p []float64) Matrix { return H }},
+	SWAP:     {0, func(p []float64) Matrix { return Swap }},
+	ISWAP:    {0, func(p []float64) Matrix { return ISwap }},
+	CPHASE:   {1, func(p []float64) Matrix { return Phase(p[0]) }},
+	CROTX:    {1, func(p []float64) Matrix { return Rx(p[0]) }},
+	CROTY:    {1, func(p []float64) Matrix { return Ry(p[0]) }},
+	CROTZ:    {1, func(p []float64) Matrix { return Rz(p[0]) }},
+	CCX:      {0, func(p []float64) Matrix { return X }},
+	CSWAP:    {0, func(p []float64) Matrix { return Swap }},
+	MCX:      {0, func(p []float64) Matrix { return X }},
+}
+
+// Number of controls of the named controlled gates, with -1 for any number.
+// Every other named gate takes none.
+var jsonGateControls = map[GateName]int{
+	CX: 1, CZ: 1, CY: 1, CH: 1, CPHASE: 1, CROTX: 1, CROTY: 1, CROTZ: 1, CSWAP: 1,
+	CCX: 2, MCX: -1, MCU: -1,
+}
+
+// Noise channels with a single probability, which are rebuilt from it when read
+var jsonChannels = map[GateName]func(p float64) Channel{
+	DEPOLARIZING:     DepolarizingChannel,
+	AMPLITUDEDAMPING: AmplitudeDampingChannel,
+	PHASEDAMPING:     PhaseDampingChannel,
+	BITFLIP:          BitFlipChannel,
+	PHASEFLIP:        PhaseFlipChannel,
+}
+
+type jsonCondition struct {
+	Clbits []int `json:"clbits"`
+	Value  int   `json:"value"`
+}
+
+type jsonGate struct {
+	Name        string         `json:"name"`
+	ID          int            `json:"id,omitempty"` // GateName of combined gates
+	Label       string         `json:"label,omitempty"`
+	Params      []float64      `json:"params,omitempty"`
+	Targets     []int          `json:"targets,omitempty"`
+	Controls    []int          `json:"controls,omitempty"`
+	NegControls []int          `json:"negControls,omitempty"`
+	Clbits      []int          `json:"clbits,omitempty"`
+	Condition   *jsonCondition `json:"condition,omitempty"`
+	Matrix      *jsonMatrix    `json:"matrix,omitempty"`
+	Kraus       []jsonMatrix   `json:"kraus,omitempty"`
+}
+
+// Encodes the gate by name, qubits and parameters. The matrix is only written for
+// gates it cannot be rebuilt for from those, such as custom unitaries and compiled circuits.
+func (g Gate) MarshalJSON() ([]byte, error) {
+	jg := jsonGate{
+		Label:       g.label,
+		Params:      g.params,
+		Targets:     g.targets,
+		Controls:    g.controls,
+		NegControls: g.negControls,
+		Clbits:      g.clbits,
+	}
+	name, known := jsonGateNames[g.name]
+	if known {
+		jg.Name = name
+	} else {
+		jg.Name = jsonCombinedName
+		jg.ID = int(g.name)
+	}
+	if g.cond != nil {
+		jg.Condition = &jsonCondition{Clbits: g.cond.clbits, Value: g.cond.value}
+	}
+
+	switch {
+	case g.kraus != nil:
+		if _, ok := jsonChannels[g.name]; !ok {
+			jg.Kraus = toJSONMatrices(g.kraus)
+		}
+	case g.name == MEASURE || g.name == RESET:
+	case g.targets == nil || g.name == CUSTOM || g.name == MCU || !known:
+		m := jsonMatrix(g.Matrix)
+		jg.Matrix = &m
+	}
+	return json.Marshal(jg)
+}
+
+// Decodes a gate written by MarshalJSON, rebuilding its operator. Returns an error
+// if the gate is unknown, its parameters or matrix do not match its qubits, or a
+// qubit is used twice. Whether the qubits fit a circuit is checked by the circuit.
+func (g *Gate) UnmarshalJSON(data []byte) error {
+	var jg jsonGate
+	if err := json.Unmarshal(data, &jg); err != nil {
+		return err
+	}
+
+	out := Gate{
+		label:       jg.Label,
+		params:      jg.Params,
+		targets:     jg.Targets,
+		controls:    jg.Controls,
+		negControls: jg.NegControls,
+		clbits:      jg.Clbits,
+	}
+	if len(out.targets) == 0 {
+		out.targets = nil
+	}
+	if jg.Condition != nil {
+		out.cond = &condition{clbits: jg.Condition.Clbits, value: jg.Condition.Value}
+	}
+
+	known := false
+	for name, s := range jsonGateNames {
+		if s == jg.Name {
+			out.name, known = name, true
+		}
+	}
+	switch {
+	case jg.Name == jsonCombinedName:
+		out.name = GateName(jg.ID)
+		if _, ok := jsonGateNames[out.name]; ok {
+			return fmt.Errorf("combined gate has id %v of the %v gate", jg.ID, jg.Name)
+		}
+	case !known:
+		return fmt.Errorf("unknown gate %q", jg.Name)
+	}
+	numControls := len(out.controls) + len(out.negControls)
+	if want := jsonGateControls[out.name]; known && want >= 0 && numControls != want {
+		return fmt.Errorf("%v takes %v controls, got %v", jg.Name, want, numControls)
+	}
+
+	seen := map[int]bool{}
+	for _, q := range out.Qubits() {
+		if q < 0 {
+			return fmt.Errorf("%v has negative qubit %v", jg.Name, q)
+		}
+		if seen[q] {
+			return fmt.Errorf("qubit %v used more than once in %v", q, jg.Name)
+		}
+		seen[q] = true
+	}
+	for _, c := range out.clbits {
+		if c < 0 {
+			return fmt.Errorf("%v has negative classical bit %v", jg.Name, c)
+		}
+	}
+	if out.cond != nil {
+		for _, c := range out.cond.clbits {
+			if c < 0 {
+				return fmt.Errorf("%v has negative classical bit %v in its condition", jg.Name, c)
+			}
+		}
+	}
+
+	if err := out.rebuild(jg); err != nil {
+		return fmt.Errorf("%v: %v", jg.Name, err)
+	}
+	*g = out
+	return nil
+}
+
+// Sets the operator or Kraus operators of a decoded gate
+func (g *Gate) rebuild(jg jsonGate) error {
+	if jg.Matrix == nil && g.targets == nil {
+		return fmt.Errorf("gate without targets has no matrix")
+	}
+	if jg.Matrix != nil {
+		m := Matrix(*jg.Matrix)
+		if m.Rows < 1 || m.Rows&(m.Rows-1) != 0 || !isUnitary(m, unitaryTolerance) {
+			return fmt.Errorf("matrix is not a unitary of size 2^n")
+		}
+		if g.targets != nil && m.Rows != 1<<uint(len(g.targets)) {
+			return fmt.Errorf("%vx%v matrix does not act on %v targets", m.Rows, m.Cols, len(g.targets))
+		}
+		g.Matrix = m
+		return nil
+	}
+
+	if build, ok := jsonChannels[g.name]; ok {
+		if len(g.params) != 1 || len(g.targets) != 1 {
+			return fmt.Errorf("channel takes 1 probability and 1 target, got %v and %v", len(g.params), len(g.targets))
+		}
+		if p := g.params[0]; !(p >= 0 && p <= 1) {
+			return fmt.Errorf("probability %v is not between 0 and 1", p)
+		}
+		g.kraus = build(g.params[0]).kraus
+		return nil
+	}
+
+	switch g.name {
+	case KRAUS:
+		ch, err := KrausChannel(fromJSONMatrices(jg.Kraus), g.label)
+		if err != nil {
+			return err
+		}
+		if ch.NumQubits() != len(g.targets) {
+			return fmt.Errorf("%v-qubit channel cannot act on %v qubits", ch.NumQubits(), len(g.targets))
+		}
+		g.kraus = ch.kraus
+		return nil
+	case MEASURE:
+		if len(g.targets) != 1 || len(g.clbits) != 1 {
+			return fmt.Errorf("measurement takes 1 target and 1 classical bit, got %v and %v", len(g.targets), len(g.clbits))
+		}
+		return nil
+	case RESET:
+		if len(g.targets) != 1 {
+			return fmt.Errorf("reset takes 1 target, got %v", len(g.targets))
+		}
+		return nil
+	}
+
+	op, ok := jsonGateMatrices[g.name]
+	if !ok {
+		return fmt.Errorf("gate has no matrix")
+	}
+	if len(g.params) != op.params {
+		return fmt.Errorf("gate takes %v parameters, got %v", op.params, len(g.params))
+	}
+	for _, p := range g.params {
+		if math.IsNaN(p) || math.IsInf(p, 0) {
+			return fmt.Errorf("parameter %v is not finite", p)
+		}
+	}
+	m := op.matrix(g.params)
+	// Single-qubit operators of uncontrolled gates such as H apply to each target
+	if m.Rows != 1<<uint(len(g.targets)) && (m.Rows != 2 || len(g.Qubits()) != len(g.targets)) {
+		return fmt.Errorf("%vx%v matrix does not act on %v targets", m.Rows, m.Cols, len(g.targets))
+	}
+	g.Matrix = m
+	return nil
+}
+
+type jsonCircuit struct {
+	Version   int    `json:"version"`
+	NumQubits int    `json:"numQubits"`
+	NumClbits int    `json:"numClbits"`
+	Gates     []Gate `json:"gates"`
+}
+
+// Encodes the circuit, with its number of qubits and classical bits and its gates
+// in order, under the schema version JSONVersion
+func (qc QuantumCircuit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCircuit{
+		Version:   JSONVersion,
+		NumQubits: qc.numQubits,
+		NumClbits: qc.numClbits,
+		Gates:     qc.gates,
+	})
+}
+
+// Decodes a circuit written by MarshalJSON. Returns an error for unsupported schema
+// versions and for gates that are invalid or do not fit the circuit's qubits and bits.
+func (qc *QuantumCircuit) UnmarshalJSON(data []byte) error {
+	var jc jsonCircuit
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return err
+	}
+	if err := checkVersion(jc.Version); err != nil {
+		return err
+	}
+	if jc.NumQubits < 0 || jc.NumClbits < 0 {
+		return fmt.Errorf("circuit has %v qubits and %v classical bits", jc.NumQubits, jc.NumClbits)
+	}
+
+	out := NewQuantumCircuit(jc.NumQubits)
+	out.numClbits = jc.NumClbits
+	for i, g := range jc.Gates {
+		for _, q := range g.Qubits() {
+			if q >= jc.NumQubits {
+				return fmt.Errorf("gate %v: qubit %v is out of range for %v qubits", i, q, jc.NumQubits)
+			}
+		}
+		clbits, _ := g.Condition()
+		for _, c := range append(append([]int{}, g.clbits...), clbits...) {
+			if c >= jc.NumClbits {
+				return fmt.Errorf("gate %v: classical bit %v is out of range for %v bits", i, c, jc.NumClbits)
+			}
+		}
+		if g.targets == nil && g.Rows != 1<<uint(jc.NumQubits) {
+			return fmt.Errorf("gate %v: %vx%v matrix does not act on %v qubits", i, g.Rows, g.Cols, jc.NumQubits)
+		}
+		out.addGate(g)
+	}
+	*qc = out
+	return nil
+}
+
+type jsonExecution struct {
+	Version       int           `json:"version"`
+	NumQubits     int           `json:"numQubits"`
+	Amplitudes    []jsonComplex `json:"amplitudes,omitempty"`
+	DensityMatrix *jsonMatrix   `json:"densityMatrix,omitempty"`
+	Probabilities []float64     `json:"probabilities"`
+	Clbits        []int         `json:"clbits"`
+}
+
+// Encodes the output of the execution: the amplitudes of the register as [re, im]
+// pairs, or its density matrix when executed with WithDensityMatrix, the probability
+// of each basis state and the classical bits. The noise model it ran with is not
+// written, so Sample on a decoded execution applies no readout errors.
+func (qce QuantumCircuitExecution) MarshalJSON() ([]byte, error) {
+	if qce.stabilizer != nil {
+		return nil, fmt.Errorf("execution of %v qubits ran on a stabilizer tableau and has no amplitudes to encode", qce.numQubits())
+	}
+	je := jsonExecution{
+		Version:       JSONVersion,
+		NumQubits:     qce.numQubits(),
+		Probabilities: qce.probabilities(),
+		Clbits:        qce.clbits,
+	}
+	if je.Clbits == nil {
+		je.Clbits = []int{}
+	}
+	if qce.rho != nil {
+		je.DensityMatrix = (*jsonMatrix)(qce.rho)
+	} else {
+		je.Amplitudes = toJSONComplex(qce.out.Data)
+	}
+	return json.Marshal(je)
+}
+
+// Decodes an execution written by MarshalJSON. Probabilities are recomputed from
+// the amplitudes or density matrix rather than read.
+func (qce *QuantumCircuitExecution) UnmarshalJSON(data []byte) error {
+	var je jsonExecution
+	if err := json.Unmarshal(data, &je); err != nil {
+		return err
+	}
+	if err := checkVersion(je.Version); err != nil {
+		return err
+	}
+	if je.NumQubits < 0 || je.NumQubits > 62 {
+		return fmt.Errorf("execution has %v qubits", je.NumQubits)
+	}
+	size := 1 << uint(je.NumQubits)
+
+	out := QuantumCircuitExecution{clbits: je.Clbits}
+	switch {
+	case (je.Amplitudes == nil) == (je.DensityMatrix == nil):
+		return fmt.Errorf("execution needs either amplitudes or a density matrix")
+	case je.DensityMatrix != nil:
+		if je.DensityMatrix.Rows != size || je.DensityMatrix.Cols != size {
+			return fmt.Errorf("density matrix is %vx%v, want %vx%v for %v qubits",
+				je.DensityMatrix.Rows, je.DensityMatrix.Cols, size, size, je.NumQubits)
+		}
+		out.rho = (*Matrix)(je.DensityMatrix)
+	default:
+		if len(je.Amplitudes) != size {
+			return fmt.Errorf("execution has %v amplitudes, want %v for %v qubits", len(je.Amplitudes), size, je.NumQubits)
+		}
+		out.out = NewColVec(Matrix{Rows: size, Cols: 1, Stride: 1, Data: fromJSONComplex(je.Amplitudes)})
+	}
+	for i, c := range out.clbits {
+		if c != 0 && c != 1 {
+			return fmt.Errorf("classical bit %v has value %v, want 0 or 1", i, c)
+		}
+	}
+	*qce = out
+	return nil
+}
+
+type jsonTrajectoryResult struct {
+	Version     int            `json:"version"`
+	Shots       int            `json:"shots"`
+	Counts      map[string]int `json:"counts"`
+	ClbitCounts map[string]int `json:"clbitCounts"`
+}
+
+// Encodes the number of shots and the counts of each outcome
+func (tr TrajectoryResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTrajectoryResult{
+		Version:     JSONVersion,
+		Shots:       tr.shots,
+		Counts:      tr.counts,
+		ClbitCounts: tr.clbitCounts,
+	})
+}
+
+// Decodes a result written by MarshalJSON. Returns an error unless the counts are
+// keyed by bitstrings of equal length and add up to the number of shots.
+func (tr *TrajectoryResult) UnmarshalJSON(data []byte) error {
+	var jr jsonTrajectoryResult
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return err
+	}
+	if err := checkVersion(jr.Version); err != nil {
+		return err
+	}
+	if jr.Counts == nil {
+		jr.Counts = map[string]int{}
+	}
+	if jr.ClbitCounts == nil {
+		jr.ClbitCounts = map[string]int{}
+	}
+	for field, counts := range map[string]map[string]int{"counts": jr.Counts, "clbitCounts": jr.ClbitCounts} {
+		if err := checkCounts(counts, jr.Shots); err != nil {
+			return fmt.Errorf("%v: %v", field, err)
+		}
+	}
+	*tr = TrajectoryResult{shots: jr.Shots, counts: jr.Counts, clbitCounts: jr.ClbitCounts}
+	return nil
+}
+
+// Checks that counts are keyed by bitstrings of one length and add up to shots
+func checkCounts(counts map[string]int, shots int) error {
+	total, length := 0, -1
+	for outcome, n := range counts {
+		if length >= 0 && len(outcome) != length {
+			return fmt.Errorf("outcomes %q and others differ in length", outcome)
+		}
+		length = len(outcome)
+		for _, b := range outcome {
+			if b != '0' && b != '1' {
+				return fmt.Errorf("outcome %q is not a bitstring", outcome)
+			}
+		}
+		if n < 0 {
+			return fmt.Errorf("outcome %q has negative count %v", outcome, n)
+		}
+		total += n
+	}
+	if total != shots {
+		return fmt.Errorf("counts add up to %v, want %v shots", total, shots)
+	}
+	return nil
+}
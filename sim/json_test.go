@@ -0,0 +1,279 @@
+package sim
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_JSON(t *testing.T) {
+	t.Run("Encodes gates by name, qubits and parameters", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.CRX(0.5, 0, 1)
+		qc.Measure(1, 0)
+		qc.If(0, 1).X(0)
+
+		got, err := json.Marshal(qc)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		want := `{"version":1,"numQubits":2,"numClbits":1,"gates":[` +
+			`{"name":"h","targets":[0]},` +
+			`{"name":"crx","params":[0.5],"targets":[1],"controls":[0]},` +
+			`{"name":"measure","targets":[1],"clbits":[0]},` +
+			`{"name":"x","targets":[0],"condition":{"clbits":[0],"value":1}}]}`
+		if string(got) != want {
+			t.Errorf("Marshal() = %v, want %v", string(got), want)
+		}
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		inner := NewQuantumCircuit(3)
+		inner.H([]int{0, 1})
+		inner.CX(1, 2)
+
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 1, 2})
+		qc.Y(0)
+		qc.Sdg(1)
+		qc.Tdg(2)
+		qc.U(0.1, -0.2, 0.3, 0)
+		qc.CP(math.Pi/3, 1, 2)
+		qc.CSWAP(0, 1, 2)
+		qc.ISWAP(0, 2)
+		qc.MCX([]int{0, NegControl(1)}, 2)
+		qc.MCU([]int{2}, Ry(0.4), []int{0})
+		if err := qc.Unitary(Swap, []int{2, 0}, "my swap"); err != nil {
+			t.Fatal(err)
+		}
+		addCompiled(&qc, inner)
+		qc.AddClbits(1)
+		qc.Measure(0, 1)
+		qc.IfRegister([]int{0, 1}, 2).Reset(2)
+		qc.Depolarizing(0.1, 1)
+		if err := qc.Kraus([]Matrix{I}, []int{2}, "identity"); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := json.Marshal(qc)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var got QuantumCircuit
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got.NumQubits() != qc.NumQubits() || got.NumClbits() != qc.NumClbits() || len(got.gates) != len(qc.gates) {
+			t.Fatalf("Unmarshal() has %v qubits, %v clbits and %v gates, want %v, %v and %v",
+				got.NumQubits(), got.NumClbits(), len(got.gates), qc.NumQubits(), qc.NumClbits(), len(qc.gates))
+		}
+		for i := range qc.gates {
+			if !got.gates[i].Equals(&qc.gates[i], StdEpsilon) || got.gates[i].Name() != qc.gates[i].Name() ||
+				!reflect.DeepEqual(got.gates[i].Params(), qc.gates[i].Params()) {
+				t.Errorf("Unmarshal() gate %v = %v, want %v", i, got.gates[i], qc.gates[i])
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			json string
+			want string
+		}{
+			{"Missing version", `{"numQubits":1,"gates":[]}`, "schema version 0"},
+			{"Later version", `{"version":2,"numQubits":1,"gates":[]}`, "schema version 2"},
+			{"Unknown gate", `{"version":1,"numQubits":1,"gates":[{"name":"foo","targets":[0]}]}`, `unknown gate "foo"`},
+			{"Qubit out of range", `{"version":1,"numQubits":1,"gates":[{"name":"x","targets":[1]}]}`, "gate 0: qubit 1 is out of range"},
+			{"Clbit out of range", `{"version":1,"numQubits":1,"numClbits":1,"gates":[{"name":"measure","targets":[0],"clbits":[1]}]}`, "classical bit 1 is out of range"},
+			{"Repeated qubit", `{"version":1,"numQubits":2,"gates":[{"name":"cx","targets":[1],"controls":[1]}]}`, "used more than once"},
+			{"Missing control", `{"version":1,"numQubits":2,"gates":[{"name":"cx","targets":[1]}]}`, "cx takes 1 controls, got 0"},
+			{"Controlled H gate", `{"version":1,"numQubits":2,"gates":[{"name":"h","targets":[1],"controls":[0]}]}`, "h takes 0 controls, got 1"},
+			{"Toffoli", `{"version":1,"numQubits":3,"gates":[{"name":"ccx","targets":[2],"negControls":[0]}]}`, "ccx takes 2 controls, got 1"},
+			{"Missing parameter", `{"version":1,"numQubits":1,"gates":[{"name":"rx","targets":[0]}]}`, "takes 1 parameters"},
+			{"Wrong targets", `{"version":1,"numQubits":2,"gates":[{"name":"swap","targets":[0]}]}`, "does not act on 1 targets"},
+			{"Not unitary", `{"version":1,"numQubits":1,"gates":[{"name":"unitary","targets":[0],"matrix":[[[1,0],[1,0]],[[0,0],[1,0]]]}]}`, "not a unitary"},
+			{"Ragged matrix", `{"version":1,"numQubits":1,"gates":[{"name":"unitary","targets":[0],"matrix":[[[1,0]],[[0,0],[1,0]]]}]}`, "row 1 of matrix"},
+			{"Probability", `{"version":1,"numQubits":1,"gates":[{"name":"bit_flip","params":[2],"targets":[0]}]}`, "not between 0 and 1"},
+			{"Not trace preserving", `{"version":1,"numQubits":1,"gates":[{"name":"kraus","targets":[0],"kraus":[[[[1,0],[0,0]],[[0,0],[0,0]]]]}]}`, "not trace preserving"},
+			{"Compiled size", `{"version":1,"numQubits":2,"gates":[{"name":"combined","id":42,"matrix":[[[1,0],[0,0]],[[0,0],[1,0]]]}]}`, "does not act on 2 qubits"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var qc QuantumCircuit
+				if err := json.Unmarshal([]byte(tt.json), &qc); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Unmarshal() error = %v, want one containing %q", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestQuantumCircuitExecution_JSON(t *testing.T) {
+	qc := NewQuantumCircuit(2)
+	qc.H([]int{0})
+	qc.S(0)
+	qc.CX(0, 1)
+	qc.Measure(1, 0)
+
+	tests := []struct {
+		name string
+		opts []ExecOption
+	}{
+		{name: "State vector"},
+		{name: "Density matrix", opts: []ExecOption{WithDensityMatrix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]ExecOption{WithRand(rand.New(rand.NewSource(1)))}, tt.opts...)
+			qce := qc.Exec([]Ket{ZeroKet, ZeroKet}, opts...)
+
+			data, err := json.Marshal(qce)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var got QuantumCircuitExecution
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !got.DensityMatrix().Equals(qce.DensityMatrix(), StdEpsilon) {
+				t.Errorf("Unmarshal() has density matrix %v, want %v", got.DensityMatrix(), qce.DensityMatrix())
+			}
+			if !reflect.DeepEqual(got.MeasureProbabilities(), qce.MeasureProbabilities()) {
+				t.Errorf("Unmarshal() has probabilities %v, want %v", got.MeasureProbabilities(), qce.MeasureProbabilities())
+			}
+			if !reflect.DeepEqual(got.Clbits(), qce.Clbits()) {
+				t.Errorf("Unmarshal() has clbits %v, want %v", got.Clbits(), qce.Clbits())
+			}
+		})
+	}
+
+	t.Run("Encodes amplitudes as pairs", func(t *testing.T) {
+		qc := NewQuantumCircuit(1)
+		qc.X(0)
+		qc.S(0)
+		got, err := json.Marshal(qc.Exec([]Ket{ZeroKet}))
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		want := `{"version":1,"numQubits":1,"amplitudes":[[0,0],[0,1]],"probabilities":[0,1],"clbits":[]}`
+		if string(got) != want {
+			t.Errorf("Marshal() = %v, want %v", string(got), want)
+		}
+	})
+
+	t.Run("Rejects tableau executions", func(t *testing.T) {
+		numQubits := maxStatevectorQubits + 1
+		qc := NewQuantumCircuit(numQubits)
+		qc.H([]int{0})
+		in := make([]Ket, numQubits)
+		for q := range in {
+			in[q] = ZeroKet
+		}
+		if _, err := json.Marshal(qc.Exec(in)); err == nil || !strings.Contains(err.Error(), "stabilizer tableau") {
+			t.Errorf("Marshal() error = %v, want one naming the stabilizer tableau", err)
+		}
+	})
+
+	t.Run("Encodes values as pointers", func(t *testing.T) {
+		qc := NewQuantumCircuit(1)
+		qc.X(0)
+		qce := qc.Exec([]Ket{ZeroKet})
+		result := qc.ExecTrajectories([]Ket{ZeroKet}, 2, 1)
+
+		for _, v := range []interface{}{*qce, *result, struct{ Execution QuantumCircuitExecution }{*qce}} {
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if !strings.Contains(string(got), `"version":1`) {
+				t.Errorf("Marshal(%T) = %v, want it to carry the schema version", v, string(got))
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			json string
+			want string
+		}{
+			{"Later version", `{"version":2,"numQubits":1,"amplitudes":[[1,0],[0,0]]}`, "schema version 2"},
+			{"No state", `{"version":1,"numQubits":1}`, "either amplitudes or a density matrix"},
+			{"Wrong size", `{"version":1,"numQubits":2,"amplitudes":[[1,0],[0,0]]}`, "has 2 amplitudes, want 4"},
+			{"Bad pair", `{"version":1,"numQubits":1,"amplitudes":[[1],[0,0]]}`, "has 1 parts"},
+			{"Clbit value", `{"version":1,"numQubits":1,"amplitudes":[[1,0],[0,0]],"clbits":[2]}`, "has value 2"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var qce QuantumCircuitExecution
+				if err := json.Unmarshal([]byte(tt.json), &qce); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Unmarshal() error = %v, want one containing %q", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestTrajectoryResult_JSON(t *testing.T) {
+	qc := NewQuantumCircuit(2)
+	qc.H([]int{0})
+	qc.CX(0, 1)
+	qc.Measure(0, 0)
+	result := qc.ExecTrajectories([]Ket{ZeroKet, ZeroKet}, 50, 3)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var got TrajectoryResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.Shots() != result.Shots() || !reflect.DeepEqual(got.Counts(), result.Counts()) ||
+		!reflect.DeepEqual(got.ClbitCounts(), result.ClbitCounts()) {
+		t.Errorf("Unmarshal() = %v, want %v", got, *result)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			json string
+			want string
+		}{
+			{"Missing version", `{"shots":1,"counts":{"0":1}}`, "schema version 0"},
+			{"Wrong total", `{"version":1,"shots":3,"counts":{"00":1,"11":1},"clbitCounts":{"0":3}}`, "counts: counts add up to 2"},
+			{"Not a bitstring", `{"version":1,"shots":1,"counts":{"0":1},"clbitCounts":{"x":1}}`, `"x" is not a bitstring`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var tr TrajectoryResult
+				if err := json.Unmarshal([]byte(tt.json), &tr); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Unmarshal() error = %v, want one containing %q", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func Test_jsonMatrix(t *testing.T) {
+	m := jsonMatrix{Rows: 2, Cols: 3, Stride: 3, Data: []complex128{1, 2i, -0.5, complex(1, -1), 0, 3}}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `[[[1,0],[0,2],[-0.5,0]],[[1,-1],[0,0],[3,0]]]`; string(data) != want {
+		t.Errorf("Marshal() = %v, want %v", string(data), want)
+	}
+	var got jsonMatrix
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Unmarshal() = %v, want %v", got, m)
+	}
+}
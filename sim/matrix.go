@@ -5,6 +5,7 @@ import (
 	"gonum.org/v1/gonum/blas"
 	"gonum.org/v1/gonum/blas/cblas128"
 	"math"
+	"math/cmplx"
 )
 
 type Matrix cblas128.General
@@ -148,6 +149,44 @@ func (a Matrix) Mul(b Matrix) *Matrix {
 	return &c
 }
 
+// Computes the conjugate transpose of matrix A.
+// Returns a reference to the matrix containing the result
+func (a Matrix) Dagger() *Matrix {
+	out := Matrix{
+		Rows:   a.Cols,
+		Cols:   a.Rows,
+		Stride: a.Rows,
+		Data:   make([]complex128, a.Rows*a.Cols),
+	}
+
+	for r := 0; r < a.Rows; r++ {
+		for c := 0; c < a.Cols; c++ {
+			out.Data[c*out.Stride+r] = cmplx.Conj(a.Data[r*a.Stride+c])
+		}
+	}
+
+	return &out
+}
+
+// Computes the entrywise complex conjugate of matrix A.
+// Returns a reference to the matrix containing the result
+func (a Matrix) Conj() *Matrix {
+	out := Matrix{
+		Rows:   a.Rows,
+		Cols:   a.Cols,
+		Stride: a.Cols,
+		Data:   make([]complex128, a.Rows*a.Cols),
+	}
+
+	for r := 0; r < a.Rows; r++ {
+		for c := 0; c < a.Cols; c++ {
+			out.Data[r*out.Stride+c] = cmplx.Conj(a.Data[r*a.Stride+c])
+		}
+	}
+
+	return &out
+}
+
 func (a Matrix) Add(b Matrix) *Matrix {
 	if a.Rows != b.Rows || a.Cols != b.Cols || a.Stride != b.Stride {
 		panic("Cannot Add matrices of differing dimensions")
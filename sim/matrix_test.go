@@ -994,3 +994,62 @@ func TestIdentity(t *testing.T) {
 		})
 	}
 }
+
+func TestMatrix_Dagger(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Matrix
+		want Matrix
+	}{
+		{
+			name: "Square matrix",
+			a: Matrix{
+				Rows:   2,
+				Cols:   2,
+				Stride: 2,
+				Data:   []complex128{1, 2i, 3 + 1i, 4},
+			},
+			want: Matrix{
+				Rows:   2,
+				Cols:   2,
+				Stride: 2,
+				Data:   []complex128{1, 3 - 1i, -2i, 4},
+			},
+		},
+		{
+			name: "Ket becomes bra",
+			a:    Matrix(HMinusKet),
+			want: Matrix{
+				Rows:   1,
+				Cols:   2,
+				Stride: 2,
+				Data:   []complex128{1 / math.Sqrt2, -1 / math.Sqrt2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Dagger(); !got.Equals(tt.want, StdEpsilon) {
+				t.Errorf("Dagger() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatrix_Conj(t *testing.T) {
+	a := Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, 2i, 3 + 1i, 4},
+	}
+	want := Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data:   []complex128{1, -2i, 3 - 1i, 4},
+	}
+	if got := a.Conj(); !got.Equals(want, StdEpsilon) {
+		t.Errorf("Conj() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,233 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Circuits preparing each of the 2^n basis states of n qubits with X gates and
+// measuring every qubit q into classical bit q. Circuit i prepares the basis state
+// whose bitstring, qubit 0 first, is i in binary.
+// Sampling each of them under readout noise gives the input to AssignmentMatrix.
+func CalibrationCircuits(numQubits int) []QuantumCircuit {
+	circuits := make([]QuantumCircuit, 1<<uint(numQubits))
+	for i := range circuits {
+		qc := NewQuantumCircuit(numQubits)
+		for q := 0; q < numQubits; q++ {
+			if i&qubitMask(q, numQubits) != 0 {
+				qc.X(q)
+			}
+		}
+		for q := 0; q < numQubits; q++ {
+			qc.Measure(q, q)
+		}
+		circuits[i] = qc
+	}
+	return circuits
+}
+
+// Builds the 2^n x 2^n assignment matrix whose entry [m][p] is the probability of
+// reading basis state m when basis state p was prepared, from the counts sampled
+// from each of the circuits of CalibrationCircuits, in the same order.
+func AssignmentMatrix(numQubits int, calibration []map[string]int) (Matrix, error) {
+	size := 1 << uint(numQubits)
+	if len(calibration) != size {
+		return Matrix{}, fmt.Errorf("got %v calibration results, want %v for %v qubits", len(calibration), size, numQubits)
+	}
+
+	a := Matrix{
+		Rows:   size,
+		Cols:   size,
+		Stride: size,
+		Data:   make([]complex128, size*size),
+	}
+	for p, counts := range calibration {
+		dist, err := countsDistribution(numQubits, counts)
+		if err != nil {
+			return Matrix{}, fmt.Errorf("calibration result %v: %v", p, err)
+		}
+		for m, prob := range dist {
+			a.Data[m*a.Stride+p] = complex(prob, 0)
+		}
+	}
+	return a, nil
+}
+
+// Builds the assignment matrix of independent readout errors from the 2x2 confusion
+// matrix of each qubit, as described by AddReadoutError, starting with qubit 0.
+func TensoredAssignmentMatrix(confusions []Matrix) Matrix {
+	a := Identity(1)
+	for q, c := range confusions {
+		if c.Rows != 2 || c.Cols != 2 {
+			panic(fmt.Sprintf("Confusion matrix of qubit %v is %vx%v, want 2x2", q, c.Rows, c.Cols))
+		}
+		a = *a.Kronecker(c)
+	}
+	return a
+}
+
+// Assignment matrix of the readout errors of the noise model on a register of numQubits
+// qubits. Qubits without a readout error are read perfectly.
+func (nm *NoiseModel) AssignmentMatrix(numQubits int) Matrix {
+	confusions := make([]Matrix, numQubits)
+	for q := range confusions {
+		if c, ok := nm.readout[q]; ok {
+			confusions[q] = c
+		} else {
+			confusions[q] = I
+		}
+	}
+	return TensoredAssignmentMatrix(confusions)
+}
+
+// Corrects measured counts for readout errors by solving A p = m for the distribution p
+// prepared before readout, where A is the assignment matrix and m the measured distribution.
+// The result is keyed by bitstring like the counts and may hold small negative
+// quasi-probabilities from sampling noise. Returns an error if A is singular.
+func MitigateInverse(assignment Matrix, counts map[string]int) (map[string]float64, error) {
+	a, measured, numQubits, err := mitigationProblem(assignment, counts)
+	if err != nil {
+		return nil, err
+	}
+
+	var p mat.VecDense
+	if err := p.SolveVec(a, measured); err != nil {
+		return nil, fmt.Errorf("assignment matrix cannot be inverted: %v", err)
+	}
+	return distributionMap(p.RawVector().Data, numQubits), nil
+}
+
+// Corrects measured counts for readout errors by finding the probability distribution p
+// minimizing ||A p - m||, where A is the assignment matrix and m the measured distribution.
+// Unlike MitigateInverse the result is always a valid distribution, keyed by bitstring.
+func MitigateLeastSquares(assignment Matrix, counts map[string]int) (map[string]float64, error) {
+	a, measured, numQubits, err := mitigationProblem(assignment, counts)
+	if err != nil {
+		return nil, err
+	}
+	size := measured.Len()
+
+	// Projected gradient descent over the probability simplex. The step is the inverse
+	// of a bound on the largest eigenvalue of AᵀA, which guarantees convergence.
+	step := 0.0
+	for r := 0; r < size; r++ {
+		for c := 0; c < size; c++ {
+			step += a.At(r, c) * a.At(r, c)
+		}
+	}
+	step = 1 / step
+
+	p := mat.NewVecDense(size, nil)
+	p.CloneFromVec(measured)
+	var residual, gradient mat.VecDense
+	next := make([]float64, size)
+	scratch := make([]float64, size)
+	for iter := 0; iter < 100000; iter++ {
+		residual.MulVec(a, p)
+		residual.SubVec(&residual, measured)
+		gradient.MulVec(a.T(), &residual)
+
+		for i := range next {
+			next[i] = p.AtVec(i) - step*gradient.AtVec(i)
+		}
+		projectSimplex(next, scratch)
+
+		change := 0.0
+		for i, v := range next {
+			change = math.Max(change, math.Abs(v-p.AtVec(i)))
+			p.SetVec(i, v)
+		}
+		if change < 1e-12 {
+			break
+		}
+	}
+
+	return distributionMap(p.RawVector().Data, numQubits), nil
+}
+
+// Projects v in place onto the set of probability distributions, nearest in Euclidean distance.
+// sorted is scratch space of the same length as v.
+func projectSimplex(v, sorted []float64) {
+	copy(sorted, v)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+
+	// Largest shift theta such that the shifted entries that stay positive sum to 1
+	sum, theta := 0.0, 0.0
+	for i, s := range sorted {
+		sum += s
+		if t := (sum - 1) / float64(i+1); s-t > 0 {
+			theta = t
+		}
+	}
+
+	for i := range v {
+		v[i] = math.Max(v[i]-theta, 0)
+	}
+}
+
+// Checks the assignment matrix and counts agree, returning them as a real matrix and
+// the measured distribution
+func mitigationProblem(assignment Matrix, counts map[string]int) (*mat.Dense, *mat.VecDense, int, error) {
+	size := assignment.Rows
+	if size < 1 || size&(size-1) != 0 || assignment.Cols != size {
+		return nil, nil, 0, fmt.Errorf("assignment matrix is %vx%v, want 2^n x 2^n", assignment.Rows, assignment.Cols)
+	}
+	numQubits := bits.TrailingZeros(uint(size))
+
+	a := mat.NewDense(size, size, nil)
+	for r := 0; r < size; r++ {
+		for c := 0; c < size; c++ {
+			a.Set(r, c, real(assignment.Data[r*assignment.Stride+c]))
+		}
+	}
+
+	measured, err := countsDistribution(numQubits, counts)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return a, mat.NewVecDense(size, measured), numQubits, nil
+}
+
+// Normalizes counts keyed by bitstring into a distribution over the basis states
+func countsDistribution(numQubits int, counts map[string]int) ([]float64, error) {
+	dist := make([]float64, 1<<uint(numQubits))
+	total := 0
+	for outcome, n := range counts {
+		if len(outcome) != numQubits {
+			return nil, fmt.Errorf("outcome %q is not a bitstring of %v qubits", outcome, numQubits)
+		}
+		i, err := strconv.ParseUint(outcome, 2, 64)
+		if err != nil {
+			return nil, fmt.Errorf("outcome %q is not a bitstring of %v qubits", outcome, numQubits)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("outcome %q has negative count %v", outcome, n)
+		}
+		dist[i] += float64(n)
+		total += n
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("counts are empty")
+	}
+
+	for i := range dist {
+		dist[i] /= float64(total)
+	}
+	return dist, nil
+}
+
+// Keys a distribution over basis states by bitstring, leaving out zero entries
+func distributionMap(dist []float64, numQubits int) map[string]float64 {
+	out := map[string]float64{}
+	for i, p := range dist {
+		if p != 0 {
+			out[bitString(i, numQubits)] = p
+		}
+	}
+	return out
+}
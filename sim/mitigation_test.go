@@ -0,0 +1,130 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAssignmentMatrix(t *testing.T) {
+	nm := NewNoiseModel()
+	if err := nm.AddReadoutError(0, ReadoutError(0.1, 0.2)); err != nil {
+		t.Fatalf("AddReadoutError() error = %v", err)
+	}
+	if err := nm.AddReadoutError(1, ReadoutError(0.05, 0)); err != nil {
+		t.Fatalf("AddReadoutError() error = %v", err)
+	}
+	want := nm.AssignmentMatrix(2)
+
+	circuits := CalibrationCircuits(2)
+	if len(circuits) != 4 {
+		t.Fatalf("CalibrationCircuits() returned %v circuits, want 4", len(circuits))
+	}
+	rng := rand.New(rand.NewSource(4))
+	calibration := make([]map[string]int, len(circuits))
+	for i := range circuits {
+		calibration[i] = circuits[i].Exec([]Ket{ZeroKet, ZeroKet}, WithNoise(nm), WithRand(rng)).Sample(20000, rng)
+	}
+
+	got, err := AssignmentMatrix(2, calibration)
+	if err != nil {
+		t.Fatalf("AssignmentMatrix() error = %v", err)
+	}
+	if !got.Equals(want, complex(0.01, 0)) {
+		t.Errorf("AssignmentMatrix() = %v, want %v", got, want)
+	}
+
+	if _, err := AssignmentMatrix(2, calibration[:3]); err == nil {
+		t.Errorf("AssignmentMatrix() with too few results should fail")
+	}
+	if _, err := AssignmentMatrix(2, []map[string]int{{"0": 1}, {"01": 1}, {"10": 1}, {"11": 1}}); err == nil {
+		t.Errorf("AssignmentMatrix() with a malformed bitstring should fail")
+	}
+}
+
+func TestMitigate(t *testing.T) {
+	assignment := TensoredAssignmentMatrix([]Matrix{ReadoutError(0.1, 0.2), ReadoutError(0.05, 0.15)})
+
+	// Exact measured counts of the distribution 0.3 |00> + 0.7 |11>
+	prepared := []float64{0.3, 0, 0, 0.7}
+	counts := map[string]int{}
+	for m := 0; m < 4; m++ {
+		p := 0.0
+		for j, q := range prepared {
+			p += real(assignment.Data[m*assignment.Stride+j]) * q
+		}
+		counts[bitString(m, 2)] = int(math.Round(p * 1e6))
+	}
+
+	tests := []struct {
+		name     string
+		mitigate func(Matrix, map[string]int) (map[string]float64, error)
+	}{
+		{name: "Inverse", mitigate: MitigateInverse},
+		{name: "Least squares", mitigate: MitigateLeastSquares},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mitigate(assignment, counts)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			for i, want := range prepared {
+				if p := got[bitString(i, 2)]; math.Abs(p-want) > 1e-4 {
+					t.Errorf("mitigated = %v, want %v", got, prepared)
+					break
+				}
+			}
+
+			if _, err := tt.mitigate(assignment, map[string]int{}); err == nil {
+				t.Errorf("mitigating empty counts should fail")
+			}
+			if _, err := tt.mitigate(Identity(3), counts); err == nil {
+				t.Errorf("mitigating with a 3x3 assignment matrix should fail")
+			}
+		})
+	}
+
+	t.Run("Least squares stays a distribution", func(t *testing.T) {
+		// Reading 1 is impossible under this assignment, so inverting gives a negative entry
+		got, err := MitigateLeastSquares(TensoredAssignmentMatrix([]Matrix{ReadoutError(0.2, 0)}), map[string]int{"0": 1})
+		if err != nil {
+			t.Fatalf("MitigateLeastSquares() error = %v", err)
+		}
+		if math.Abs(got["0"]-1) > 1e-6 || got["1"] < 0 {
+			t.Errorf("MitigateLeastSquares() = %v, want all probability on 0", got)
+		}
+	})
+
+	t.Run("Singular assignment", func(t *testing.T) {
+		singular := Matrix{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{0.5, 0.5, 0.5, 0.5}}
+		if _, err := MitigateInverse(singular, map[string]int{"0": 1}); err == nil {
+			t.Errorf("MitigateInverse() with a singular assignment matrix should fail")
+		}
+	})
+}
+
+func Test_projectSimplex(t *testing.T) {
+	tests := []struct {
+		name string
+		v    []float64
+		want []float64
+	}{
+		{"Already a distribution", []float64{0.2, 0.3, 0.5}, []float64{0.2, 0.3, 0.5}},
+		{"Negative entry", []float64{1.2, -0.2}, []float64{1, 0}},
+		{"Shifted down", []float64{0.5, 0.5, 0.5, 0.5}, []float64{0.25, 0.25, 0.25, 0.25}},
+		{"Unsorted", []float64{0.1, 0.9, 0.4}, []float64{0, 0.75, 0.25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := append([]float64{}, tt.v...)
+			projectSimplex(got, make([]float64, len(got)))
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+					t.Errorf("projectSimplex(%v) = %v, want %v", tt.v, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Singular values this much smaller than the largest are treated as zero and always dropped
+const mpsZeroTolerance = 1e-14
+
+// One site of a matrix product state: a left x 2 x right tensor, indexed (l*2+s)*right+r
+type mpsTensor struct {
+	left, right int
+	data        []complex128
+}
+
+// Register state as a matrix product state: a chain of one tensor per site, contracted
+// over bonds between neighbours, whose sizes grow with the entanglement between the two
+// sides. Qubits move between sites as gates on distant qubits swap them into place.
+// The chain is kept in mixed canonical form around the site center, so that the norm and
+// local probabilities can be read from that site alone and truncations are optimal.
+type mps struct {
+	numQubits int
+	sites     []mpsTensor
+	siteOf    []int // Site holding each qubit
+	qubitAt   []int // Qubit held by each site
+	center    int
+
+	maxBond     int     // Largest bond dimension kept, or 0 for no limit
+	threshold   float64 // Largest weight of singular values dropped at each truncation
+	discarded   float64 // Total weight dropped by truncations
+	maxBondSeen int
+}
+
+// Creates the matrix product state of a product of single-qubit states
+func newMPS(qubitStates []Ket, maxBond int, threshold float64) *mps {
+	n := len(qubitStates)
+	m := &mps{
+		numQubits:   n,
+		sites:       make([]mpsTensor, n),
+		siteOf:      make([]int, n),
+		qubitAt:     make([]int, n),
+		maxBond:     maxBond,
+		threshold:   threshold,
+		maxBondSeen: 1,
+	}
+	for q, ket := range qubitStates {
+		m.sites[q] = mpsTensor{left: 1, right: 1, data: []complex128{ket.Data[0], ket.Data[1]}}
+		m.siteOf[q] = q
+		m.qubitAt[q] = q
+	}
+	return m
+}
+
+func (t mpsTensor) matrix(rows, cols int) Matrix {
+	return Matrix{Rows: rows, Cols: cols, Stride: cols, Data: t.data}
+}
+
+// Moves the orthogonality center to the site, by SVDs that leave the sites passed over
+// left- or right-orthonormal
+func (m *mps) moveCenter(site int) {
+	for m.center < site {
+		a := m.sites[m.center]
+		u, s, v := svd(a.matrix(a.left*2, a.right))
+		keep := m.keep(s, false)
+
+		// Site becomes U, and S V† moves into the next site
+		m.sites[m.center] = mpsTensor{left: a.left, right: keep, data: columns(u, keep).Data}
+		sv := scaleRows(*columns(v, keep).Dagger(), s[:keep])
+		next := m.sites[m.center+1]
+		m.sites[m.center+1] = mpsTensor{left: keep, right: next.right, data: sv.Mul(next.matrix(next.left, 2*next.right)).Data}
+		m.center++
+	}
+	for m.center > site {
+		a := m.sites[m.center]
+		u, s, v := svd(a.matrix(a.left, 2*a.right))
+		keep := m.keep(s, false)
+
+		// Site becomes V†, and U S moves into the previous site
+		m.sites[m.center] = mpsTensor{left: keep, right: a.right, data: columns(v, keep).Dagger().Data}
+		us := scaleColumns(*columns(u, keep), s[:keep])
+		prev := m.sites[m.center-1]
+		m.sites[m.center-1] = mpsTensor{left: prev.left, right: keep, data: prev.matrix(prev.left*2, prev.right).Mul(us).Data}
+		m.center--
+	}
+}
+
+// Number of singular values to keep. Numerically zero values are always dropped, and when
+// truncating, as many of the smallest as the bond dimension and threshold require.
+// The dropped weight is added to the truncation error and the kept values rescaled to
+// preserve the norm.
+func (m *mps) keep(s []float64, truncate bool) int {
+	total := 0.0
+	for _, x := range s {
+		total += x * x
+	}
+
+	keep, dropped := len(s), 0.0
+	for keep > 1 {
+		w := s[keep-1] * s[keep-1]
+		zero := s[keep-1] <= mpsZeroTolerance*s[0]
+		tooMany := truncate && m.maxBond > 0 && keep > m.maxBond
+		small := truncate && (dropped+w)/total <= m.threshold
+		if !zero && !tooMany && !small {
+			break
+		}
+		dropped += w
+		keep--
+	}
+
+	if dropped > 0 && total > 0 {
+		m.discarded += dropped / total
+		f := math.Sqrt(total / (total - dropped))
+		for i := 0; i < keep; i++ {
+			s[i] *= f
+		}
+	}
+	if keep > m.maxBondSeen {
+		m.maxBondSeen = keep
+	}
+	return keep
+}
+
+// Applies f to the tensor contracted from the k sites starting at first, then splits it
+// back into sites with truncation, leaving the center at the last of them.
+// The contracted tensor has shape left x 2^k x right, with the first site most significant.
+func (m *mps) applyBlock(first, k int, f func(block []complex128, left, right int)) {
+	m.moveCenter(first)
+
+	a := m.sites[first]
+	left, phys, right := a.left, 2, a.right
+	block := a.data
+	for j := 1; j < k; j++ {
+		b := m.sites[first+j]
+		block = Matrix{Rows: left * phys, Cols: right, Stride: right, Data: block}.Mul(b.matrix(b.left, 2*b.right)).Data
+		phys *= 2
+		right = b.right
+	}
+
+	f(block, left, right)
+
+	for j := 0; j < k-1; j++ {
+		phys /= 2
+		u, s, v := svd(Matrix{Rows: left * 2, Cols: phys * right, Stride: phys * right, Data: block})
+		keep := m.keep(s, true)
+		m.sites[first+j] = mpsTensor{left: left, right: keep, data: columns(u, keep).Data}
+		block = scaleRows(*columns(v, keep).Dagger(), s[:keep]).Data
+		left = keep
+	}
+	m.sites[first+k-1] = mpsTensor{left: left, right: right, data: block}
+	m.center = first + k - 1
+}
+
+// Multiplies the physical index of a left x 2^k x right block by the operator
+func applyPhysical(block []complex128, left, right int, op Matrix) {
+	dim := op.Rows
+	in := make([]complex128, dim)
+	for l := 0; l < left; l++ {
+		for r := 0; r < right; r++ {
+			for s := range in {
+				in[s] = block[(l*dim+s)*right+r]
+			}
+			for s := 0; s < dim; s++ {
+				var sum complex128
+				for t := 0; t < dim; t++ {
+					sum += op.Data[s*op.Stride+t] * in[t]
+				}
+				block[(l*dim+s)*right+r] = sum
+			}
+		}
+	}
+}
+
+// Swaps the qubits held by two neighbouring sites
+func (m *mps) swapSites(site int) {
+	m.applyBlock(site, 2, func(block []complex128, left, right int) {
+		applyPhysical(block, left, right, Swap)
+	})
+	a, b := m.qubitAt[site], m.qubitAt[site+1]
+	m.qubitAt[site], m.qubitAt[site+1] = b, a
+	m.siteOf[a], m.siteOf[b] = site+1, site
+}
+
+// Swaps the qubits onto neighbouring sites, keeping their relative order.
+// Returns the qubits ordered by site and the first of their sites.
+func (m *mps) gather(qubits []int) ([]int, int) {
+	order := append([]int{}, qubits...)
+	sort.Slice(order, func(i, j int) bool { return m.siteOf[order[i]] < m.siteOf[order[j]] })
+
+	// Swap toward the middle qubit, minimizing the distance moved
+	mid := len(order) / 2
+	for j := mid - 1; j >= 0; j-- {
+		for m.siteOf[order[j]] < m.siteOf[order[j+1]]-1 {
+			m.swapSites(m.siteOf[order[j]])
+		}
+	}
+	for j := mid + 1; j < len(order); j++ {
+		for m.siteOf[order[j]] > m.siteOf[order[j-1]]+1 {
+			m.swapSites(m.siteOf[order[j]] - 1)
+		}
+	}
+	return order, m.siteOf[order[0]]
+}
+
+// Operator on the qubits of order, the first most significant, applying the kernel to the
+// targets under the controls
+func localOperator(kernel Matrix, order, targets, controls, negControls []int) Matrix {
+	local := map[int]int{}
+	for i, q := range order {
+		local[q] = i
+	}
+	remap := func(qubits []int) []int {
+		out := make([]int, len(qubits))
+		for i, q := range qubits {
+			out[i] = local[q]
+		}
+		return out
+	}
+
+	k := len(order)
+	dim := 1 << uint(k)
+	op := Matrix{Rows: dim, Cols: dim, Stride: dim, Data: make([]complex128, dim*dim)}
+	col := make([]complex128, dim)
+	for c := 0; c < dim; c++ {
+		for i := range col {
+			col[i] = 0
+		}
+		col[c] = 1
+		applyKernel(col, k, kernel, remap(targets), remap(controls), remap(negControls))
+		for r := 0; r < dim; r++ {
+			op.Data[r*dim+c] = col[r]
+		}
+	}
+	return op
+}
+
+// Applies the kernel to the targets under the controls, as applyKernel does on a state vector
+func (m *mps) applyLocal(kernel Matrix, targets, controls, negControls []int) {
+	qubits := append(append(append([]int{}, controls...), negControls...), targets...)
+
+	// Single-qubit operators keep the canonical form without any SVD
+	if len(qubits) == 1 {
+		a := m.sites[m.siteOf[qubits[0]]]
+		applyPhysical(a.data, a.left, a.right, kernel)
+		return
+	}
+
+	order, first := m.gather(qubits)
+	op := localOperator(kernel, order, targets, controls, negControls)
+	m.applyBlock(first, len(order), func(block []complex128, left, right int) {
+		applyPhysical(block, left, right, op)
+	})
+}
+
+// Is the gate on the whole register the identity, like the wire, so that it can be skipped?
+func isWholeRegisterIdentity(g *Gate) bool {
+	return g.kraus == nil && g.Matrix.Equals(Identity(g.Rows), complex(unitaryTolerance, unitaryTolerance))
+}
+
+// Applies a gate, skipping identities on the whole register. Panics on other
+// whole-register gates, which a matrix product state cannot apply locally.
+func (m *mps) apply(g *Gate) {
+	if g.targets == nil {
+		if !isWholeRegisterIdentity(g) {
+			panic(fmt.Sprintf("Cannot apply %v to the whole register of a matrix product state", g.Name()))
+		}
+		return
+	}
+	if g.Rows == 2 && len(g.targets) > 1 {
+		for _, t := range g.targets {
+			m.applyLocal(g.Matrix, []int{t}, g.controls, g.negControls)
+		}
+		return
+	}
+	m.applyLocal(g.Matrix, g.targets, g.controls, g.negControls)
+}
+
+// Probabilities of reading 0 and 1 from the qubit, with the center moved to its site
+func (m *mps) qubitProbabilities(qubit int) (float64, float64) {
+	m.moveCenter(m.siteOf[qubit])
+	a := m.sites[m.center]
+	var p [2]float64
+	for l := 0; l < a.left; l++ {
+		for s := 0; s < 2; s++ {
+			for r := 0; r < a.right; r++ {
+				x := a.data[(l*2+s)*a.right+r]
+				p[s] += real(x)*real(x) + imag(x)*imag(x)
+			}
+		}
+	}
+	return p[0], p[1]
+}
+
+func (m *mps) measure(qubit int, u float64) int {
+	p0, p1 := m.qubitProbabilities(qubit)
+	total := p0 + p1
+
+	outcome, prob := 0, p0
+	if u < p1/total {
+		outcome, prob = 1, p1
+	}
+
+	// Keep only the outcome read and renormalize
+	a := m.sites[m.center]
+	norm := complex(1/math.Sqrt(prob), 0)
+	for l := 0; l < a.left; l++ {
+		for s := 0; s < 2; s++ {
+			for r := 0; r < a.right; r++ {
+				if s == outcome {
+					a.data[(l*2+s)*a.right+r] *= norm
+				} else {
+					a.data[(l*2+s)*a.right+r] = 0
+				}
+			}
+		}
+	}
+	return outcome
+}
+
+func (m *mps) reset(qubit int, u float64) {
+	if m.measure(qubit, u) == 1 {
+		m.applyLocal(X, []int{qubit}, nil, nil)
+	}
+}
+
+// Applies one Kraus operator picked at random, as applyKrausTrajectory does on a state vector
+func (m *mps) channel(ops []Matrix, targets []int, u float64) {
+	order, first := m.gather(targets)
+	m.applyBlock(first, len(order), func(block []complex128, left, right int) {
+		candidate := make([]complex128, len(block))
+		applyOp := func(k Matrix) float64 {
+			copy(candidate, block)
+			applyPhysical(candidate, left, right, localOperator(k, order, targets, nil, nil))
+			prob := 0.0
+			for _, x := range candidate {
+				prob += real(x)*real(x) + imag(x)*imag(x)
+			}
+			return prob
+		}
+
+		probs := make([]float64, len(ops))
+		total := 0.0
+		for i, k := range ops {
+			probs[i] = applyOp(k)
+			total += probs[i]
+		}
+
+		chosen, cumulative := -1, 0.0
+		for i, prob := range probs {
+			if prob == 0 {
+				continue
+			}
+			chosen = i
+			cumulative += prob / total
+			if u < cumulative {
+				break
+			}
+		}
+
+		prob := applyOp(ops[chosen])
+		norm := complex(math.Sqrt(total/prob), 0)
+		for i := range block {
+			block[i] = candidate[i] * norm
+		}
+	})
+}
+
+// Keeps the first cols columns of a matrix
+func columns(a Matrix, cols int) *Matrix {
+	out := Matrix{Rows: a.Rows, Cols: cols, Stride: cols, Data: make([]complex128, a.Rows*cols)}
+	for r := 0; r < a.Rows; r++ {
+		copy(out.Data[r*cols:(r+1)*cols], a.Data[r*a.Stride:r*a.Stride+cols])
+	}
+	return &out
+}
+
+// Multiplies each row of a matrix by a factor, as diag(f) A
+func scaleRows(a Matrix, f []float64) Matrix {
+	for r := 0; r < a.Rows; r++ {
+		for c := 0; c < a.Cols; c++ {
+			a.Data[r*a.Stride+c] *= complex(f[r], 0)
+		}
+	}
+	return a
+}
+
+// Multiplies each column of a matrix by a factor, as A diag(f)
+func scaleColumns(a Matrix, f []float64) Matrix {
+	for r := 0; r < a.Rows; r++ {
+		for c := 0; c < a.Cols; c++ {
+			a.Data[r*a.Stride+c] *= complex(f[c], 0)
+		}
+	}
+	return a
+}
+
+// Limits the bond dimension of matrix product states built by ExecMPS to chi,
+// truncating the smallest singular values beyond it
+func WithMaxBondDimension(chi int) ExecOption {
+	return func(cfg *execConfig) {
+		cfg.maxBond = chi
+	}
+}
+
+// Drops the smallest singular values of each truncation in ExecMPS as long as their
+// total weight, the sum of their squares relative to all of them, is at most threshold
+func WithTruncationThreshold(threshold float64) ExecOption {
+	return func(cfg *execConfig) {
+		cfg.truncation = threshold
+	}
+}
+
+type MPSExecution struct {
+	m      *mps
+	clbits []int
+	noise  *NoiseModel
+}
+
+// Executes the circuit on a matrix product state, whose memory grows with the entanglement
+// of the register rather than exponentially with its number of qubits, so shallow circuits
+// on 50-100 qubits can be simulated. The bond dimension and truncation threshold are set
+// with WithMaxBondDimension and WithTruncationThreshold; by default nothing is truncated.
+// Gates on qubits far apart are applied by swapping them next to each other.
+// Noise runs as a single stochastic trajectory, as with Exec, and WithDensityMatrix is ignored.
+// Returns an error for gates acting on the whole register with anything but the identity.
+func (qc *QuantumCircuit) ExecMPS(qubitStates []Ket, opts ...ExecOption) (*MPSExecution, error) {
+	if len(qubitStates) != qc.numQubits {
+		return nil, fmt.Errorf("cannot execute qubitStates of size %v on circuit with %v qubits", len(qubitStates), qc.numQubits)
+	}
+
+	cfg := &execConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.maxBond < 0 {
+		return nil, fmt.Errorf("maximum bond dimension %v is negative", cfg.maxBond)
+	}
+
+	for i := range qc.gates {
+		g := &qc.gates[i]
+		if g.targets == nil && !isWholeRegisterIdentity(g) {
+			return nil, fmt.Errorf("gate %v: %v acts on the whole register", i, g.Name())
+		}
+	}
+
+	m := newMPS(qubitStates, cfg.maxBond, cfg.truncation)
+	clbits := qc.run(m, cfg)
+	return &MPSExecution{m: m, clbits: clbits, noise: cfg.noise}, nil
+}
+
+// Number of qubits in the executed circuit
+func (me *MPSExecution) NumQubits() int {
+	return me.m.numQubits
+}
+
+// Values of the classical bits after execution, each 0 or 1
+func (me *MPSExecution) Clbits() []int {
+	return me.clbits
+}
+
+// Total weight of the singular values dropped by truncations during execution. The
+// fidelity of the output state with the exact one is roughly at least 1 minus this.
+func (me *MPSExecution) TruncationError() float64 {
+	return me.m.discarded
+}
+
+// Largest bond dimension reached during execution
+func (me *MPSExecution) MaxBondDimension() int {
+	return me.m.maxBondSeen
+}
+
+// Probability that measuring a particular qubit in the standard basis will return ON
+func (me *MPSExecution) MeasureProbabilityOn(qubit int) float64 {
+	p0, p1 := me.m.qubitProbabilities(qubit)
+	return p1 / (p0 + p1)
+}
+
+// Amplitude of the output state on the basis state given as a bitstring with qubit 0 first
+func (me *MPSExecution) Amplitude(bits string) complex128 {
+	m := me.m
+	if len(bits) != m.numQubits {
+		panic(fmt.Sprintf("Bitstring %q does not have %v qubits", bits, m.numQubits))
+	}
+
+	env := []complex128{1}
+	for site, a := range m.sites {
+		s := int(bits[m.qubitAt[site]] - '0')
+		next := make([]complex128, a.right)
+		for l, e := range env {
+			for r := range next {
+				next[r] += e * a.data[(l*2+s)*a.right+r]
+			}
+		}
+		env = next
+	}
+	return env[0]
+}
+
+// Draws shots measurements of every qubit in the standard basis from the output state,
+// one site at a time. Returns how many times each outcome was seen, keyed by bitstring
+// with qubit 0 first. Results are reproducible for a given seed of rng; a nil rng uses
+// the global source. Readout errors of the noise model the circuit was executed with
+// corrupt each shot.
+func (me *MPSExecution) Sample(shots int, rng *rand.Rand) map[string]int {
+	draw := (&execConfig{rng: rng}).float64
+	m := me.m
+
+	// With the center on the first site every later site is right-orthonormal, so the
+	// probability of each outcome of a site follows from the sites before it alone
+	m.moveCenter(0)
+
+	counts := map[string]int{}
+	outcome := make([]byte, m.numQubits)
+	for shot := 0; shot < shots; shot++ {
+		env := []complex128{1}
+		for site, a := range m.sites {
+			var next [2][]complex128
+			var p [2]float64
+			for s := 0; s < 2; s++ {
+				next[s] = make([]complex128, a.right)
+				for l, e := range env {
+					for r := range next[s] {
+						next[s][r] += e * a.data[(l*2+s)*a.right+r]
+					}
+				}
+				for _, x := range next[s] {
+					p[s] += real(x)*real(x) + imag(x)*imag(x)
+				}
+			}
+
+			s := 0
+			if draw() < p[1]/(p[0]+p[1]) {
+				s = 1
+			}
+			norm := complex(1/math.Sqrt(p[s]), 0)
+			for r := range next[s] {
+				next[s][r] *= norm
+			}
+			env = next[s]
+
+			q := m.qubitAt[site]
+			if me.noise != nil {
+				s = me.noise.read(q, s, draw())
+			}
+			outcome[q] = byte('0' + s)
+		}
+		counts[string(outcome)]++
+	}
+	return counts
+}
@@ -0,0 +1,189 @@
+package sim
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_ExecMPS(t *testing.T) {
+	t.Run("Matches the state vector", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(9))
+		numQubits := 6
+		for trial := 0; trial < 10; trial++ {
+			qc := NewQuantumCircuit(numQubits)
+			for i := 0; i < 30; i++ {
+				a := rng.Intn(numQubits)
+				b := (a + 1 + rng.Intn(numQubits-1)) % numQubits
+				c := (b + 1 + rng.Intn(numQubits-2)) % numQubits
+				if c == a {
+					c = (c + 1) % numQubits
+					if c == b {
+						c = (c + 1) % numQubits
+					}
+				}
+				switch rng.Intn(8) {
+				case 0:
+					qc.H([]int{a, b})
+				case 1:
+					qc.RY(rng.Float64()*math.Pi, a)
+				case 2:
+					qc.U(rng.Float64(), rng.Float64(), rng.Float64(), a)
+				case 3:
+					qc.CX(a, b)
+				case 4:
+					qc.CP(rng.Float64(), a, b)
+				case 5:
+					qc.CCX(a, b, c)
+				case 6:
+					qc.CSWAP(NegControl(a), b, c)
+				case 7:
+					qc.ISWAP(a, b)
+				}
+			}
+			in := []Ket{ZeroKet, OneKet, HPlusKet, ZeroKet, HMinusKet, ZeroKet}
+
+			me, err := qc.ExecMPS(in)
+			if err != nil {
+				t.Fatalf("ExecMPS() error = %v", err)
+			}
+			want := qc.Exec(in).out
+			for i, amp := range want.Data {
+				if got := me.Amplitude(bitString(i, numQubits)); cmplx.Abs(got-amp) > 1e-8 {
+					t.Fatalf("Amplitude(%v) = %v, want %v", bitString(i, numQubits), got, amp)
+				}
+			}
+			if me.TruncationError() > 1e-12 {
+				t.Errorf("TruncationError() = %v without truncation", me.TruncationError())
+			}
+			for q := 0; q < numQubits; q++ {
+				if got, want := me.MeasureProbabilityOn(q), qc.Exec(in).MeasureProbabilityOn(q); !floatEqual(got, want, 1e-8) {
+					t.Errorf("MeasureProbabilityOn(%v) = %v, want %v", q, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("Truncating a GHZ state", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0})
+		qc.CX(0, 1)
+		qc.CX(1, 2)
+
+		me, err := qc.ExecMPS([]Ket{ZeroKet, ZeroKet, ZeroKet}, WithMaxBondDimension(1))
+		if err != nil {
+			t.Fatalf("ExecMPS() error = %v", err)
+		}
+		if got := me.TruncationError(); !floatEqual(got, 0.5, 1e-8) {
+			t.Errorf("TruncationError() = %v, want 0.5", got)
+		}
+		if got := me.MaxBondDimension(); got != 1 {
+			t.Errorf("MaxBondDimension() = %v, want 1", got)
+		}
+
+		me, err = qc.ExecMPS([]Ket{ZeroKet, ZeroKet, ZeroKet}, WithTruncationThreshold(0.6))
+		if err != nil {
+			t.Fatalf("ExecMPS() error = %v", err)
+		}
+		if got := cmplx.Abs(me.Amplitude("000")); !floatEqual(got, 1, 1e-8) {
+			t.Errorf("|Amplitude(000)| = %v after truncating the smaller half, want 1", got)
+		}
+	})
+
+	t.Run("Large shallow circuit", func(t *testing.T) {
+		numQubits := 80
+		qc := NewQuantumCircuit(numQubits)
+		in := make([]Ket, numQubits)
+		for q := range in {
+			in[q] = ZeroKet
+		}
+		qc.H([]int{0})
+		for q := 1; q < numQubits; q++ {
+			qc.CX(q-1, q)
+		}
+		qc.CZ(0, numQubits-1)
+
+		me, err := qc.ExecMPS(in)
+		if err != nil {
+			t.Fatalf("ExecMPS() error = %v", err)
+		}
+		if got := me.MaxBondDimension(); got > 2 {
+			t.Errorf("MaxBondDimension() = %v, want at most 2", got)
+		}
+		if got := me.MeasureProbabilityOn(numQubits / 2); !floatEqual(got, 0.5, 1e-8) {
+			t.Errorf("MeasureProbabilityOn() = %v, want 0.5", got)
+		}
+		counts := me.Sample(50, rand.New(rand.NewSource(1)))
+		zeros, ones := strings.Repeat("0", numQubits), strings.Repeat("1", numQubits)
+		if counts[zeros]+counts[ones] != 50 || counts[zeros] == 0 || counts[ones] == 0 {
+			t.Errorf("Sample() has %v all-zero and %v all-one outcomes of 50", counts[zeros], counts[ones])
+		}
+	})
+
+	t.Run("Measurement, reset and noise", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0})
+		qc.CX(0, 2)
+		qc.Measure(2, 0)
+		qc.If(0, 1).X(1)
+		qc.Reset(2)
+		qc.BitFlip(1, 2)
+
+		rng := rand.New(rand.NewSource(2))
+		for shot := 0; shot < 10; shot++ {
+			me, err := qc.ExecMPS([]Ket{ZeroKet, ZeroKet, ZeroKet}, WithRand(rng))
+			if err != nil {
+				t.Fatalf("ExecMPS() error = %v", err)
+			}
+			c := float64(me.Clbits()[0])
+			for q, want := range []float64{c, c, 1} {
+				if got := me.MeasureProbabilityOn(q); !floatEqual(got, want, 1e-8) {
+					t.Fatalf("MeasureProbabilityOn(%v) = %v with clbit %v, want %v", q, got, c, want)
+				}
+			}
+		}
+	})
+
+	t.Run("Rejects whole register gates", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		inner := NewQuantumCircuit(2)
+		inner.H([]int{0})
+		addCompiled(&qc, inner)
+		if _, err := qc.ExecMPS([]Ket{ZeroKet, ZeroKet}); err == nil {
+			t.Errorf("ExecMPS() with a combined gate on the whole register should fail")
+		}
+	})
+
+	t.Run("Panics on other whole register gates applied directly", func(t *testing.T) {
+		inner := NewQuantumCircuit(2)
+		inner.H([]int{0})
+		inner.Compile()
+		m := newMPS([]Ket{ZeroKet, ZeroKet}, 0, 0)
+		m.apply(createWire(2))
+		defer func() {
+			if recover() == nil {
+				t.Errorf("apply() of a compiled circuit did not panic")
+			}
+		}()
+		m.apply(&inner.compiled)
+	})
+
+	t.Run("Skips identity wires", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.addGate(*createWire(2))
+		qc.H([]int{0})
+		qc.CX(0, 1)
+		qc.addGate(*createWire(2))
+		me, err := qc.ExecMPS([]Ket{ZeroKet, ZeroKet})
+		if err != nil {
+			t.Fatalf("ExecMPS() error = %v", err)
+		}
+		for _, bits := range []string{"00", "11"} {
+			if got := me.Amplitude(bits); !floatEqual(real(got), 1/math.Sqrt2, 1e-8) || !floatEqual(imag(got), 0, 1e-8) {
+				t.Errorf("Amplitude(%q) = %v, want 1/√2", bits, got)
+			}
+		}
+	})
+}
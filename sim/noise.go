@@ -0,0 +1,275 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+// A noise channel rho -> sum K rho K† given by its Kraus operators K, which act
+// jointly on the channel's qubits. Channels are trace preserving: sum K†K = I.
+type Channel struct {
+	name   GateName
+	label  string
+	params []float64
+	kraus  []Matrix
+}
+
+// Kraus operators of the channel
+func (ch Channel) Kraus() []Matrix {
+	return ch.kraus
+}
+
+// Number of qubits the channel acts on
+func (ch Channel) NumQubits() int {
+	return int(math.Log2(float64(ch.kraus[0].Rows)))
+}
+
+// Scales a matrix by a real factor
+func scaled(m Matrix, f float64) Matrix {
+	out := Matrix{
+		Rows:   m.Rows,
+		Cols:   m.Cols,
+		Stride: m.Cols,
+		Data:   make([]complex128, m.Rows*m.Cols),
+	}
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			out.Data[r*out.Stride+c] = m.Data[r*m.Stride+c] * complex(f, 0)
+		}
+	}
+	return out
+}
+
+func checkProbability(name string, p float64) {
+	if p < 0 || p > 1 {
+		panic(fmt.Sprintf("%v probability %v is not between 0 and 1", name, p))
+	}
+}
+
+// Single-qubit depolarizing channel rho -> (1-p) rho + p I/2,
+// replacing the state with the maximally mixed state with probability p
+func DepolarizingChannel(p float64) Channel {
+	checkProbability("Depolarizing", p)
+	return Channel{
+		name:   DEPOLARIZING,
+		params: []float64{p},
+		kraus: []Matrix{
+			scaled(I, math.Sqrt(1-3*p/4)),
+			scaled(X, math.Sqrt(p/4)),
+			scaled(Y, math.Sqrt(p/4)),
+			scaled(Z, math.Sqrt(p/4)),
+		},
+	}
+}
+
+// Single-qubit amplitude damping channel, decaying |1> to |0> with probability gamma
+func AmplitudeDampingChannel(gamma float64) Channel {
+	checkProbability("Amplitude damping", gamma)
+	return Channel{
+		name:   AMPLITUDEDAMPING,
+		params: []float64{gamma},
+		kraus: []Matrix{
+			{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{1, 0, 0, complex(math.Sqrt(1-gamma), 0)}},
+			{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{0, complex(math.Sqrt(gamma), 0), 0, 0}},
+		},
+	}
+}
+
+// Single-qubit phase damping channel, shrinking the coherences between |0> and |1>
+// by a factor of sqrt(1-lambda) without any change in populations
+func PhaseDampingChannel(lambda float64) Channel {
+	checkProbability("Phase damping", lambda)
+	return Channel{
+		name:   PHASEDAMPING,
+		params: []float64{lambda},
+		kraus: []Matrix{
+			{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{1, 0, 0, complex(math.Sqrt(1-lambda), 0)}},
+			{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{0, 0, 0, complex(math.Sqrt(lambda), 0)}},
+		},
+	}
+}
+
+// Single-qubit bit flip channel, applying X with probability p
+func BitFlipChannel(p float64) Channel {
+	checkProbability("Bit flip", p)
+	return Channel{
+		name:   BITFLIP,
+		params: []float64{p},
+		kraus:  []Matrix{scaled(I, math.Sqrt(1-p)), scaled(X, math.Sqrt(p))},
+	}
+}
+
+// Single-qubit phase flip channel, applying Z with probability p
+func PhaseFlipChannel(p float64) Channel {
+	checkProbability("Phase flip", p)
+	return Channel{
+		name:   PHASEFLIP,
+		params: []float64{p},
+		kraus:  []Matrix{scaled(I, math.Sqrt(1-p)), scaled(Z, math.Sqrt(p))},
+	}
+}
+
+// Channel given by an arbitrary list of Kraus operators, named by label.
+// Returns an error unless the operators are square, of equal size 2^k
+// and trace preserving.
+func KrausChannel(ops []Matrix, label string) (Channel, error) {
+	if len(ops) == 0 {
+		return Channel{}, fmt.Errorf("channel %q has no Kraus operators", label)
+	}
+
+	size := ops[0].Rows
+	if size < 2 || size&(size-1) != 0 {
+		return Channel{}, fmt.Errorf("Kraus operators of channel %q are %vx%v, not a power of two", label, size, size)
+	}
+
+	sum := Matrix{
+		Rows:   size,
+		Cols:   size,
+		Stride: size,
+		Data:   make([]complex128, size*size),
+	}
+	for i, k := range ops {
+		if k.Rows != size || k.Cols != size {
+			return Channel{}, fmt.Errorf("Kraus operator %v of channel %q is %vx%v, want %vx%v", i, label, k.Rows, k.Cols, size, size)
+		}
+		sum = *sum.Add(*k.Dagger().Mul(k))
+	}
+
+	if !sum.Equals(Identity(size), complex(unitaryTolerance, unitaryTolerance)) {
+		return Channel{}, fmt.Errorf("channel %q is not trace preserving", label)
+	}
+
+	return Channel{
+		name:  KRAUS,
+		label: label,
+		kraus: append([]Matrix{}, ops...),
+	}, nil
+}
+
+// Creates a gate applying the channel jointly to the given qubits
+func createChannel(ch Channel, qubits []int, numQubits int) (*Gate, error) {
+	if len(ch.kraus) == 0 {
+		return nil, fmt.Errorf("channel has no Kraus operators")
+	}
+	if ch.NumQubits() != len(qubits) {
+		return nil, fmt.Errorf("%v-qubit channel cannot act on %v qubits", ch.NumQubits(), len(qubits))
+	}
+
+	seen := map[int]bool{}
+	for _, q := range qubits {
+		if q < 0 || q >= numQubits {
+			return nil, fmt.Errorf("qubit %v is out of range for %v qubits", q, numQubits)
+		}
+		if seen[q] {
+			return nil, fmt.Errorf("qubit %v used more than once in channel", q)
+		}
+		seen[q] = true
+	}
+
+	return &Gate{
+		name:    ch.name,
+		label:   ch.label,
+		params:  ch.params,
+		targets: append([]int{}, qubits...),
+		kraus:   ch.kraus,
+	}, nil
+}
+
+// Adds a noise channel acting jointly on the given qubits to the circuit.
+// Returns an error if the channel does not act on that many qubits.
+func (qc *QuantumCircuit) AddChannel(ch Channel, qubits ...int) error {
+	g, err := createChannel(ch, qubits, qc.numQubits)
+	if err != nil {
+		return err
+	}
+	qc.addGate(*g)
+	return nil
+}
+
+func (qc *QuantumCircuit) addSingleQubitChannel(ch Channel, qubit int) {
+	if err := qc.AddChannel(ch, qubit); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Adds a depolarizing channel with probability p to the qubit
+func (qc *QuantumCircuit) Depolarizing(p float64, qubit int) {
+	qc.addSingleQubitChannel(DepolarizingChannel(p), qubit)
+}
+
+// Adds an amplitude damping channel with decay probability gamma to the qubit
+func (qc *QuantumCircuit) AmplitudeDamping(gamma float64, qubit int) {
+	qc.addSingleQubitChannel(AmplitudeDampingChannel(gamma), qubit)
+}
+
+// Adds a phase damping channel with parameter lambda to the qubit
+func (qc *QuantumCircuit) PhaseDamping(lambda float64, qubit int) {
+	qc.addSingleQubitChannel(PhaseDampingChannel(lambda), qubit)
+}
+
+// Adds a bit flip channel with probability p to the qubit
+func (qc *QuantumCircuit) BitFlip(p float64, qubit int) {
+	qc.addSingleQubitChannel(BitFlipChannel(p), qubit)
+}
+
+// Adds a phase flip channel with probability p to the qubit
+func (qc *QuantumCircuit) PhaseFlip(p float64, qubit int) {
+	qc.addSingleQubitChannel(PhaseFlipChannel(p), qubit)
+}
+
+// Adds a channel given by arbitrary Kraus operators acting jointly on the qubits.
+// Returns an error if the operators are not trace preserving or do not match the qubits.
+func (qc *QuantumCircuit) Kraus(ops []Matrix, qubits []int, label string) error {
+	ch, err := KrausChannel(ops, label)
+	if err != nil {
+		return err
+	}
+	return qc.AddChannel(ch, qubits...)
+}
+
+// Applies one Kraus operator of a channel to the state vector, picked at random with
+// probability ||K psi||^2, and renormalizes the state. Averaged over many shots this
+// reproduces the channel, as a stochastic trajectory.
+// u is a uniform random number in [0, 1) deciding which operator is picked.
+func applyKrausTrajectory(state ColVec, numQubits int, ops []Matrix, targets []int, u float64) {
+	candidate := make([]complex128, len(state.Data))
+	applyOp := func(k Matrix) float64 {
+		copy(candidate, state.Data)
+		applyKernel(candidate, numQubits, k, targets, nil, nil)
+
+		prob := 0.0
+		for _, amp := range candidate {
+			prob += real(amp)*real(amp) + imag(amp)*imag(amp)
+		}
+		return prob
+	}
+
+	probs := make([]float64, len(ops))
+	for i, k := range ops {
+		probs[i] = applyOp(k)
+	}
+
+	// Operators that cannot happen are skipped, and the last possible one
+	// takes whatever probability is left through rounding
+	chosen, cumulative := -1, 0.0
+	for i, prob := range probs {
+		if prob == 0 {
+			continue
+		}
+		chosen = i
+		cumulative += prob
+		if u < cumulative {
+			break
+		}
+	}
+
+	if chosen < 0 {
+		panic(fmt.Sprintf("Cannot pick a Kraus operator: each has probability 0 on a state of norm %v", stateNorm(state.Data)))
+	}
+
+	prob := applyOp(ops[chosen])
+	norm := complex(1/math.Sqrt(prob), 0)
+	for i := range candidate {
+		state.Data[i] = candidate[i] * norm
+	}
+}
@@ -0,0 +1,158 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestChannels_TracePreserving(t *testing.T) {
+	tests := []struct {
+		name string
+		ch   Channel
+	}{
+		{name: "Depolarizing", ch: DepolarizingChannel(0.3)},
+		{name: "Amplitude damping", ch: AmplitudeDampingChannel(0.25)},
+		{name: "Phase damping", ch: PhaseDampingChannel(0.6)},
+		{name: "Bit flip", ch: BitFlipChannel(0.1)},
+		{name: "Phase flip", ch: PhaseFlipChannel(0.9)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := KrausChannel(tt.ch.Kraus(), tt.name); err != nil {
+				t.Errorf("KrausChannel() error = %v", err)
+			}
+			if got := tt.ch.NumQubits(); got != 1 {
+				t.Errorf("NumQubits() = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestKrausChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []Matrix
+		wantErr bool
+	}{
+		{name: "Unitary", ops: []Matrix{H}},
+		{name: "Two-qubit", ops: []Matrix{scaled(Swap, math.Sqrt(0.5)), scaled(ISwap, math.Sqrt(0.5))}},
+		{name: "No operators", ops: nil, wantErr: true},
+		{name: "Not trace preserving", ops: []Matrix{X, Z}, wantErr: true},
+		{name: "Mismatched sizes", ops: []Matrix{scaled(X, math.Sqrt(0.5)), scaled(Swap, math.Sqrt(0.5))}, wantErr: true},
+		{name: "Not a power of two", ops: []Matrix{Identity(3)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := KrausChannel(tt.ops, tt.name); (err != nil) != tt.wantErr {
+				t.Errorf("KrausChannel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuantumCircuit_AddChannel(t *testing.T) {
+	qc := NewQuantumCircuit(2)
+	if err := qc.AddChannel(DepolarizingChannel(0.1), 0, 1); err == nil {
+		t.Errorf("AddChannel() of a single-qubit channel on two qubits should fail")
+	}
+	if err := qc.AddChannel(BitFlipChannel(0.1), 2); err == nil {
+		t.Errorf("AddChannel() on an out of range qubit should fail")
+	}
+	if err := qc.Kraus([]Matrix{X, Z}, []int{0}, "bad"); err == nil {
+		t.Errorf("Kraus() with operators that are not trace preserving should fail")
+	}
+	if err := qc.AddChannel(BitFlipChannel(0.1), 1); err != nil {
+		t.Errorf("AddChannel() error = %v", err)
+	}
+	if got := qc.gates[0].Name(); got != "Bit flip" {
+		t.Errorf("Name() = %v, want Bit flip", got)
+	}
+	if qc.gates[0].IsUnitary() {
+		t.Errorf("IsUnitary() = true for a channel")
+	}
+}
+
+func TestQuantumCircuit_ExecChannels(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+		in    []Ket
+		want  []complex128
+	}{
+		{
+			name:  "Amplitude damping decays |1>",
+			build: func(qc *QuantumCircuit) { qc.AmplitudeDamping(0.3, 0) },
+			in:    []Ket{OneKet},
+			want:  []complex128{0.3, 0, 0, 0.7},
+		},
+		{
+			name:  "Full depolarizing gives the maximally mixed state",
+			build: func(qc *QuantumCircuit) { qc.Depolarizing(1, 0) },
+			in:    []Ket{HPlusKet},
+			want:  []complex128{0.5, 0, 0, 0.5},
+		},
+		{
+			name:  "Full phase damping removes coherences",
+			build: func(qc *QuantumCircuit) { qc.PhaseDamping(1, 0) },
+			in:    []Ket{HPlusKet},
+			want:  []complex128{0.5, 0, 0, 0.5},
+		},
+		{
+			name:  "Phase flip shrinks coherences",
+			build: func(qc *QuantumCircuit) { qc.PhaseFlip(0.25, 0) },
+			in:    []Ket{HPlusKet},
+			want:  []complex128{0.5, 0.25, 0.25, 0.5},
+		},
+		{
+			name:  "Bit flip mixes populations",
+			build: func(qc *QuantumCircuit) { qc.BitFlip(0.2, 0) },
+			in:    []Ket{ZeroKet},
+			want:  []complex128{0.8, 0, 0, 0.2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(len(tt.in))
+			tt.build(&qc)
+			want := Matrix{Rows: 2, Cols: 2, Stride: 2, Data: tt.want}
+
+			if got := qc.Exec(tt.in, WithDensityMatrix()).DensityMatrix(); !got.Equals(want, StdEpsilon) {
+				t.Errorf("DensityMatrix() = %v, want %v", got, want)
+			}
+
+			// Averaging trajectories approaches the exact density matrix
+			shots := 4000
+			rng := rand.New(rand.NewSource(3))
+			avg := Matrix{Rows: 2, Cols: 2, Stride: 2, Data: make([]complex128, 4)}
+			for i := 0; i < shots; i++ {
+				avg = *avg.Add(scaled(qc.Exec(tt.in, WithRand(rng)).DensityMatrix(), 1/float64(shots)))
+			}
+			if !avg.Equals(want, complex(0.03, 0.03)) {
+				t.Errorf("averaged trajectories = %v, want %v", avg, want)
+			}
+		})
+	}
+}
+
+func TestApplyKrausTrajectory(t *testing.T) {
+	// Amplitude damping on |+> can only jump to |0> through the second operator
+	state := KronKets([]Ket{HPlusKet})
+	applyKrausTrajectory(state, 1, AmplitudeDampingChannel(0.5).Kraus(), []int{0}, 0.99)
+	want := NewColVec(Matrix{Rows: 2, Cols: 1, Stride: 1, Data: []complex128{1, 0}})
+	if !Matrix(state).Equals(Matrix(want), StdEpsilon) {
+		t.Errorf("state after applyKrausTrajectory() = %v, want %v", state, want)
+	}
+
+	t.Run("Panics when no operator can happen", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if msg, ok := r.(string); !ok || !strings.Contains(msg, "probability 0") {
+				t.Errorf("applyKrausTrajectory() panics with %v, want an explicit message", r)
+			}
+		}()
+		zero := NewColVec(Matrix{Rows: 2, Cols: 1, Stride: 1, Data: []complex128{0, 0}})
+		applyKrausTrajectory(zero, 1, BitFlipChannel(0.5).Kraus(), []int{0}, 0.5)
+	})
+}
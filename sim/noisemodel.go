@@ -0,0 +1,189 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+// Noise of a device, applied to an ideal circuit when it is executed with WithNoise.
+// Gate errors are channels applied after every gate of a given name, and readout
+// errors are confusion matrices that corrupt measured bits.
+// The same circuit can be executed under several noise models.
+type NoiseModel struct {
+	gateErrors map[GateName][]gateError
+	readout    map[int]Matrix
+}
+
+// Channel applied after gates of one name. A nil qubits matches every gate of that name.
+type gateError struct {
+	qubits  []int
+	channel Channel
+}
+
+func NewNoiseModel() *NoiseModel {
+	return &NoiseModel{
+		gateErrors: map[GateName][]gateError{},
+		readout:    map[int]Matrix{},
+	}
+}
+
+// Applies the channel after every gate with the given name. With no qubits, it applies
+// wherever the gate acts; otherwise only where the gate acts on exactly those qubits,
+// controls first, in which case it replaces any error added without qubits.
+// A single-qubit channel is applied to each qubit of the gate, and a larger channel
+// jointly to all of them, which must match in number.
+func (nm *NoiseModel) AddGateError(name GateName, ch Channel, qubits ...int) error {
+	if len(ch.kraus) == 0 {
+		return fmt.Errorf("channel has no Kraus operators")
+	}
+	if len(qubits) > 0 && ch.NumQubits() != 1 && ch.NumQubits() != len(qubits) {
+		return fmt.Errorf("%v-qubit channel cannot act on %v qubits", ch.NumQubits(), len(qubits))
+	}
+	for _, q := range qubits {
+		if q < 0 {
+			return fmt.Errorf("qubit %v is out of range", q)
+		}
+	}
+
+	var qs []int
+	if len(qubits) > 0 {
+		qs = append([]int{}, qubits...)
+	}
+	nm.gateErrors[name] = append(nm.gateErrors[name], gateError{qubits: qs, channel: ch})
+	return nil
+}
+
+// Sets the readout error of a qubit as a 2x2 confusion matrix whose entry [r][t] is the
+// probability of reading r when the qubit is t. Each column must sum to 1.
+func (nm *NoiseModel) AddReadoutError(qubit int, confusion Matrix) error {
+	if qubit < 0 {
+		return fmt.Errorf("qubit %v is out of range", qubit)
+	}
+	if confusion.Rows != 2 || confusion.Cols != 2 {
+		return fmt.Errorf("readout error of qubit %v is %vx%v, want 2x2", qubit, confusion.Rows, confusion.Cols)
+	}
+	for c := 0; c < 2; c++ {
+		sum := 0.0
+		for r := 0; r < 2; r++ {
+			p := confusion.Data[r*confusion.Stride+c]
+			if imag(p) != 0 || real(p) < 0 || real(p) > 1 {
+				return fmt.Errorf("readout error of qubit %v has entry %v that is not a probability", qubit, p)
+			}
+			sum += real(p)
+		}
+		if math.Abs(sum-1) > unitaryTolerance {
+			return fmt.Errorf("column %v of readout error of qubit %v sums to %v, not 1", c, qubit, sum)
+		}
+	}
+
+	nm.readout[qubit] = Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data: []complex128{
+			confusion.Data[0], confusion.Data[1],
+			confusion.Data[confusion.Stride], confusion.Data[confusion.Stride+1],
+		},
+	}
+	return nil
+}
+
+// Readout error of a qubit where reading 0 as 1 has probability p0 and 1 as 0 has probability p1
+func ReadoutError(p0, p1 float64) Matrix {
+	checkProbability("Readout", p0)
+	checkProbability("Readout", p1)
+	return Matrix{
+		Rows:   2,
+		Cols:   2,
+		Stride: 2,
+		Data: []complex128{
+			complex(1-p0, 0), complex(p1, 0),
+			complex(p0, 0), complex(1-p1, 0),
+		},
+	}
+}
+
+// Executes the circuit under the noise model
+func WithNoise(nm *NoiseModel) ExecOption {
+	return func(cfg *execConfig) {
+		cfg.noise = nm
+	}
+}
+
+// Channels to apply after the gate on a register of numQubits qubits, each as a
+// gate acting on the qubits it affects
+func (nm *NoiseModel) errorsAfter(g *Gate, numQubits int) []Gate {
+	rules := nm.gateErrors[g.name]
+	if len(rules) == 0 {
+		return nil
+	}
+
+	qubits := g.Qubits()
+	if g.targets == nil {
+		qubits = make([]int, numQubits)
+		for i := range qubits {
+			qubits[i] = i
+		}
+	}
+
+	// Errors given for these exact qubits replace the ones given for any qubits
+	var matched []gateError
+	for _, rule := range rules {
+		if rule.qubits != nil && intsEqual(rule.qubits, qubits) {
+			matched = append(matched, rule)
+		}
+	}
+	if matched == nil {
+		for _, rule := range rules {
+			if rule.qubits == nil {
+				matched = append(matched, rule)
+			}
+		}
+	}
+
+	var out []Gate
+	for _, rule := range matched {
+		ch := rule.channel
+		if ch.NumQubits() == 1 {
+			for _, q := range qubits {
+				out = append(out, Gate{name: ch.name, label: ch.label, params: ch.params, targets: []int{q}, kraus: ch.kraus})
+			}
+		} else if ch.NumQubits() == len(qubits) {
+			out = append(out, Gate{name: ch.name, label: ch.label, params: ch.params, targets: qubits, kraus: ch.kraus})
+		}
+	}
+	return out
+}
+
+// Bit read for a qubit whose true value is bit, under the qubit's readout error.
+// u is a uniform random number in [0, 1) deciding whether the bit flips.
+func (nm *NoiseModel) read(qubit, bit int, u float64) int {
+	confusion, ok := nm.readout[qubit]
+	if !ok {
+		return bit
+	}
+	flip := real(confusion.Data[(1-bit)*confusion.Stride+bit])
+	if u < flip {
+		return 1 - bit
+	}
+	return bit
+}
+
+// Basis state read for a register in the basis state index, under the readout error of each qubit.
+// draw returns uniform random numbers in [0, 1).
+func (nm *NoiseModel) readAll(index, numQubits int, draw func() float64) int {
+	for q := 0; q < numQubits; q++ {
+		if _, ok := nm.readout[q]; !ok {
+			continue
+		}
+		mask := qubitMask(q, numQubits)
+		bit := 0
+		if index&mask != 0 {
+			bit = 1
+		}
+		if nm.read(q, bit, draw()) != bit {
+			index ^= mask
+		}
+	}
+	return index
+}
@@ -0,0 +1,127 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNoiseModel_GateErrors(t *testing.T) {
+	qc := NewQuantumCircuit(2)
+	qc.X(0)
+	qc.CX(0, 1)
+	in := []Ket{ZeroKet, ZeroKet}
+
+	t.Run("Ideal circuit is unchanged", func(t *testing.T) {
+		nm := NewNoiseModel()
+		got := qc.Exec(in, WithDensityMatrix(), WithNoise(nm)).MeasureProbabilities()
+		if want := []float64{0, 0, 0, 1}; !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Single-qubit error after each qubit of a gate", func(t *testing.T) {
+		nm := NewNoiseModel()
+		if err := nm.AddGateError(CX, BitFlipChannel(0.1)); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+		got := qc.Exec(in, WithDensityMatrix(), WithNoise(nm)).MeasureProbabilities()
+		want := []float64{0.1 * 0.1, 0.1 * 0.9, 0.9 * 0.1, 0.9 * 0.9}
+		if !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Errors for specific qubits replace general ones", func(t *testing.T) {
+		nm := NewNoiseModel()
+		if err := nm.AddGateError(PAULIX, AmplitudeDampingChannel(1)); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+		if err := nm.AddGateError(PAULIX, BitFlipChannel(0), 0); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+		got := qc.Exec(in, WithDensityMatrix(), WithNoise(nm)).MeasureProbabilities()
+		if want := []float64{0, 0, 0, 1}; !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Joint two-qubit error", func(t *testing.T) {
+		swapNoise, err := KrausChannel([]Matrix{scaled(Identity(4), math.Sqrt(0.5)), scaled(Swap, math.Sqrt(0.5))}, "swap noise")
+		if err != nil {
+			t.Fatalf("KrausChannel() error = %v", err)
+		}
+		nm := NewNoiseModel()
+		if err := nm.AddGateError(PAULIX, swapNoise, 0); err == nil {
+			t.Errorf("AddGateError() of a two-qubit channel on one qubit should fail")
+		}
+		if err := nm.AddGateError(CZ, swapNoise, 0, 1); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+
+		cz := NewQuantumCircuit(2)
+		cz.X(0)
+		cz.CZ(0, 1)
+		cz.CZ(1, 0)
+		got := cz.Exec(in, WithDensityMatrix(), WithNoise(nm)).MeasureProbabilities()
+		if want := []float64{0, 0.5, 0.5, 0}; !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Trajectories on the state vector", func(t *testing.T) {
+		nm := NewNoiseModel()
+		if err := nm.AddGateError(PAULIX, DepolarizingChannel(1)); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+		rng := rand.New(rand.NewSource(5))
+		on := 0
+		for shot := 0; shot < 2000; shot++ {
+			on += int(math.Round(qc.Exec(in, WithNoise(nm), WithRand(rng)).MeasureProbabilityOn(0)))
+		}
+		if math.Abs(float64(on)/2000-0.5) > 0.05 {
+			t.Errorf("qubit 0 read 1 in %v of 2000 shots, want about half", on)
+		}
+	})
+}
+
+func TestNoiseModel_ReadoutErrors(t *testing.T) {
+	nm := NewNoiseModel()
+	if err := nm.AddReadoutError(0, Identity(4)); err == nil {
+		t.Errorf("AddReadoutError() of a 4x4 matrix should fail")
+	}
+	if err := nm.AddReadoutError(0, Matrix{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{0.9, 0.2, 0.2, 0.8}}); err == nil {
+		t.Errorf("AddReadoutError() with columns not summing to 1 should fail")
+	}
+	if err := nm.AddReadoutError(1, ReadoutError(0, 1)); err != nil {
+		t.Fatalf("AddReadoutError() error = %v", err)
+	}
+
+	qc := NewQuantumCircuit(2)
+	qc.X(0)
+	qc.X(1)
+	qc.Measure(0, 0)
+	qc.Measure(1, 1)
+	in := []Ket{ZeroKet, ZeroKet}
+
+	qce := qc.Exec(in, WithNoise(nm))
+	if got, want := qce.Clbits(), []int{1, 0}; !intsEqual(got, want) {
+		t.Errorf("Clbits() = %v, want %v", got, want)
+	}
+	if got := qce.MeasureProbabilityOn(1); !floatEqual(got, 1, FloatEpsilon) {
+		t.Errorf("MeasureProbabilityOn(1) = %v, want the state to be unaffected by readout", got)
+	}
+	if got := qce.Sample(100, rand.New(rand.NewSource(1))); got["10"] != 100 {
+		t.Errorf("Sample() = %v, want 10 every time", got)
+	}
+
+	nm = NewNoiseModel()
+	if err := nm.AddReadoutError(0, ReadoutError(0.2, 0)); err != nil {
+		t.Fatalf("AddReadoutError() error = %v", err)
+	}
+	empty := NewQuantumCircuit(1)
+	counts := empty.Exec([]Ket{ZeroKet}, WithNoise(nm)).Sample(4000, rand.New(rand.NewSource(2)))
+	if math.Abs(float64(counts["1"])/4000-0.2) > 0.03 {
+		t.Errorf("Sample() = %v, want 1 about a fifth of the time", counts)
+	}
+}
@@ -0,0 +1,190 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+)
+
+// Largest register whose operator Operator builds. A 2^n x 2^n matrix of 12 qubits
+// already takes 256 MiB.
+const maxOperatorQubits = 12
+
+// Settings for building the operator of a circuit
+type operatorConfig struct {
+	normalize bool
+}
+
+// Optional setting passed to Operator
+type OperatorOption func(*operatorConfig)
+
+// Removes the global phase of the operator with NormalizeGlobalPhase, so that
+// circuits equal up to global phase give equal operators
+func WithNormalizedPhase() OperatorOption {
+	return func(cfg *operatorConfig) {
+		cfg.normalize = true
+	}
+}
+
+// Returns the 2^n x 2^n unitary the circuit applies to its register, with the same
+// qubit ordering as Exec: column j is the output for the basis state j as input.
+// Operator is what would be Unitary() (Matrix, error); it is renamed because
+// QuantumCircuit.Unitary already adds a custom unitary gate to the circuit.
+// Pass WithNormalizedPhase to remove the global phase, as NormalizeGlobalPhase does.
+// Returns an error if the circuit has measurements, resets, noise channels or
+// conditioned gates, or more than 12 qubits.
+func (qc *QuantumCircuit) Operator(opts ...OperatorOption) (Matrix, error) {
+	cfg := &operatorConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if qc.numQubits > maxOperatorQubits {
+		return Matrix{}, fmt.Errorf("operator of %v qubits is too large to build, the limit is %v", qc.numQubits, maxOperatorQubits)
+	}
+	for i := range qc.gates {
+		if !qc.gates[i].IsUnitary() {
+			return Matrix{}, fmt.Errorf("gate %v: %v is not unitary", i, qc.gates[i].Name())
+		}
+	}
+
+	// Each column is a basis state run through the circuit on the state vector engine
+	dim := 1 << uint(qc.numQubits)
+	op := Matrix{Rows: dim, Cols: dim, Stride: dim, Data: make([]complex128, dim*dim)}
+	col := NewColVec(Matrix{Rows: dim, Cols: 1, Stride: 1, Data: make([]complex128, dim)})
+	for c := 0; c < dim; c++ {
+		for i := range col.Data {
+			col.Data[i] = 0
+		}
+		col.Data[c] = 1
+		for i := range qc.gates {
+			applyGate(col, qc.numQubits, &qc.gates[i])
+		}
+		for r := 0; r < dim; r++ {
+			op.Data[r*op.Stride+c] = col.Data[r]
+		}
+	}
+	if cfg.normalize {
+		return NormalizeGlobalPhase(op), nil
+	}
+	return op, nil
+}
+
+// Removes the global phase of a matrix, multiplying it by the phase that makes its
+// first largest entry real and positive. Matrices equal up to global phase come out equal,
+// up to rounding.
+func NormalizeGlobalPhase(m Matrix) Matrix {
+	largest := 0.0
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			largest = math.Max(largest, cmplx.Abs(m.Data[r*m.Stride+c]))
+		}
+	}
+
+	out := Matrix{Rows: m.Rows, Cols: m.Cols, Stride: m.Cols, Data: make([]complex128, m.Rows*m.Cols)}
+	phase := complex128(1)
+	found := false
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			x := m.Data[r*m.Stride+c]
+			if !found && largest > 0 && cmplx.Abs(x) >= largest*(1-unitaryTolerance) {
+				phase = cmplx.Conj(x) / complex(cmplx.Abs(x), 0)
+				found = true
+			}
+			out.Data[r*out.Stride+c] = x
+		}
+	}
+	for i := range out.Data {
+		out.Data[i] *= phase
+	}
+	return out
+}
+
+// Are the matrices equal up to a global phase, A = e^(i*phi) B, with every entry within tol?
+func EqualUpToGlobalPhase(a, b Matrix, tol float64) bool {
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return false
+	}
+
+	// The phase best aligning B to A is that of their overlap tr(B†A)
+	var overlap complex128
+	for r := 0; r < a.Rows; r++ {
+		for c := 0; c < a.Cols; c++ {
+			overlap += cmplx.Conj(b.Data[r*b.Stride+c]) * a.Data[r*a.Stride+c]
+		}
+	}
+	phase := complex128(1)
+	if cmplx.Abs(overlap) > 0 {
+		phase = overlap / complex(cmplx.Abs(overlap), 0)
+	}
+
+	for r := 0; r < a.Rows; r++ {
+		for c := 0; c < a.Cols; c++ {
+			if cmplx.Abs(a.Data[r*a.Stride+c]-phase*b.Data[r*b.Stride+c]) > tol {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Number of random input states on which Equivalent compares circuits too large for Operator
+const equivalenceTrials = 4
+
+// Do the circuits apply the same unitary up to global phase, with every entry within tol?
+// Gate names and decompositions do not matter, only the operators they build.
+// Circuits of more than 12 qubits whose gates are all Clifford are compared exactly by
+// their stabilizer tableaux, whatever their size. Other circuits of more than 12 qubits
+// are run on a few random input states, whose outputs must agree up to global phase within
+// tol in every amplitude; a random state is an eigenvector of the difference V†U only if it
+// is a multiple of the identity, so this only misses differences smaller than tol.
+// Circuits with measurements, resets, noise channels or conditioned gates, or of different
+// sizes, are never equivalent. Panics if a circuit is neither Clifford nor small enough for
+// a state vector, more than 30 qubits.
+func Equivalent(a, b QuantumCircuit, tol float64) bool {
+	if a.numQubits != b.numQubits {
+		return false
+	}
+	for _, qc := range []*QuantumCircuit{&a, &b} {
+		for i := range qc.gates {
+			if !qc.gates[i].IsUnitary() {
+				return false
+			}
+		}
+	}
+
+	if a.numQubits <= maxOperatorQubits {
+		opA, errA := a.Operator()
+		opB, errB := b.Operator()
+		return errA == nil && errB == nil && EqualUpToGlobalPhase(opA, opB, tol)
+	}
+
+	if a.IsClifford() && b.IsClifford() {
+		ta, errA := a.cliffordTableau()
+		tb, errB := b.cliffordTableau()
+		return errA == nil && errB == nil && ta.equals(tb)
+	}
+	if a.numQubits > maxStatevectorQubits {
+		panic(fmt.Sprintf("Cannot compare non-Clifford circuits of %v qubits, the limit for a state vector is %v",
+			a.numQubits, maxStatevectorQubits))
+	}
+
+	// A fixed seed keeps the result reproducible
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < equivalenceTrials; trial++ {
+		psi := RandomState(a.numQubits, rng)
+		outA := NewColVec(Matrix{Rows: psi.Rows, Cols: 1, Stride: 1, Data: append([]complex128{}, psi.Data...)})
+		outB := NewColVec(Matrix{Rows: psi.Rows, Cols: 1, Stride: 1, Data: append([]complex128{}, psi.Data...)})
+		for i := range a.gates {
+			applyGate(outA, a.numQubits, &a.gates[i])
+		}
+		for i := range b.gates {
+			applyGate(outB, b.numQubits, &b.gates[i])
+		}
+		if !EqualUpToGlobalPhase(Matrix(outA), Matrix(outB), tol) {
+			return false
+		}
+	}
+	return true
+}
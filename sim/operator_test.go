@@ -0,0 +1,218 @@
+package sim
+
+import (
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_Operator(t *testing.T) {
+	t.Run("Matches the compiled circuit", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 2})
+		qc.CX(0, 1)
+		qc.CCX(2, 0, 1)
+		qc.RZ(0.3, 2)
+		qc.CSWAP(NegControl(1), 0, 2)
+
+		got, err := qc.Operator()
+		if err != nil {
+			t.Fatalf("Operator() error = %v", err)
+		}
+		qc.Compile()
+		if !got.Equals(qc.compiled.Matrix, StdEpsilon) {
+			t.Errorf("Operator() = %v, want %v", got, qc.compiled.Matrix)
+		}
+	})
+
+	t.Run("Empty circuit", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		if got, err := qc.Operator(); err != nil || !got.Equals(Identity(4), StdEpsilon) {
+			t.Errorf("Operator() = %v, %v, want identity", got, err)
+		}
+	})
+
+	t.Run("Normalized global phase", func(t *testing.T) {
+		// RZ(theta) is P(theta) up to a global phase of e^(-i*theta/2)
+		rz := NewQuantumCircuit(1)
+		rz.RZ(0.8, 0)
+		p := NewQuantumCircuit(1)
+		p.P(0.8, 0)
+
+		got, err := rz.Operator(WithNormalizedPhase())
+		if err != nil {
+			t.Fatalf("Operator() error = %v", err)
+		}
+		want, err := p.Operator(WithNormalizedPhase())
+		if err != nil {
+			t.Fatalf("Operator() error = %v", err)
+		}
+		if !got.Equals(want, StdEpsilon) {
+			t.Errorf("Operator(WithNormalizedPhase()) = %v, want %v", got, want)
+		}
+		if raw, _ := rz.Operator(); raw.Equals(want, StdEpsilon) {
+			t.Errorf("Operator() without options should keep the global phase")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		measured := NewQuantumCircuit(1)
+		measured.Measure(0, 0)
+		if _, err := measured.Operator(); err == nil {
+			t.Errorf("Operator() with a measurement should fail")
+		}
+
+		noisy := NewQuantumCircuit(1)
+		noisy.BitFlip(0.1, 0)
+		if _, err := noisy.Operator(); err == nil {
+			t.Errorf("Operator() with a noise channel should fail")
+		}
+
+		large := NewQuantumCircuit(maxOperatorQubits + 1)
+		if _, err := large.Operator(); err == nil {
+			t.Errorf("Operator() of %v qubits should fail", maxOperatorQubits+1)
+		}
+	})
+}
+
+func TestEqualUpToGlobalPhase(t *testing.T) {
+	phased := func(m Matrix, phi float64) Matrix {
+		out := Matrix{Rows: m.Rows, Cols: m.Cols, Stride: m.Cols, Data: make([]complex128, len(m.Data))}
+		for i, x := range m.Data {
+			out.Data[i] = x * cmplx.Exp(complex(0, phi))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		a, b Matrix
+		want bool
+	}{
+		{name: "Same", a: H, b: H, want: true},
+		{name: "Phase", a: H, b: phased(H, 1.2), want: true},
+		{name: "Rz and Phase differ by a global phase", a: Rz(0.7), b: Phase(0.7), want: true},
+		{name: "Relative phase", a: Z, b: I, want: false},
+		{name: "Sizes", a: I, b: Swap, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EqualUpToGlobalPhase(tt.a, tt.b, 1e-9); got != tt.want {
+				t.Errorf("EqualUpToGlobalPhase() = %v, want %v", got, tt.want)
+			}
+			if got := NormalizeGlobalPhase(tt.a).Equals(NormalizeGlobalPhase(tt.b), StdEpsilon); got != tt.want {
+				t.Errorf("NormalizeGlobalPhase() equal = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := NormalizeGlobalPhase(phased(X, math.Pi/3)); !got.Equals(X, StdEpsilon) {
+		t.Errorf("NormalizeGlobalPhase() = %v, want %v", got, X)
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	// Circuits of n qubits: H-CZ-H against CX, three CX against SWAP and RZ against P
+	build := func(numQubits int, decomposed bool) QuantumCircuit {
+		qc := NewQuantumCircuit(numQubits)
+		last := numQubits - 1
+		qc.H([]int{0})
+		if decomposed {
+			qc.H([]int{last})
+			qc.CZ(0, last)
+			qc.H([]int{last})
+			qc.CX(1, 2)
+			qc.CX(2, 1)
+			qc.CX(1, 2)
+			qc.RZ(0.4, 1)
+		} else {
+			qc.CX(0, last)
+			qc.SWAP(2, 1)
+			qc.P(0.4, 1)
+		}
+		return qc
+	}
+
+	tests := []struct {
+		name      string
+		numQubits int
+	}{
+		{name: "Small circuits by operator", numQubits: 4},
+		{name: "Large circuits by random states", numQubits: maxOperatorQubits + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := build(tt.numQubits, false), build(tt.numQubits, true)
+			if !Equivalent(a, b, 1e-9) {
+				t.Errorf("Equivalent() = false for a decomposition")
+			}
+
+			// A relative phase is not a global one
+			b.Z(0)
+			if Equivalent(a, b, 1e-9) {
+				t.Errorf("Equivalent() = true after adding Z")
+			}
+		})
+	}
+
+	t.Run("Large Clifford circuits by tableau", func(t *testing.T) {
+		const n = 1000
+		a, b := NewQuantumCircuit(n), NewQuantumCircuit(n)
+		a.H([]int{0})
+		a.CZ(0, n-1)
+		a.SWAP(1, 2)
+		for q := 1; q < n; q++ {
+			a.CX(q-1, q)
+		}
+		b.H([]int{0})
+		b.H([]int{n - 1})
+		b.CX(0, n-1)
+		b.H([]int{n - 1})
+		b.CX(1, 2)
+		b.CX(2, 1)
+		b.CX(1, 2)
+		for q := 1; q < n; q++ {
+			b.CX(q-1, q)
+		}
+		if !Equivalent(a, b, 1e-9) {
+			t.Errorf("Equivalent() = false for a decomposition")
+		}
+
+		// Y and ZX differ only by a global phase
+		a.Y(5)
+		b.X(5)
+		b.Z(5)
+		if !Equivalent(a, b, 1e-9) {
+			t.Errorf("Equivalent() = false for Y and XZ")
+		}
+		b.Z(7)
+		if Equivalent(a, b, 1e-9) {
+			t.Errorf("Equivalent() = true after adding Z")
+		}
+	})
+
+	t.Run("Panics on large non-Clifford circuits", func(t *testing.T) {
+		a, b := NewQuantumCircuit(maxStatevectorQubits+1), NewQuantumCircuit(maxStatevectorQubits+1)
+		a.T(0)
+		defer func() {
+			r := recover()
+			if msg, ok := r.(string); !ok || !strings.Contains(msg, "non-Clifford") {
+				t.Errorf("Equivalent() panics with %v, want a non-Clifford error", r)
+			}
+		}()
+		Equivalent(a, b, 1e-9)
+	})
+
+	t.Run("Never equivalent", func(t *testing.T) {
+		a, b := NewQuantumCircuit(1), NewQuantumCircuit(2)
+		if Equivalent(a, b, 1e-9) {
+			t.Errorf("Equivalent() = true for different sizes")
+		}
+		c, d := NewQuantumCircuit(1), NewQuantumCircuit(1)
+		d.Measure(0, 0)
+		if Equivalent(c, d, 1e-9) {
+			t.Errorf("Equivalent() = true with a measurement")
+		}
+	})
+}
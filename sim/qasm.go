@@ -0,0 +1,406 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// Version of the OpenQASM language ToQASM writes
+type QASMVersion int
+
+const (
+	QASM2 QASMVersion = 2 // OpenQASM 2.0 with qelib1.inc
+	QASM3 QASMVersion = 3 // OpenQASM 3.0 with stdgates.inc
+)
+
+// Names of gates in OpenQASM, as applied to their targets without controls
+var qasmNames = map[GateName]string{
+	HADAMARD: "h", CH: "h",
+	PAULIX: "x", CX: "x", CCX: "x", MCX: "x",
+	PAULIY: "y", CY: "y",
+	PAULIZ: "z", CZ: "z",
+	SGATE: "s", SDAGGER: "sdg", TGATE: "t", TDAGGER: "tdg",
+	ROTX: "rx", CROTX: "rx",
+	ROTY: "ry", CROTY: "ry",
+	ROTZ: "rz", CROTZ: "rz",
+	PHASE: "p", CPHASE: "p",
+	U3:   "u3",
+	SWAP: "swap", CSWAP: "swap",
+}
+
+// Controlled gates of qelib1.inc and stdgates.inc, by uncontrolled name and number of controls
+var (
+	qelib1Controlled = map[string][]string{
+		"x": {1: "cx", 2: "ccx", 3: "c3x", 4: "c4x"},
+		"y": {1: "cy"}, "z": {1: "cz"}, "h": {1: "ch"}, "swap": {1: "cswap"},
+		"rx": {1: "crx"}, "ry": {1: "cry"}, "rz": {1: "crz"}, "u1": {1: "cu1"}, "u3": {1: "cu3"},
+	}
+	stdgatesControlled = map[string][]string{
+		"x": {1: "cx", 2: "ccx"},
+		"y": {1: "cy"}, "z": {1: "cz"}, "h": {1: "ch"}, "swap": {1: "cswap"},
+		"rx": {1: "crx"}, "ry": {1: "cry"}, "rz": {1: "crz"}, "p": {1: "cp"},
+	}
+)
+
+// Identifiers custom gates cannot be named after: keywords, the registers and the standard gates
+var qasmReserved = map[string]bool{
+	"OPENQASM": true, "include": true, "qreg": true, "creg": true, "qubit": true, "bit": true,
+	"gate": true, "opaque": true, "measure": true, "reset": true, "barrier": true, "if": true,
+	"ctrl": true, "negctrl": true, "gphase": true, "pi": true, "U": true, "CX": true,
+	"sin": true, "cos": true, "tan": true, "exp": true, "ln": true, "sqrt": true,
+	"q": true, "c": true, "iswap": true,
+	"u3": true, "u2": true, "u1": true, "u0": true, "u": true, "p": true, "id": true, "cx": true,
+	"x": true, "y": true, "z": true, "h": true, "s": true, "sdg": true, "t": true, "tdg": true,
+	"rx": true, "ry": true, "rz": true, "sx": true, "sxdg": true, "cz": true, "cy": true,
+	"swap": true, "ch": true, "ccx": true, "cswap": true, "crx": true, "cry": true, "crz": true,
+	"cu1": true, "cp": true, "cu3": true, "csx": true, "cu": true, "rxx": true, "rzz": true,
+	"rccx": true, "rc3x": true, "c3x": true, "c3sqrtx": true, "c4x": true,
+	"phase": true, "cphase": true,
+}
+
+// Definition of iSWAP, which neither standard library has
+const iswapDefinition = "gate iswap a,b { s a; s b; h a; cx a,b; cx b,a; h b; }"
+
+// Writes the circuit as an OpenQASM program, on a quantum register q and a classical register c
+// indexed like the circuit's qubits and classical bits.
+// Custom unitaries are declared as opaque gates in OpenQASM 2.0, which has no way to define
+// them from a matrix. Returns an error for operations with no equivalent in the version, such as
+// noise channels, compiled circuits and, in OpenQASM 2.0, conditions on part of the classical register.
+func (qc *QuantumCircuit) ToQASM(version QASMVersion) (string, error) {
+	if version != QASM2 && version != QASM3 {
+		return "", fmt.Errorf("unknown OpenQASM version %v", version)
+	}
+	w := &qasmWriter{
+		version:   version,
+		numClbits: qc.numClbits,
+		defined:   map[string]bool{},
+		opaque:    map[string]int{},
+	}
+
+	for i := range qc.gates {
+		g := &qc.gates[i]
+		stmts, err := w.gate(g)
+		if err != nil {
+			return "", fmt.Errorf("gate %v: %v", i, err)
+		}
+		prefix, err := w.condition(g.cond)
+		if err != nil {
+			return "", fmt.Errorf("gate %v: %v", i, err)
+		}
+		for _, s := range stmts {
+			w.body = append(w.body, prefix+s)
+		}
+	}
+
+	var b strings.Builder
+	if version == QASM2 {
+		b.WriteString("OPENQASM 2.0;\ninclude \"qelib1.inc\";\n")
+	} else {
+		b.WriteString("OPENQASM 3.0;\ninclude \"stdgates.inc\";\n")
+	}
+	for _, d := range w.defs {
+		b.WriteString(d + "\n")
+	}
+	if qc.numQubits > 0 {
+		if version == QASM2 {
+			fmt.Fprintf(&b, "qreg q[%v];\n", qc.numQubits)
+		} else {
+			fmt.Fprintf(&b, "qubit[%v] q;\n", qc.numQubits)
+		}
+	}
+	if qc.numClbits > 0 {
+		if version == QASM2 {
+			fmt.Fprintf(&b, "creg c[%v];\n", qc.numClbits)
+		} else {
+			fmt.Fprintf(&b, "bit[%v] c;\n", qc.numClbits)
+		}
+	}
+	for _, s := range w.body {
+		b.WriteString(s + "\n")
+	}
+	return b.String(), nil
+}
+
+type qasmWriter struct {
+	version   QASMVersion
+	numClbits int
+	defs      []string // Gate definitions and opaque declarations, in order of first use
+	defined   map[string]bool
+	opaque    map[string]int // Number of qubits of each declared opaque gate
+	body      []string
+}
+
+// Adds a gate definition or declaration to the program, once
+func (w *qasmWriter) define(name, def string) {
+	if !w.defined[name] {
+		w.defined[name] = true
+		w.defs = append(w.defs, def)
+	}
+}
+
+// Statements applying the gate, without its condition
+func (w *qasmWriter) gate(g *Gate) ([]string, error) {
+	switch {
+	case g.kraus != nil:
+		return nil, fmt.Errorf("%v noise channel has no OpenQASM equivalent", g.Name())
+	case g.targets == nil && g.name == WIRE:
+		return nil, nil
+	case g.targets == nil:
+		return nil, fmt.Errorf("%v acts on the whole register and has no OpenQASM equivalent", g.Name())
+	}
+
+	switch g.name {
+	case MEASURE:
+		if w.version == QASM2 {
+			return []string{fmt.Sprintf("measure q[%v] -> c[%v];", g.targets[0], g.clbits[0])}, nil
+		}
+		return []string{fmt.Sprintf("c[%v] = measure q[%v];", g.clbits[0], g.targets[0])}, nil
+	case RESET:
+		return []string{fmt.Sprintf("reset q[%v];", g.targets[0])}, nil
+	case ISWAP:
+		w.define("iswap", iswapDefinition)
+		return []string{"iswap " + qasmOperands(g.targets) + ";"}, nil
+	case CUSTOM:
+		if w.version != QASM2 {
+			return nil, fmt.Errorf("custom gate %q has no OpenQASM 3.0 equivalent", g.Name())
+		}
+		return []string{w.opaqueName(g.label, len(g.targets)) + " " + qasmOperands(g.targets) + ";"}, nil
+	case MCU:
+		return w.controlledU(g)
+	}
+
+	name, ok := qasmNames[g.name]
+	if !ok {
+		return nil, fmt.Errorf("%v has no OpenQASM equivalent", g.Name())
+	}
+	if w.version == QASM2 && name == "p" {
+		name = "u1"
+	}
+	call := name + qasmParams(g.params)
+
+	// Gates on several targets without controls are either swaps or applied to each target
+	if len(g.controls)+len(g.negControls) == 0 {
+		if name == "swap" {
+			return []string{call + " " + qasmOperands(g.targets) + ";"}, nil
+		}
+		var stmts []string
+		for _, t := range g.targets {
+			stmts = append(stmts, fmt.Sprintf("%v q[%v];", call, t))
+		}
+		return stmts, nil
+	}
+	return w.controlled(g, name, g.params)
+}
+
+// Statements applying the named gate to the targets of g, under the controls of g
+func (w *qasmWriter) controlled(g *Gate, name string, params []float64) ([]string, error) {
+	numControls := len(g.controls) + len(g.negControls)
+	operands := qasmOperands(g.Qubits())
+
+	if w.version == QASM3 {
+		if names := stdgatesControlled[name]; len(g.negControls) == 0 && numControls < len(names) && names[numControls] != "" {
+			return []string{names[numControls] + qasmParams(params) + " " + operands + ";"}, nil
+		}
+		return []string{qasmModifiers(len(g.controls), len(g.negControls)) + name + qasmParams(params) + " " + operands + ";"}, nil
+	}
+
+	names := qelib1Controlled[name]
+	if numControls >= len(names) || names[numControls] == "" {
+		return nil, fmt.Errorf("%v with %v controls has no OpenQASM 2.0 equivalent", g.Name(), numControls)
+	}
+	stmt := names[numControls] + qasmParams(params) + " " + operands + ";"
+	return qasmNegate(g.negControls, []string{stmt}), nil
+}
+
+// Statements applying a controlled 2x2 matrix, as a controlled U gate and a phase
+// on the controls for its global phase
+func (w *qasmWriter) controlledU(g *Gate) ([]string, error) {
+	if len(g.targets) != 1 {
+		return nil, fmt.Errorf("%v on %v targets has no OpenQASM equivalent", g.Name(), len(g.targets))
+	}
+	theta, phi, lambda, alpha := eulerAngles(g.Matrix)
+	params := []float64{theta, phi, lambda}
+	numControls := len(g.controls) + len(g.negControls)
+	controls := append(append([]int{}, g.controls...), g.negControls...)
+
+	if numControls == 0 {
+		return []string{fmt.Sprintf("u3%v q[%v];", qasmParams(params), g.targets[0])}, nil
+	}
+
+	if w.version == QASM3 {
+		modifiers := qasmModifiers(len(g.controls), len(g.negControls))
+		stmts := []string{modifiers + "U" + qasmParams(params) + " " + qasmOperands(g.Qubits()) + ";"}
+		if alpha != 0 {
+			stmts = append(stmts, modifiers+"gphase"+qasmParams([]float64{alpha})+" "+qasmOperands(controls)+";")
+		}
+		return stmts, nil
+	}
+
+	if numControls != 1 {
+		return nil, fmt.Errorf("%v with %v controls has no OpenQASM 2.0 equivalent", g.Name(), numControls)
+	}
+	stmts := []string{"cu3" + qasmParams(params) + " " + qasmOperands(g.Qubits()) + ";"}
+	if alpha != 0 {
+		stmts = append(stmts, fmt.Sprintf("u1%v q[%v];", qasmParams([]float64{alpha}), controls[0]))
+	}
+	return qasmNegate(g.negControls, stmts), nil
+}
+
+// Name of the opaque gate standing for a custom gate, declaring it on first use.
+// Labels are turned into identifiers, and given a suffix if they are reserved or
+// already used for a gate on a different number of qubits.
+func (w *qasmWriter) opaqueName(label string, numQubits int) string {
+	name := []rune(label)
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			name[i] = '_'
+		}
+	}
+	base := string(name)
+	switch {
+	case base == "":
+		base = "custom"
+	case base[0] < 'a' || base[0] > 'z':
+		base = "g_" + base
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		n, used := w.opaque[candidate]
+		if used && n == numQubits {
+			return candidate
+		}
+		if !used && !qasmReserved[candidate] {
+			break
+		}
+		candidate = fmt.Sprintf("%v_%v", base, i)
+	}
+
+	args := make([]string, numQubits)
+	for i := range args {
+		args[i] = fmt.Sprintf("a%v", i)
+	}
+	w.opaque[candidate] = numQubits
+	w.define(candidate, fmt.Sprintf("opaque %v %v;", candidate, strings.Join(args, ",")))
+	return candidate
+}
+
+// Prefix making a statement conditional on the classical bits, empty if cond is nil
+func (w *qasmWriter) condition(cond *condition) (string, error) {
+	if cond == nil {
+		return "", nil
+	}
+
+	whole := len(cond.clbits) == w.numClbits
+	for i, b := range cond.clbits {
+		whole = whole && b == i
+	}
+	if whole {
+		if w.version == QASM2 {
+			return fmt.Sprintf("if(c==%v) ", cond.value), nil
+		}
+		return fmt.Sprintf("if (c == %v) ", cond.value), nil
+	}
+
+	if w.version == QASM2 {
+		return "", fmt.Errorf("condition on classical bits %v is not on the whole register, as OpenQASM 2.0 requires", cond.clbits)
+	}
+	terms := make([]string, len(cond.clbits))
+	for i, b := range cond.clbits {
+		terms[i] = fmt.Sprintf("c[%v] == %v", b, cond.value>>uint(i)&1)
+	}
+	return "if (" + strings.Join(terms, " && ") + ") ", nil
+}
+
+// Surrounds statements with X gates on the negative controls, which OpenQASM 2.0 lacks
+func qasmNegate(negControls []int, stmts []string) []string {
+	if len(negControls) == 0 {
+		return stmts
+	}
+	var flips []string
+	for _, q := range negControls {
+		flips = append(flips, fmt.Sprintf("x q[%v];", q))
+	}
+	return append(append(flips, stmts...), flips...)
+}
+
+// OpenQASM 3.0 control modifiers for the given numbers of controls and negative controls
+func qasmModifiers(numControls, numNegControls int) string {
+	modifier := func(name string, n int) string {
+		switch n {
+		case 0:
+			return ""
+		case 1:
+			return name + " @ "
+		}
+		return fmt.Sprintf("%v(%v) @ ", name, n)
+	}
+	return modifier("ctrl", numControls) + modifier("negctrl", numNegControls)
+}
+
+func qasmOperands(qubits []int) string {
+	operands := make([]string, len(qubits))
+	for i, q := range qubits {
+		operands[i] = fmt.Sprintf("q[%v]", q)
+	}
+	return strings.Join(operands, ",")
+}
+
+func qasmParams(params []float64) string {
+	if len(params) == 0 {
+		return ""
+	}
+	formatted := make([]string, len(params))
+	for i, p := range params {
+		formatted[i] = formatAngle(p)
+	}
+	return "(" + strings.Join(formatted, ",") + ")"
+}
+
+// Formats an angle exactly, as a simple fraction of pi where it is one
+func formatAngle(x float64) string {
+	for _, d := range []int{1, 2, 3, 4, 6, 8} {
+		n := math.Round(x / math.Pi * float64(d))
+		if n == 0 || math.Abs(n) > 64 || n*math.Pi/float64(d) != x {
+			continue
+		}
+		s := "pi"
+		if n == -1 {
+			s = "-pi"
+		} else if n != 1 {
+			s = strconv.FormatFloat(n, 'f', -1, 64) + "*pi"
+		}
+		if d != 1 {
+			s += "/" + strconv.Itoa(d)
+		}
+		return s
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// Angles of a 2x2 unitary m = e^(i*alpha) U(theta, phi, lambda)
+func eulerAngles(m Matrix) (theta, phi, lambda, alpha float64) {
+	u00, u01 := m.Data[0], m.Data[1]
+	u10, u11 := m.Data[m.Stride], m.Data[m.Stride+1]
+	theta = 2 * math.Atan2(cmplx.Abs(u10), cmplx.Abs(u00))
+
+	const tolerance = 1e-12
+	switch {
+	case cmplx.Abs(u10) < tolerance:
+		// Diagonal: only the sum phi + lambda matters
+		alpha = cmplx.Phase(u00)
+		lambda = cmplx.Phase(u11) - alpha
+	case cmplx.Abs(u00) < tolerance:
+		// Antidiagonal: only the difference phi - lambda matters
+		alpha = cmplx.Phase(-u01)
+		phi = cmplx.Phase(u10) - alpha
+	default:
+		alpha = cmplx.Phase(u00)
+		phi = cmplx.Phase(u10) - alpha
+		lambda = cmplx.Phase(-u01) - alpha
+	}
+	return theta, phi, lambda, alpha
+}
@@ -0,0 +1,142 @@
+package sim
+
+import (
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_ToQASM(t *testing.T) {
+	t.Run("OpenQASM 2.0", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 2})
+		qc.CX(0, 1)
+		qc.RZ(math.Pi/4, 2)
+		qc.CP(-math.Pi/2, 1, 2)
+		qc.MCX([]int{0, NegControl(1)}, 2)
+		qc.ISWAP(0, 2)
+		qc.Unitary(H, []int{1}, "My gate")
+		qc.Measure(0, 0)
+		qc.If(0, 1).X(1)
+		qc.Reset(0)
+
+		got, err := qc.ToQASM(QASM2)
+		if err != nil {
+			t.Fatalf("ToQASM() error = %v", err)
+		}
+		want := `OPENQASM 2.0;
+include "qelib1.inc";
+gate iswap a,b { s a; s b; h a; cx a,b; cx b,a; h b; }
+opaque g_My_gate a0;
+qreg q[3];
+creg c[1];
+h q[0];
+h q[2];
+cx q[0],q[1];
+rz(pi/4) q[2];
+cu1(-pi/2) q[1],q[2];
+x q[1];
+ccx q[0],q[1],q[2];
+x q[1];
+iswap q[0],q[2];
+g_My_gate q[1];
+measure q[0] -> c[0];
+if(c==1) x q[1];
+reset q[0];
+`
+		if got != want {
+			t.Errorf("ToQASM() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("OpenQASM 3.0", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.P(0.25, 0)
+		qc.CP(0.5, 0, 1)
+		qc.MCX([]int{0, NegControl(1)}, 2)
+		qc.MCU([]int{0, 1}, Sdg, []int{2})
+		qc.Measure(0, 0)
+		qc.Measure(1, 2)
+		qc.IfRegister([]int{0, 2}, 2).Z(2)
+
+		got, err := qc.ToQASM(QASM3)
+		if err != nil {
+			t.Fatalf("ToQASM() error = %v", err)
+		}
+		want := `OPENQASM 3.0;
+include "stdgates.inc";
+qubit[3] q;
+bit[3] c;
+p(0.25) q[0];
+cp(0.5) q[0],q[1];
+ctrl @ negctrl @ x q[0],q[1],q[2];
+ctrl(2) @ U(0,0,-pi/2) q[0],q[1],q[2];
+c[0] = measure q[0];
+c[2] = measure q[1];
+if (c[0] == 0 && c[2] == 1) z q[2];
+`
+		if got != want {
+			t.Errorf("ToQASM() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			version QASMVersion
+			build   func(qc *QuantumCircuit)
+			want    string
+		}{
+			{"Noise channel", QASM2, func(qc *QuantumCircuit) { qc.BitFlip(0.1, 0) }, "noise channel"},
+			{"Compiled circuit", QASM3, func(qc *QuantumCircuit) {
+				inner := NewQuantumCircuit(6)
+				inner.H([]int{0})
+				addCompiled(qc, inner)
+			}, "whole register"},
+			{"Custom gate in 3.0", QASM3, func(qc *QuantumCircuit) { qc.Unitary(X, []int{0}, "flip") }, "custom gate"},
+			{"Five controls", QASM2, func(qc *QuantumCircuit) { qc.MCX([]int{0, 1, 2, 3, 4}, 5) }, "5 controls"},
+			{"Partial condition", QASM2, func(qc *QuantumCircuit) {
+				qc.Measure(0, 1)
+				qc.If(1, 1).X(0)
+			}, "whole register"},
+			{"Unknown version", QASMVersion(4), func(qc *QuantumCircuit) {}, "unknown"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				qc := NewQuantumCircuit(6)
+				tt.build(&qc)
+				if _, err := qc.ToQASM(tt.version); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("ToQASM() error = %v, want one containing %q", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestEulerAngles(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Matrix
+	}{
+		{name: "X", m: X},
+		{name: "Y", m: Y},
+		{name: "H", m: H},
+		{name: "Sdg", m: Sdg},
+		{name: "Rz", m: Rz(0.7)},
+		{name: "Ry", m: Ry(-2.1)},
+		{name: "U", m: U(1.1, -0.4, 2.5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			theta, phi, lambda, alpha := eulerAngles(tt.m)
+			got := U(theta, phi, lambda)
+			for i := range got.Data {
+				got.Data[i] *= cmplx.Exp(complex(0, alpha))
+			}
+			if !got.Equals(tt.m, StdEpsilon) {
+				t.Errorf("e^(i*%v) U(%v, %v, %v) = %v, want %v", alpha, theta, phi, lambda, got, tt.m)
+			}
+		})
+	}
+}
@@ -3,20 +3,85 @@ package sim
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"strconv"
 )
 
 type QuantumCircuit struct {
 	numQubits    int
+	numClbits    int
 	gates        []Gate
 	compileValid bool
 	compiled     Gate
+
+	// Set on the views returned by If, which add conditioned gates to parent
+	parent *QuantumCircuit
+	cond   *condition
 }
 
 type QuantumCircuitExecution struct {
 	in       []Ket
 	register ColVec
 	out      ColVec
+	rho      *Matrix // Output density matrix, set instead of out when executed with WithDensityMatrix
+	clbits   []int
+	noise    *NoiseModel // Readout errors applied by Sample, when executed with WithNoise
+
+	// Set instead of out for Clifford circuits too large for a state vector
+	stabilizer *StabilizerExecution
+}
+
+// Largest register Exec holds as a state vector, 16 GiB of amplitudes.
+// A density matrix squares the memory, so its limit is half as many qubits.
+const maxStatevectorQubits = 30
+
+// Largest number of qubits the state vector or density matrix of cfg can hold
+func (cfg *execConfig) maxQubits() int {
+	if cfg.density {
+		return maxStatevectorQubits / 2
+	}
+	return maxStatevectorQubits
+}
+
+// Settings for a single execution of a circuit
+type execConfig struct {
+	rng     *rand.Rand
+	density bool
+	noise   *NoiseModel
+
+	// Truncation of matrix product states in ExecMPS
+	maxBond    int
+	truncation float64
+}
+
+// Optional setting passed to Exec
+type ExecOption func(*execConfig)
+
+// Draws measurement outcomes during execution from rng, making runs reproducible
+// for a given seed. Without it the global source is used.
+func WithRand(rng *rand.Rand) ExecOption {
+	return func(cfg *execConfig) {
+		cfg.rng = rng
+	}
+}
+
+// Evolves the register as a density matrix rho, applying each gate as U rho U†,
+// so that mixed states can be represented. Noise channels are applied exactly
+// rather than as a single stochastic trajectory. This takes the square of the memory
+// of the default state vector.
+func WithDensityMatrix() ExecOption {
+	return func(cfg *execConfig) {
+		cfg.density = true
+	}
+}
+
+// Uniform random number in [0, 1) from the configured source
+func (cfg *execConfig) float64() float64 {
+	if cfg.rng != nil {
+		return cfg.rng.Float64()
+	}
+	return rand.Float64()
 }
 
 func NewQuantumCircuit(numQubits int) QuantumCircuit {
@@ -28,51 +93,62 @@ func NewQuantumCircuit(numQubits int) QuantumCircuit {
 	}
 }
 
-// Computes the probabilites of measuring all outcomes in the standard Z bases
-func (qce *QuantumCircuitExecution) MeasureProbabilities() []float64 {
-	probabilities := make([]float64, qce.out.Size())
+// Number of qubits in the output register
+func (qce *QuantumCircuitExecution) numQubits() int {
+	if qce.stabilizer != nil {
+		return qce.stabilizer.NumQubits()
+	}
+	size := qce.out.Size()
+	if qce.rho != nil {
+		size = qce.rho.Rows
+	}
+	return int(math.Log2(float64(size)))
+}
 
-	numQubits := int(math.Log2(float64(qce.out.Size())))
+// Panics for executions run on a stabilizer tableau, which has no amplitudes to read
+func (qce *QuantumCircuitExecution) needAmplitudes(method string) {
+	if qce.stabilizer != nil {
+		panic(fmt.Sprintf("%v needs all 2^%v amplitudes of the output, but the circuit was too large for a state vector and ran on a stabilizer tableau",
+			method, qce.stabilizer.NumQubits()))
+	}
+}
 
-	for i := 0; i < qce.out.Size(); i++ {
-		byteStr := fmt.Sprintf("%0"+strconv.Itoa(numQubits)+"v", strconv.FormatInt(int64(i), 2))
-		var basis []Ket
-		for _, c := range byteStr {
-			if c == '0' {
-				basis = append(basis, ZeroKet)
-			} else if c == '1' {
-				basis = append(basis, OneKet)
-			}
+// Probability of each standard basis state of the output register
+func (qce *QuantumCircuitExecution) probabilities() []float64 {
+	qce.needAmplitudes("MeasureProbabilities")
+	if qce.rho != nil {
+		probabilities := make([]float64, qce.rho.Rows)
+		for i := range probabilities {
+			probabilities[i] = real(qce.rho.Data[i*qce.rho.Stride+i])
 		}
-
-		probabilities[i] = qce.MeasureProbability(basis)
+		return probabilities
 	}
 
+	probabilities := make([]float64, qce.out.Size())
+	for i, amp := range qce.out.Data {
+		probabilities[i] = real(amp)*real(amp) + imag(amp)*imag(amp)
+	}
 	return probabilities
 }
 
+// Computes the probabilites of measuring all outcomes in the standard Z bases
+func (qce *QuantumCircuitExecution) MeasureProbabilities() []float64 {
+	return qce.probabilities()
+}
+
 // Probability that measuring a particular qubit in the standard basis will return ON
 // Calculated as the sum of the probabilities of all the output states that have that one qubit ON
 func (qce *QuantumCircuitExecution) MeasureProbabilityOn(qubit int) float64 {
-	numQubits := int(math.Log2(float64(qce.out.Size())))
+	if qce.stabilizer != nil {
+		return qce.stabilizer.MeasureProbabilityOn(qubit)
+	}
+	mask := qubitMask(qubit, qce.numQubits())
 	probSum := 0.0
 
-	for i := 0; i < qce.out.Size(); i++ {
-		byteStr := fmt.Sprintf("%0"+strconv.Itoa(numQubits)+"v", strconv.FormatInt(int64(i), 2))
-		if byteStr[qubit] == '0' {
-			continue
+	for i, p := range qce.probabilities() {
+		if i&mask != 0 {
+			probSum += p
 		}
-
-		var basis []Ket
-		for _, c := range byteStr {
-			if c == '0' {
-				basis = append(basis, ZeroKet)
-			} else if c == '1' {
-				basis = append(basis, OneKet)
-			}
-		}
-
-		probSum += qce.MeasureProbability(basis)
 	}
 
 	return probSum
@@ -80,87 +156,456 @@ func (qce *QuantumCircuitExecution) MeasureProbabilityOn(qubit int) float64 {
 
 // Measures the probability of reading out a certain vector
 func (qce *QuantumCircuitExecution) MeasureProbability(basis []Ket) float64 {
+	qce.needAmplitudes("MeasureProbability")
 	// Check dimensions of the measurement basis
 	// We need n basis vectors to measure a space of 2^n
-	if float64(len(basis)) != math.Log2(float64(qce.out.Size())) {
+	if len(basis) != qce.numQubits() {
 		panic("Not enough basis vectors for measurement basis")
 	}
 
 	// Assemble basis vector by Kronecker products
 	basisVec := KronKets(basis)
 
+	if qce.rho != nil {
+		// Expectation <b|rho|b> of the projector onto the basis vector
+		projected := NewColVec(*qce.rho.Mul(Matrix(basisVec)))
+		expectation := basisVec.Dotp(projected) / basisVec.Dotp(basisVec)
+		return real(expectation)
+	}
+
 	// Magnitude of projection of output onto basis
 	magnitude := qce.out.Dotp(basisVec) / basisVec.Dotp(basisVec)
 
 	return real(magnitude)*real(magnitude) + imag(magnitude)*imag(magnitude)
 }
 
+// Density matrix of the output register. For pure output states this is |psi><psi|.
+func (qce *QuantumCircuitExecution) DensityMatrix() Matrix {
+	qce.needAmplitudes("DensityMatrix")
+	if qce.rho != nil {
+		return *qce.rho
+	}
+	return newDensityMatrix(qce.out, qce.numQubits()).rho
+}
+
+// Draws shots measurements of every qubit in the standard basis from the output state.
+// Returns how many times each outcome was seen, keyed by bitstring with qubit 0 first.
+// Results are reproducible for a given seed of rng; a nil rng uses the global source.
+// Readout errors of the noise model the circuit was executed with corrupt each shot.
+func (qce *QuantumCircuitExecution) Sample(shots int, rng *rand.Rand) map[string]int {
+	if qce.stabilizer != nil {
+		return qce.stabilizer.Sample(shots, rng)
+	}
+	numQubits := qce.numQubits()
+
+	// Cumulative distribution over the basis states, searched once per shot
+	probabilities := qce.probabilities()
+	cumulative := make([]float64, len(probabilities))
+	total := 0.0
+	for i, p := range probabilities {
+		total += p
+		cumulative[i] = total
+	}
+
+	draw := (&execConfig{rng: rng}).float64
+
+	counts := map[string]int{}
+	for s := 0; s < shots; s++ {
+		u := draw()
+
+		// First state whose cumulative probability exceeds the draw, so states
+		// with zero probability are never picked
+		x := u * total
+		i := sort.Search(len(cumulative), func(j int) bool { return cumulative[j] > x })
+		if i == len(cumulative) {
+			i--
+		}
+		if qce.noise != nil {
+			i = qce.noise.readAll(i, numQubits, draw)
+		}
+		counts[bitString(i, numQubits)]++
+	}
+
+	return counts
+}
+
+// Formats a basis state index as a bitstring with qubit 0 first
+func bitString(index, numQubits int) string {
+	return fmt.Sprintf("%0"+strconv.Itoa(numQubits)+"v", strconv.FormatInt(int64(index), 2))
+}
+
+// Number of qubits in the circuit
+func (qc *QuantumCircuit) NumQubits() int {
+	return qc.numQubits
+}
+
+// Number of classical bits measurements in the circuit can write to
+func (qc *QuantumCircuit) NumClbits() int {
+	return qc.numClbits
+}
+
+// Grows the classical register by n bits, for bits that are declared before any
+// gate writes them, and returns the index of the first new bit
+func (qc *QuantumCircuit) AddClbits(n int) int {
+	if qc.parent != nil {
+		return qc.parent.AddClbits(n)
+	}
+	first := qc.numClbits
+	qc.numClbits += n
+	return first
+}
+
+// Values of the classical bits after execution, each 0 or 1
+func (qce *QuantumCircuitExecution) Clbits() []int {
+	return qce.clbits
+}
+
+// Appends a gate to the circuit. Panics if it touches a qubit outside the circuit.
 func (qc *QuantumCircuit) addGate(g Gate) {
+	for _, q := range g.Qubits() {
+		if q < 0 || q >= qc.numQubits {
+			panic(fmt.Sprintf("Qubit %v of %v gate is out of range for circuit with %v qubits", q, g.Name(), qc.numQubits))
+		}
+	}
+	if qc.cond != nil {
+		g.cond = qc.cond.and(g.cond)
+	}
+	if qc.parent != nil {
+		qc.parent.addGate(g)
+		return
+	}
+
+	// Grow the classical register to hold every bit the gate reads or writes
+	clbits := g.clbits
+	if g.cond != nil {
+		clbits = append(append([]int{}, clbits...), g.cond.clbits...)
+	}
+	for _, c := range clbits {
+		if c >= qc.numClbits {
+			qc.numClbits = c + 1
+		}
+	}
+
 	qc.compileValid = false
 	qc.gates = append(qc.gates, g)
 }
 
+// Returns a view of the circuit whose gates only fire when classical bit cbit
+// equals value, as in OpenQASM's if (c==1) x q[0];
+// Gates added through the view are appended to this circuit, e.g. qc.If(0, 1).X(2)
+func (qc *QuantumCircuit) If(cbit, value int) *QuantumCircuit {
+	return qc.IfRegister([]int{cbit}, value)
+}
+
+// Returns a view of the circuit whose gates only fire when the classical bits,
+// read as a binary number with the first bit least significant, equal value.
+// Gates added through the view are appended to this circuit.
+func (qc *QuantumCircuit) IfRegister(cbits []int, value int) *QuantumCircuit {
+	return &QuantumCircuit{
+		numQubits: qc.numQubits,
+		parent:    qc,
+		cond: &condition{
+			clbits: append([]int{}, cbits...),
+			value:  value,
+		},
+	}
+}
+
 // Add a singular or multi-qubit Hadamard gate to this circuit.
 // Takes in a list of qubit indices that the Hadamard gate should apply to.
 func (qc *QuantumCircuit) H(hQubits []int) {
 	if len(hQubits) > qc.numQubits {
 		panic("Too many qubits provided for H gate")
 	}
-	qc.addGate(*createH(hQubits, qc.numQubits))
+	qc.addGate(*createH(hQubits))
 }
 
 // Adds a CNOT (C-X) gate to this circuit. Takes in the control and target
 // qubit indices that the gate should operate on.
 func (qc *QuantumCircuit) CX(control, target int) {
-	qc.addGate(*createCX(control, target, qc.numQubits))
+	qc.addGate(*createCX(control, target))
+}
+
+// Adds a controlled-Z gate to this circuit
+func (qc *QuantumCircuit) CZ(control, target int) {
+	qc.addGate(*createControlled(Z, CZ, []int{control}, []int{target}))
+}
+
+// Adds a controlled-Y gate to this circuit
+func (qc *QuantumCircuit) CY(control, target int) {
+	qc.addGate(*createControlled(Y, CY, []int{control}, []int{target}))
+}
+
+// Adds a controlled-Hadamard gate to this circuit
+func (qc *QuantumCircuit) CH(control, target int) {
+	qc.addGate(*createControlled(H, CH, []int{control}, []int{target}))
+}
+
+// Adds a controlled-phase gate to this circuit, multiplying |11> by e^(i*lambda)
+func (qc *QuantumCircuit) CP(lambda float64, control, target int) {
+	qc.addGate(*createControlled(Phase(lambda), CPHASE, []int{control}, []int{target}, lambda))
+}
+
+// Adds a controlled rotation by theta about the X axis to this circuit
+func (qc *QuantumCircuit) CRX(theta float64, control, target int) {
+	qc.addGate(*createControlled(Rx(theta), CROTX, []int{control}, []int{target}, theta))
+}
+
+// Adds a controlled rotation by theta about the Y axis to this circuit
+func (qc *QuantumCircuit) CRY(theta float64, control, target int) {
+	qc.addGate(*createControlled(Ry(theta), CROTY, []int{control}, []int{target}, theta))
+}
+
+// Adds a controlled rotation by theta about the Z axis to this circuit
+func (qc *QuantumCircuit) CRZ(theta float64, control, target int) {
+	qc.addGate(*createControlled(Rz(theta), CROTZ, []int{control}, []int{target}, theta))
+}
+
+// Adds a Toffoli (controlled-controlled-X) gate to this circuit
+func (qc *QuantumCircuit) CCX(control1, control2, target int) {
+	qc.addGate(*createControlled(X, CCX, []int{control1, control2}, []int{target}))
+}
+
+// Adds a Fredkin (controlled-SWAP) gate to this circuit
+func (qc *QuantumCircuit) CSWAP(control, a, b int) {
+	qc.addGate(*createControlled(Swap, CSWAP, []int{control}, []int{a, b}))
+}
+
+// Adds an X gate on the target controlled by any number of qubits.
+// Controls wrapped in NegControl fire when their qubit is |0>.
+func (qc *QuantumCircuit) MCX(controls []int, target int) {
+	qc.addGate(*createControlled(X, MCX, controls, []int{target}))
+}
+
+// Adds a gate applying the matrix u to the targets, controlled by any number of qubits.
+// u acts jointly on the targets, the first target being its most significant qubit.
+// Controls wrapped in NegControl fire when their qubit is |0>.
+func (qc *QuantumCircuit) MCU(controls []int, u Matrix, targets []int) {
+	qc.addGate(*createControlled(u, MCU, controls, targets))
+}
+
+// Adds a gate applying an arbitrary unitary matrix to the given qubits, named by label.
+// The matrix acts jointly on the qubits, the first of which is its most significant.
+// Returns an error if the matrix is not a square unitary of size 2^len(qubits).
+func (qc *QuantumCircuit) Unitary(m Matrix, qubits []int, label string) error {
+	g, err := createCustom(m, qubits, label, qc.numQubits)
+	if err != nil {
+		return err
+	}
+	qc.addGate(*g)
+	return nil
+}
+
+// Adds a SWAP gate exchanging the states of qubits a and b
+func (qc *QuantumCircuit) SWAP(a, b int) {
+	qc.addGate(*createTwoQubit(Swap, SWAP, a, b))
+}
+
+// Adds an iSWAP gate exchanging the states of qubits a and b,
+// with a phase of i on the swapped |01> and |10> components
+func (qc *QuantumCircuit) ISWAP(a, b int) {
+	qc.addGate(*createTwoQubit(ISwap, ISWAP, a, b))
 }
 
 // Adds a Pauli-X gate to the qubit
 func (qc *QuantumCircuit) X(qubit int) {
-	qc.addGate(*createX(qubit, qc.numQubits))
+	qc.addGate(*createX(qubit))
+}
+
+// Adds a Pauli-Y gate to the qubit
+func (qc *QuantumCircuit) Y(qubit int) {
+	qc.addGate(*createSingleQubit(Y, PAULIY, qubit))
+}
+
+// Adds a Pauli-Z gate to the qubit
+func (qc *QuantumCircuit) Z(qubit int) {
+	qc.addGate(*createSingleQubit(Z, PAULIZ, qubit))
+}
+
+// Adds an S (phase) gate to the qubit
+func (qc *QuantumCircuit) S(qubit int) {
+	qc.addGate(*createSingleQubit(S, SGATE, qubit))
 }
 
-// Compiles all gates in the circuit into one compiled operation
+// Adds an S-dagger gate, the inverse of S, to the qubit
+func (qc *QuantumCircuit) Sdg(qubit int) {
+	qc.addGate(*createSingleQubit(Sdg, SDAGGER, qubit))
+}
+
+// Adds a T (pi/8) gate to the qubit
+func (qc *QuantumCircuit) T(qubit int) {
+	qc.addGate(*createSingleQubit(T, TGATE, qubit))
+}
+
+// Adds a T-dagger gate, the inverse of T, to the qubit
+func (qc *QuantumCircuit) Tdg(qubit int) {
+	qc.addGate(*createSingleQubit(Tdg, TDAGGER, qubit))
+}
+
+// Adds a rotation by theta about the X axis to the qubit
+func (qc *QuantumCircuit) RX(theta float64, qubit int) {
+	qc.addGate(*createSingleQubit(Rx(theta), ROTX, qubit, theta))
+}
+
+// Adds a rotation by theta about the Y axis to the qubit
+func (qc *QuantumCircuit) RY(theta float64, qubit int) {
+	qc.addGate(*createSingleQubit(Ry(theta), ROTY, qubit, theta))
+}
+
+// Adds a rotation by theta about the Z axis to the qubit
+func (qc *QuantumCircuit) RZ(theta float64, qubit int) {
+	qc.addGate(*createSingleQubit(Rz(theta), ROTZ, qubit, theta))
+}
+
+// Adds a phase gate, multiplying the |1> component of the qubit by e^(i*lambda)
+func (qc *QuantumCircuit) P(lambda float64, qubit int) {
+	qc.addGate(*createSingleQubit(Phase(lambda), PHASE, qubit, lambda))
+}
+
+// Adds a general single-qubit gate given by its three Euler angles to the qubit
+func (qc *QuantumCircuit) U(theta, phi, lambda float64, qubit int) {
+	qc.addGate(*createSingleQubit(U(theta, phi, lambda), U3, qubit, theta, phi, lambda))
+}
+
+// Measures the qubit in the standard basis partway through the circuit, collapsing
+// the register and writing the outcome to the classical bit cbit.
+// The classical register grows to hold cbit if needed.
+func (qc *QuantumCircuit) Measure(qubit, cbit int) {
+	qc.addGate(*createMeasure(qubit, cbit))
+}
+
+// Resets the qubit to |0> partway through the circuit
+func (qc *QuantumCircuit) Reset(qubit int) {
+	qc.addGate(*createReset(qubit))
+}
+
+// Compiles all gates in the circuit into one compiled operation.
+// Exec does not need this; use Operator to inspect the unitary of small circuits.
+// Panics if the circuit contains measurements, resets or conditioned gates.
 func (qc *QuantumCircuit) Compile() {
 	if len(qc.gates) == 0 {
 		qc.compiled = *createWire(qc.numQubits)
 	} else {
-		qc.compiled = *Combine(42, qc.gates...)
+		qc.compiled = *Combine(42, qc.numQubits, qc.gates...)
 	}
 	qc.compileValid = true
 }
 
 // Executes the circuit with the given register as input.
 // Register is given as an array of 2x1 matrices, given each state.
-func (qc *QuantumCircuit) Exec(qubitStates []Ket) *QuantumCircuitExecution {
+// Gates are applied one at a time directly to the state vector, so the
+// full 2^n x 2^n unitary of the circuit is never built.
+// Measurements and resets in the circuit collapse the register at random,
+// so each call is one shot of the circuit.
+// Circuits of more than 30 qubits (15 with WithDensityMatrix) are too large for a state
+// vector. Those that ExecStabilizer accepts run on a stabilizer tableau instead, and their
+// execution only supports Clbits, MeasureProbabilityOn and Sample; others panic.
+func (qc *QuantumCircuit) Exec(qubitStates []Ket, opts ...ExecOption) *QuantumCircuitExecution {
 	if len(qubitStates) != qc.numQubits {
 		panic(fmt.Sprintf("Cannot execute qubitStates of size %v on circuit with %v qubits", len(qubitStates), qc.numQubits))
 	}
 
-	if !qc.compileValid {
-		qc.Compile()
+	cfg := &execConfig{}
+	for _, opt := range opts {
+		opt(cfg)
 	}
 
-	input := KronKets(qubitStates)
+	if qc.numQubits > cfg.maxQubits() {
+		se, err := qc.ExecStabilizer(qubitStates, opts...)
+		if err != nil {
+			panic(fmt.Sprintf("Cannot execute circuit of %v qubits: the limit for a state vector is %v, "+
+				"and ExecStabilizer rejects it: %v", qc.numQubits, cfg.maxQubits(), err))
+		}
+		return &QuantumCircuitExecution{in: qubitStates, clbits: se.clbits, noise: cfg.noise, stabilizer: se}
+	}
+
+	return qc.exec(qubitStates, KronKets(qubitStates), cfg)
+}
+
+// Runs the circuit on a copy of the input state, which is the product of qubitStates
+// when they are given
+func (qc *QuantumCircuit) exec(qubitStates []Ket, input ColVec, cfg *execConfig) *QuantumCircuitExecution {
+	out := NewColVec(Matrix{
+		Rows:   input.Rows,
+		Cols:   1,
+		Stride: 1,
+		Data:   make([]complex128, len(input.Data)),
+	})
+	copy(out.Data, input.Data)
 
-	return &QuantumCircuitExecution{
+	qce := &QuantumCircuitExecution{
 		in:       qubitStates,
 		register: input,
-		out:      NewColVec(*qc.compiled.Matrix.Mul((Matrix)(input))),
+		noise:    cfg.noise,
 	}
+
+	if cfg.density {
+		dm := newDensityMatrix(out, qc.numQubits)
+		qce.clbits = qc.run(dm, cfg)
+		qce.rho = &dm.rho
+	} else {
+		qce.clbits = qc.run(&statevector{state: out, numQubits: qc.numQubits}, cfg)
+		qce.out = out
+	}
+
+	return qce
+}
+
+// Runs every gate of the circuit on the register state in place.
+// Returns the classical register written by measurements.
+func (qc *QuantumCircuit) run(reg register, cfg *execConfig) []int {
+	clbits := make([]int, qc.numClbits)
+
+	for i := range qc.gates {
+		g := &qc.gates[i]
+		if g.cond != nil && !g.cond.holds(clbits) {
+			continue
+		}
+
+		switch g.name {
+		case MEASURE:
+			outcome := reg.measure(g.targets[0], cfg.float64())
+			if cfg.noise != nil {
+				outcome = cfg.noise.read(g.targets[0], outcome, cfg.float64())
+			}
+			clbits[g.clbits[0]] = outcome
+		case RESET:
+			reg.reset(g.targets[0], cfg.float64())
+		default:
+			if g.kraus != nil {
+				reg.channel(g.kraus, g.targets, cfg.float64())
+			} else {
+				reg.apply(g)
+			}
+		}
+
+		if cfg.noise != nil {
+			for _, e := range cfg.noise.errorsAfter(g, qc.numQubits) {
+				reg.channel(e.kraus, e.targets, cfg.float64())
+			}
+		}
+	}
+
+	return clbits
 }
 
-// Adds another QuantumCircuit to the given QuantumCircuit
-// Compiles the given QuantumCircuit, then adds that single
-// gate to the existing QuantumCircuit.
+// Adds another QuantumCircuit to the given QuantumCircuit, appending each of its
+// gates in order. Measurements, resets, noise channels and conditions are kept, and
+// the classical register grows to hold the other circuit's classical bits.
 func (qc *QuantumCircuit) AddCircuit(c QuantumCircuit) {
 	if qc.numQubits != c.numQubits {
 		panic("Cannot add circuit of different number of qubits")
 	}
 
-	if !c.compileValid {
-		c.Compile()
+	for _, g := range c.gates {
+		qc.addGate(g)
 	}
 
-	qc.addGate(c.compiled)
+	root := qc
+	for root.parent != nil {
+		root = root.parent
+	}
+	if c.numClbits > root.numClbits {
+		root.numClbits = c.numClbits
+	}
 }
@@ -3,6 +3,8 @@ package sim
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"reflect"
 	"testing"
 )
 
@@ -19,7 +21,7 @@ func TestQuantumCircuit_H(t *testing.T) {
 			name:   HADAMARD,
 		}
 		qc.H([]int{0})
-		if len(qc.gates) != 1 || !qc.gates[0].Equals(&expected, StdEpsilon) {
+		if len(qc.gates) != 1 || !qc.gates[0].Expand(qc.numQubits).Equals(expected.Matrix, StdEpsilon) {
 			fmt.Printf("length %v", len(qc.gates))
 			t.Errorf("Add H to circuit makes circuit %v, expected %v", qc.gates[0], expected)
 		}
@@ -49,7 +51,7 @@ func TestQuantumCircuit_H(t *testing.T) {
 			name: HADAMARD,
 		}
 		qc.H([]int{0, 1, 2})
-		if len(qc.gates) != 1 || !qc.gates[0].Equals(&expected3, StdEpsilon) {
+		if len(qc.gates) != 1 || !qc.gates[0].Expand(qc.numQubits).Equals(expected3.Matrix, StdEpsilon) {
 			fmt.Printf("length %v", len(qc.gates))
 			t.Errorf("Add H to circuit makes circuit %v, expected %v", qc.gates[0], expected3)
 		}
@@ -78,7 +80,7 @@ func TestQuantumCircuit_X(t *testing.T) {
 			compiled:     Gate{},
 		}
 		qc.X(0)
-		expected := createX(0, 1)
+		expected := createX(0)
 		if len(qc.gates) != 1 || !qc.gates[0].Equals(expected, StdEpsilon) {
 			t.Errorf("qc.gates = %v, expected %v", qc.gates, []Gate{*expected})
 		}
@@ -92,13 +94,349 @@ func TestQuantumCircuit_X(t *testing.T) {
 			compiled:     Gate{},
 		}
 		qc.X(1)
-		expected := createX(1, 3)
+		expected := createX(1)
 		if len(qc.gates) != 1 || !qc.gates[0].Equals(expected, StdEpsilon) {
 			t.Errorf("qc.gates = %v, expected %v", qc.gates, []Gate{*expected})
 		}
 	})
 }
 
+func TestQuantumCircuit_SingleQubitGates(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+		in    []Ket
+		want  []complex128
+	}{
+		{
+			name:  "Y on |0>",
+			build: func(qc *QuantumCircuit) { qc.Y(0) },
+			in:    []Ket{ZeroKet},
+			want:  []complex128{0, 1i},
+		},
+		{
+			name:  "Z on |->",
+			build: func(qc *QuantumCircuit) { qc.Z(0) },
+			in:    []Ket{HMinusKet},
+			want:  []complex128{1 / math.Sqrt2, 1 / math.Sqrt2},
+		},
+		{
+			name:  "S on second qubit of |11>",
+			build: func(qc *QuantumCircuit) { qc.S(1) },
+			in:    []Ket{OneKet, OneKet},
+			want:  []complex128{0, 0, 0, 1i},
+		},
+		{
+			name:  "Sdg undoes S",
+			build: func(qc *QuantumCircuit) { qc.S(0); qc.Sdg(0) },
+			in:    []Ket{HPlusKet},
+			want:  []complex128{1 / math.Sqrt2, 1 / math.Sqrt2},
+		},
+		{
+			name:  "T twice on |1> is S",
+			build: func(qc *QuantumCircuit) { qc.T(0); qc.T(0) },
+			in:    []Ket{OneKet},
+			want:  []complex128{0, 1i},
+		},
+		{
+			name:  "RY(pi/2) on |0> is |+>",
+			build: func(qc *QuantumCircuit) { qc.RY(math.Pi/2, 0) },
+			in:    []Ket{ZeroKet},
+			want:  []complex128{1 / math.Sqrt2, 1 / math.Sqrt2},
+		},
+		{
+			name:  "RX(pi) on second qubit of |00>",
+			build: func(qc *QuantumCircuit) { qc.RX(math.Pi, 1) },
+			in:    []Ket{ZeroKet, ZeroKet},
+			want:  []complex128{0, -1i, 0, 0},
+		},
+		{
+			name:  "RZ then P on |+>",
+			build: func(qc *QuantumCircuit) { qc.RZ(math.Pi, 0); qc.P(math.Pi, 0) },
+			in:    []Ket{HPlusKet},
+			want:  []complex128{-1i / math.Sqrt2, -1i / math.Sqrt2},
+		},
+		{
+			name:  "U(pi/2, 0, pi) acts as H",
+			build: func(qc *QuantumCircuit) { qc.U(math.Pi/2, 0, math.Pi, 0) },
+			in:    []Ket{HMinusKet},
+			want:  []complex128{0, 1},
+		},
+		{
+			name:  "Tdg on |1>",
+			build: func(qc *QuantumCircuit) { qc.Tdg(0) },
+			in:    []Ket{OneKet},
+			want:  []complex128{0, complex(1/math.Sqrt2, -1/math.Sqrt2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(len(tt.in))
+			tt.build(&qc)
+			want := NewColVec(Matrix{Rows: len(tt.want), Cols: 1, Stride: 1, Data: tt.want})
+			if got := qc.Exec(tt.in); !Matrix(got.out).Equals(Matrix(want), StdEpsilon) {
+				t.Errorf("Exec() = %v, want %v", got.out, want)
+			}
+		})
+	}
+}
+
+func TestQuantumCircuit_RotationParams(t *testing.T) {
+	qc := NewQuantumCircuit(1)
+	qc.RX(0.1, 0)
+	qc.U(0.2, 0.3, 0.4, 0)
+	qc.H([]int{0})
+
+	want := [][]float64{{0.1}, {0.2, 0.3, 0.4}, nil}
+	for i, g := range qc.gates {
+		if !floatsEqual(g.Params(), want[i], FloatEpsilon) {
+			t.Errorf("gate %v Params() = %v, want %v", i, g.Params(), want[i])
+		}
+	}
+}
+
+func TestQuantumCircuit_TwoQubitGates(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+		in    []Ket
+		want  []complex128
+	}{
+		{
+			name:  "CZ kicks phase back onto |+>",
+			build: func(qc *QuantumCircuit) { qc.CZ(0, 1) },
+			in:    []Ket{HPlusKet, OneKet},
+			want:  []complex128{0, 1 / math.Sqrt2, 0, -1 / math.Sqrt2},
+		},
+		{
+			name:  "CY on |11>",
+			build: func(qc *QuantumCircuit) { qc.CY(1, 0) },
+			in:    []Ket{OneKet, OneKet},
+			want:  []complex128{0, -1i, 0, 0},
+		},
+		{
+			name:  "CH does nothing with the control off",
+			build: func(qc *QuantumCircuit) { qc.CH(0, 1) },
+			in:    []Ket{ZeroKet, OneKet},
+			want:  []complex128{0, 1, 0, 0},
+		},
+		{
+			name:  "SWAP |01>",
+			build: func(qc *QuantumCircuit) { qc.SWAP(0, 1) },
+			in:    []Ket{ZeroKet, OneKet},
+			want:  []complex128{0, 0, 1, 0},
+		},
+		{
+			name:  "ISWAP |10>",
+			build: func(qc *QuantumCircuit) { qc.ISWAP(1, 0) },
+			in:    []Ket{OneKet, ZeroKet},
+			want:  []complex128{0, 1i, 0, 0},
+		},
+		{
+			name:  "CP(pi) matches CZ",
+			build: func(qc *QuantumCircuit) { qc.CP(math.Pi, 0, 1) },
+			in:    []Ket{OneKet, HPlusKet},
+			want:  []complex128{0, 0, 1 / math.Sqrt2, -1 / math.Sqrt2},
+		},
+		{
+			name:  "CRX(pi) on |10>",
+			build: func(qc *QuantumCircuit) { qc.CRX(math.Pi, 0, 1) },
+			in:    []Ket{OneKet, ZeroKet},
+			want:  []complex128{0, 0, 0, -1i},
+		},
+		{
+			name:  "CRY(pi) on |11>",
+			build: func(qc *QuantumCircuit) { qc.CRY(math.Pi, 0, 1) },
+			in:    []Ket{OneKet, OneKet},
+			want:  []complex128{0, 0, -1, 0},
+		},
+		{
+			name:  "CRZ(pi) on |11>",
+			build: func(qc *QuantumCircuit) { qc.CRZ(math.Pi, 0, 1) },
+			in:    []Ket{OneKet, OneKet},
+			want:  []complex128{0, 0, 0, 1i},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(len(tt.in))
+			tt.build(&qc)
+			want := NewColVec(Matrix{Rows: len(tt.want), Cols: 1, Stride: 1, Data: tt.want})
+			if got := qc.Exec(tt.in); !Matrix(got.out).Equals(Matrix(want), StdEpsilon) {
+				t.Errorf("Exec() = %v, want %v", got.out, want)
+			}
+		})
+	}
+}
+
+func TestQuantumCircuit_MultiControlledGates(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+		in    []Ket
+		want  []complex128
+	}{
+		{
+			name:  "CCX flips the target with both controls on",
+			build: func(qc *QuantumCircuit) { qc.CCX(0, 1, 2) },
+			in:    []Ket{OneKet, OneKet, ZeroKet},
+			want:  []complex128{0, 0, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			name:  "CCX does nothing with one control off",
+			build: func(qc *QuantumCircuit) { qc.CCX(0, 1, 2) },
+			in:    []Ket{OneKet, ZeroKet, ZeroKet},
+			want:  []complex128{0, 0, 0, 0, 1, 0, 0, 0},
+		},
+		{
+			name:  "CSWAP |101>",
+			build: func(qc *QuantumCircuit) { qc.CSWAP(0, 1, 2) },
+			in:    []Ket{OneKet, ZeroKet, OneKet},
+			want:  []complex128{0, 0, 0, 0, 0, 0, 1, 0},
+		},
+		{
+			name:  "MCX with the target between controls",
+			build: func(qc *QuantumCircuit) { qc.MCX([]int{0, 2, 3}, 1) },
+			in:    []Ket{OneKet, ZeroKet, OneKet, OneKet},
+			want:  []complex128{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			name:  "MCX with a negative control on |0>",
+			build: func(qc *QuantumCircuit) { qc.MCX([]int{NegControl(0), 1}, 2) },
+			in:    []Ket{ZeroKet, OneKet, ZeroKet},
+			want:  []complex128{0, 0, 0, 1, 0, 0, 0, 0},
+		},
+		{
+			name:  "MCX with a negative control on |1>",
+			build: func(qc *QuantumCircuit) { qc.MCX([]int{NegControl(0), 1}, 2) },
+			in:    []Ket{OneKet, OneKet, ZeroKet},
+			want:  []complex128{0, 0, 0, 0, 0, 0, 1, 0},
+		},
+		{
+			name:  "MCU of SWAP matches CSWAP",
+			build: func(qc *QuantumCircuit) { qc.MCU([]int{2}, Swap, []int{0, 1}) },
+			in:    []Ket{OneKet, ZeroKet, OneKet},
+			want:  []complex128{0, 0, 0, 1, 0, 0, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(len(tt.in))
+			tt.build(&qc)
+			want := NewColVec(Matrix{Rows: len(tt.want), Cols: 1, Stride: 1, Data: tt.want})
+			if got := qc.Exec(tt.in); !Matrix(got.out).Equals(Matrix(want), StdEpsilon) {
+				t.Errorf("Exec() = %v, want %v", got.out, want)
+			}
+		})
+	}
+
+	t.Run("Panic on matrix not matching targets", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Does not panic on matrix not matching targets")
+			}
+		}()
+		qc.MCU([]int{0}, X, []int{1, 2})
+	})
+
+	t.Run("Panic on repeated qubit", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Does not panic on repeated qubit")
+			}
+		}()
+		qc.MCX([]int{0, NegControl(0)}, 2)
+	})
+
+	panics := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+	}{
+		{"Negative control not from NegControl", func(qc *QuantumCircuit) { qc.CZ(-3, 0) }},
+		{"Negative target", func(qc *QuantumCircuit) { qc.X(-1) }},
+		{"Target out of range", func(qc *QuantumCircuit) { qc.CX(0, 3) }},
+		{"Control out of range", func(qc *QuantumCircuit) { qc.CCX(0, 5, 1) }},
+		{"Negative control out of range", func(qc *QuantumCircuit) { qc.MCX([]int{NegControl(3)}, 0) }},
+		{"Conditioned gate out of range", func(qc *QuantumCircuit) { qc.If(0, 1).RX(0.5, 4) }},
+	}
+	for _, tt := range panics {
+		t.Run("Panic on "+tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(3)
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Does not panic on %v", tt.name)
+				}
+			}()
+			tt.build(&qc)
+		})
+	}
+}
+
+func TestQuantumCircuit_Unitary(t *testing.T) {
+	t.Run("Add custom gate to circuit", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		if err := qc.Unitary(Swap, []int{1, 0}, "my-swap"); err != nil {
+			t.Fatalf("Unitary() returned error %v", err)
+		}
+		if got := qc.gates[0].Name(); got != "my-swap" {
+			t.Errorf("Name() = %v, want my-swap", got)
+		}
+		if got := qc.Exec([]Ket{OneKet, ZeroKet}); !floatsEqual(got.MeasureProbabilities(), []float64{0, 1, 0, 0}, FloatEpsilon) {
+			t.Errorf("Exec() = %v, want |01>", got.out)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		m      Matrix
+		qubits []int
+	}{
+		{
+			name:   "Not square",
+			m:      Matrix{Rows: 2, Cols: 1, Stride: 1, Data: []complex128{1, 0}},
+			qubits: []int{0},
+		},
+		{
+			name:   "Size does not match qubits",
+			m:      X,
+			qubits: []int{0, 1},
+		},
+		{
+			name:   "Not a power of two",
+			m:      Identity(3),
+			qubits: []int{0},
+		},
+		{
+			name:   "Not unitary",
+			m:      Matrix{Rows: 2, Cols: 2, Stride: 2, Data: []complex128{1, 1, 0, 1}},
+			qubits: []int{0},
+		},
+		{
+			name:   "Qubit out of range",
+			m:      X,
+			qubits: []int{2},
+		},
+		{
+			name:   "Repeated qubit",
+			m:      Swap,
+			qubits: []int{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(2)
+			if err := qc.Unitary(tt.m, tt.qubits, "bad"); err == nil {
+				t.Errorf("Unitary() returned no error")
+			}
+			if len(qc.gates) != 0 {
+				t.Errorf("Unitary() added a gate despite the error")
+			}
+		})
+	}
+}
+
 func TestQuantumCircuit_CX(t *testing.T) {
 	t.Run("Add CX to circuit", func(t *testing.T) {
 		qc := &QuantumCircuit{
@@ -120,7 +458,7 @@ func TestQuantumCircuit_CX(t *testing.T) {
 			name: CX,
 		}
 		qc.CX(0, 1)
-		if len(qc.gates) != 1 || !qc.gates[0].Equals(&expected, StdEpsilon) {
+		if len(qc.gates) != 1 || !qc.gates[0].Expand(qc.numQubits).Equals(expected.Matrix, StdEpsilon) {
 			fmt.Printf("length %v", len(qc.gates))
 			t.Errorf("Add CX to circuit makes circuit %v, expected %v", qc.gates[0], expected)
 		}
@@ -158,7 +496,7 @@ func TestQuantumCircuit_CX(t *testing.T) {
 			name: CX,
 		}
 		qc.CX(2, 3)
-		if len(qc.gates) != 1 || !qc.gates[0].Equals(&expected, StdEpsilon) {
+		if len(qc.gates) != 1 || !qc.gates[0].Expand(qc.numQubits).Equals(expected.Matrix, StdEpsilon) {
 			fmt.Printf("length %v", len(qc.gates))
 			t.Errorf("Add CX to circuit makes circuit %v, expected %v", qc.gates[0], expected)
 		}
@@ -203,11 +541,11 @@ func TestQuantumCircuit_Compile(t *testing.T) {
 	t.Run("Compile CX on two qubits", func(t *testing.T) {
 		qc := &QuantumCircuit{
 			numQubits: 2,
-			gates:     []Gate{*createCX(0, 1, 2)},
+			gates:     []Gate{*createCX(0, 1)},
 		}
-		expected := *createCX(0, 1, 2)
+		expected := *createCX(0, 1)
 		qc.Compile()
-		if !qc.compiled.Matrix.Equals(expected.Matrix, StdEpsilon) {
+		if !qc.compiled.Matrix.Equals(expected.Expand(2), StdEpsilon) {
 			t.Errorf("Compiling CX gate makes circuit %v, expected %v", qc.compiled, expected)
 		}
 		if !qc.compileValid {
@@ -218,7 +556,7 @@ func TestQuantumCircuit_Compile(t *testing.T) {
 	t.Run("Compile H(0) CX", func(t *testing.T) {
 		qc := &QuantumCircuit{
 			numQubits: 2,
-			gates:     []Gate{*createH([]int{0}, 2), *createCX(0, 1, 2)},
+			gates:     []Gate{*createH([]int{0}), *createCX(0, 1)},
 		}
 		expectedMatrix := Matrix{
 			Rows:   4,
@@ -284,9 +622,9 @@ func TestQuantumCircuit_Exec(t *testing.T) {
 			fields: fields{
 				numQubits: 2,
 				gates: []Gate{
-					*createH([]int{0, 1}, 2),
+					*createH([]int{0, 1}),
 				},
-				compiled: *createH([]int{0, 1}, 2),
+				compiled: *createH([]int{0, 1}),
 			},
 			args: args{
 				register: []Ket{ZeroKet, OneKet},
@@ -306,9 +644,9 @@ func TestQuantumCircuit_Exec(t *testing.T) {
 			fields: fields{
 				numQubits: 3,
 				gates: []Gate{
-					*createH([]int{0, 1, 2}, 3),
+					*createH([]int{0, 1, 2}),
 				},
-				compiled: *createH([]int{0, 1, 2}, 3),
+				compiled: *createH([]int{0, 1, 2}),
 			},
 			args: args{
 				register: []Ket{OneKet, HPlusKet, HMinusKet},
@@ -339,7 +677,7 @@ func TestQuantumCircuit_Exec(t *testing.T) {
 	t.Run("Panic on not enough qubits", func(t *testing.T) {
 		qc := QuantumCircuit{
 			numQubits:    2,
-			gates:        []Gate{*createH([]int{0}, 2)},
+			gates:        []Gate{*createH([]int{0})},
 			compileValid: false,
 			compiled:     Gate{},
 		}
@@ -355,7 +693,7 @@ func TestQuantumCircuit_Exec(t *testing.T) {
 }
 
 func TestQuantumCircuit_addGate(t *testing.T) {
-	h := createH([]int{0, 1}, 2)
+	h := createH([]int{0, 1})
 	t.Run("Add gate to empty circuit", func(t *testing.T) {
 		qc := &QuantumCircuit{
 			numQubits:    2,
@@ -379,7 +717,7 @@ func TestQuantumCircuit_addGate(t *testing.T) {
 			compiled:     *h,
 		}
 
-		cx := createCX(0, 1, 2)
+		cx := createCX(0, 1)
 		qc.addGate(*cx)
 		if !(len(qc.gates) == 2) || !qc.gates[0].Equals(h, StdEpsilon) || !qc.gates[1].Equals(cx, StdEpsilon) || qc.compileValid {
 			t.Errorf("addGate() = %v, want %v", qc.gates, []Gate{*h, *cx})
@@ -510,6 +848,12 @@ func floatsEqual(a, b []float64, epsilon float64) bool {
 	return true
 }
 
+// Adds the gates of inner to qc as the single gate on the whole register that Compile builds
+func addCompiled(qc *QuantumCircuit, inner QuantumCircuit) {
+	inner.Compile()
+	qc.addGate(inner.compiled)
+}
+
 func TestQuantumCircuitExecution_MeasureProbabilities(t *testing.T) {
 	type fields struct {
 		in       []Ket
@@ -587,9 +931,9 @@ func TestQuantumCircuit_AddCircuit(t *testing.T) {
 	t.Run("Add empty circuit", func(t *testing.T) {
 		qc := QuantumCircuit{
 			numQubits:    2,
-			gates:        []Gate{*createH([]int{0, 1}, 2)},
+			gates:        []Gate{*createH([]int{0, 1})},
 			compileValid: true,
-			compiled:     *createH([]int{0, 1}, 2),
+			compiled:     *createH([]int{0, 1}),
 		}
 		toAdd := QuantumCircuit{
 			numQubits:    2,
@@ -599,9 +943,9 @@ func TestQuantumCircuit_AddCircuit(t *testing.T) {
 		}
 		expected := QuantumCircuit{
 			numQubits:    2,
-			gates:        []Gate{*createH([]int{0, 1}, 2), *createWire(2)},
-			compileValid: false,
-			compiled:     *createH([]int{0, 1}, 2),
+			gates:        []Gate{*createH([]int{0, 1})},
+			compileValid: true,
+			compiled:     *createH([]int{0, 1}),
 		}
 
 		qc.AddCircuit(toAdd)
@@ -610,6 +954,43 @@ func TestQuantumCircuit_AddCircuit(t *testing.T) {
 			t.Errorf("AddCircuit got %v, wanted %v", qc, expected)
 		}
 	})
+
+	t.Run("Add circuit with measurements and conditions", func(t *testing.T) {
+		inner := NewQuantumCircuit(2)
+		inner.X(0)
+		inner.Measure(0, 1)
+		inner.If(1, 1).X(1)
+		inner.AddClbits(1)
+
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{1})
+		qc.AddCircuit(inner)
+
+		if len(qc.gates) != 4 || qc.NumClbits() != 3 {
+			t.Fatalf("AddCircuit gives %v gates and %v clbits, want 4 and 3", len(qc.gates), qc.NumClbits())
+		}
+		for i := range inner.gates {
+			if !qc.gates[i+1].Equals(&inner.gates[i], StdEpsilon) {
+				t.Errorf("AddCircuit gate %v = %v, want %v", i+1, qc.gates[i+1], inner.gates[i])
+			}
+		}
+		if got := qc.Exec([]Ket{ZeroKet, ZeroKet}).Clbits(); !reflect.DeepEqual(got, []int{0, 1, 0}) {
+			t.Errorf("Clbits() = %v, want [0 1 0]", got)
+		}
+	})
+
+	t.Run("Add circuit through a condition", func(t *testing.T) {
+		inner := NewQuantumCircuit(1)
+		inner.X(0)
+
+		qc := NewQuantumCircuit(1)
+		qc.Measure(0, 0)
+		qc.If(0, 1).AddCircuit(inner)
+
+		if clbits, value := qc.gates[1].Condition(); !reflect.DeepEqual(clbits, []int{0}) || value != 1 {
+			t.Errorf("AddCircuit through If gives condition %v == %v, want [0] == 1", clbits, value)
+		}
+	})
 	/*
 	     {2 [{{4 4 4 [(0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001-0i)]} 0}
 	         {{4 4 4 [(1+0i) (0+0i) (0+0i) (0+0i) (0+0i) (1+0i) (0+0i) (0+0i) (0+0i) (0+0i) (1+0i) (0+0i) (0+0i) (0+0i) (0+0i) (1+0i)]} 1}] false {{4 4 4 [(0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001+0i) (-0.5000000000000001+0i) (-0.5000000000000001+0i) (0.5000000000000001-0i)]} 0}},
@@ -699,3 +1080,233 @@ func TestQuantumCircuitExecution_MeasureProbabilityOn(t *testing.T) {
 		})
 	}
 }
+
+func TestQuantumCircuitExecution_Sample(t *testing.T) {
+	bell := NewQuantumCircuit(2)
+	bell.H([]int{0})
+	bell.CX(0, 1)
+	qce := bell.Exec([]Ket{ZeroKet, ZeroKet})
+
+	t.Run("Bell state only gives correlated outcomes", func(t *testing.T) {
+		counts := qce.Sample(10000, rand.New(rand.NewSource(1)))
+		if counts["00"]+counts["11"] != 10000 || len(counts) != 2 {
+			t.Errorf("Sample() = %v, want only 00 and 11", counts)
+		}
+		if math.Abs(float64(counts["00"])/10000-0.5) > 0.02 {
+			t.Errorf("Sample() = %v, want 00 and 11 about equally often", counts)
+		}
+	})
+
+	t.Run("Same seed gives same counts", func(t *testing.T) {
+		a := qce.Sample(1000, rand.New(rand.NewSource(42)))
+		b := qce.Sample(1000, rand.New(rand.NewSource(42)))
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("Sample() = %v and %v with the same seed", a, b)
+		}
+	})
+
+	t.Run("Qubit 0 is the first character", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.X(0)
+		counts := qc.Exec([]Ket{ZeroKet, ZeroKet, ZeroKet}).Sample(10, rand.New(rand.NewSource(1)))
+		if counts["100"] != 10 {
+			t.Errorf("Sample() = %v, want 100 every shot", counts)
+		}
+	})
+}
+
+func TestQuantumCircuit_Measure(t *testing.T) {
+	t.Run("Measure |1> writes 1", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.X(1)
+		qc.Measure(1, 2)
+		if qc.NumClbits() != 3 {
+			t.Errorf("NumClbits() = %v, want 3", qc.NumClbits())
+		}
+		qce := qc.Exec([]Ket{ZeroKet, ZeroKet})
+		if !reflect.DeepEqual(qce.Clbits(), []int{0, 0, 1}) {
+			t.Errorf("Clbits() = %v, want [0 0 1]", qce.Clbits())
+		}
+	})
+
+	t.Run("AddClbits declares bits before measuring", func(t *testing.T) {
+		qc := NewQuantumCircuit(1)
+		qc.Measure(0, 0)
+		if first := qc.If(0, 1).AddClbits(2); first != 1 || qc.NumClbits() != 3 {
+			t.Errorf("AddClbits(2) = %v with NumClbits() = %v, want 1 and 3", first, qc.NumClbits())
+		}
+		if got := qc.Exec([]Ket{OneKet}).Clbits(); !reflect.DeepEqual(got, []int{1, 0, 0}) {
+			t.Errorf("Clbits() = %v, want [1 0 0]", got)
+		}
+	})
+
+	t.Run("Bell pair measurements agree and collapse the state", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.CX(0, 1)
+		qc.Measure(0, 0)
+		qc.Measure(1, 1)
+
+		rng := rand.New(rand.NewSource(3))
+		seen := map[int]bool{}
+		for shot := 0; shot < 50; shot++ {
+			qce := qc.Exec([]Ket{ZeroKet, ZeroKet}, WithRand(rng))
+			c := qce.Clbits()
+			if c[0] != c[1] {
+				t.Fatalf("Clbits() = %v, want equal bits", c)
+			}
+			if got := qce.MeasureProbabilityOn(0); !floatEqual(got, float64(c[0]), FloatEpsilon) {
+				t.Fatalf("MeasureProbabilityOn(0) = %v after measuring %v", got, c[0])
+			}
+			seen[c[0]] = true
+		}
+		if !seen[0] || !seen[1] {
+			t.Errorf("only saw outcomes %v over 50 shots", seen)
+		}
+	})
+
+	t.Run("Same seed gives same outcomes", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 1, 2})
+		qc.Measure(0, 0)
+		qc.Measure(1, 1)
+		qc.Measure(2, 2)
+		a := qc.Exec([]Ket{ZeroKet, ZeroKet, ZeroKet}, WithRand(rand.New(rand.NewSource(9)))).Clbits()
+		b := qc.Exec([]Ket{ZeroKet, ZeroKet, ZeroKet}, WithRand(rand.New(rand.NewSource(9)))).Clbits()
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("Clbits() = %v and %v with the same seed", a, b)
+		}
+	})
+
+	t.Run("Panic on compiling a measurement", func(t *testing.T) {
+		qc := NewQuantumCircuit(1)
+		qc.Measure(0, 0)
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Does not panic on compiling a measurement")
+			}
+		}()
+		qc.Compile()
+	})
+}
+
+func TestQuantumCircuit_Reset(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Ket
+	}{
+		{name: "Reset |1>", in: []Ket{OneKet, OneKet}},
+		{name: "Reset |+>", in: []Ket{OneKet, HPlusKet}},
+		{name: "Reset |0>", in: []Ket{OneKet, ZeroKet}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(2)
+			qc.Reset(1)
+			qce := qc.Exec(tt.in, WithRand(rand.New(rand.NewSource(1))))
+			if got := qce.MeasureProbabilities(); !floatsEqual(got, []float64{0, 0, 1, 0}, FloatEpsilon) {
+				t.Errorf("MeasureProbabilities() = %v, want |10>", got)
+			}
+		})
+	}
+}
+
+func TestQuantumCircuit_If(t *testing.T) {
+	t.Run("Teleportation", func(t *testing.T) {
+		theta := 1.2
+		qc := NewQuantumCircuit(3)
+		qc.RY(theta, 0)
+		qc.H([]int{1})
+		qc.CX(1, 2)
+		qc.CX(0, 1)
+		qc.H([]int{0})
+		qc.Measure(0, 0)
+		qc.Measure(1, 1)
+		qc.If(1, 1).X(2)
+		qc.If(0, 1).Z(2)
+
+		rng := rand.New(rand.NewSource(5))
+		want := math.Pow(math.Sin(theta/2), 2)
+		for shot := 0; shot < 20; shot++ {
+			qce := qc.Exec([]Ket{ZeroKet, ZeroKet, ZeroKet}, WithRand(rng))
+			if got := qce.MeasureProbabilityOn(2); !floatEqual(got, want, FloatEpsilon) {
+				t.Fatalf("MeasureProbabilityOn(2) = %v with clbits %v, want %v", got, qce.Clbits(), want)
+			}
+		}
+	})
+
+	tests := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+		want  []float64
+	}{
+		{
+			name: "Condition not met",
+			build: func(qc *QuantumCircuit) {
+				qc.Measure(0, 0)
+				qc.If(0, 1).X(1)
+			},
+			want: []float64{1, 0, 0, 0},
+		},
+		{
+			name: "Condition met",
+			build: func(qc *QuantumCircuit) {
+				qc.X(0)
+				qc.Measure(0, 0)
+				qc.If(0, 1).X(1)
+			},
+			want: []float64{0, 0, 0, 1},
+		},
+		{
+			name: "Register value with first bit least significant",
+			build: func(qc *QuantumCircuit) {
+				qc.X(0)
+				qc.Measure(0, 0)
+				qc.Measure(1, 1)
+				qc.IfRegister([]int{0, 1}, 1).X(1)
+			},
+			want: []float64{0, 0, 0, 1},
+		},
+		{
+			name: "Register value not met",
+			build: func(qc *QuantumCircuit) {
+				qc.X(0)
+				qc.Measure(0, 0)
+				qc.Measure(1, 1)
+				qc.IfRegister([]int{0, 1}, 2).X(1)
+			},
+			want: []float64{0, 0, 1, 0},
+		},
+		{
+			name: "Nested conditions must both hold",
+			build: func(qc *QuantumCircuit) {
+				qc.X(0)
+				qc.Measure(0, 0)
+				qc.Measure(1, 1)
+				qc.If(0, 1).If(1, 1).X(1)
+				qc.If(0, 1).If(1, 0).Z(0)
+			},
+			want: []float64{0, 0, 1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(2)
+			tt.build(&qc)
+			if got := qc.Exec([]Ket{ZeroKet, ZeroKet}).MeasureProbabilities(); !floatsEqual(got, tt.want, FloatEpsilon) {
+				t.Errorf("MeasureProbabilities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Condition grows the classical register", func(t *testing.T) {
+		qc := NewQuantumCircuit(1)
+		qc.If(3, 0).X(0)
+		if qc.NumClbits() != 4 || len(qc.gates) != 1 {
+			t.Errorf("NumClbits() = %v with %v gates, want 4 with 1 gate", qc.NumClbits(), len(qc.gates))
+		}
+		if bits, value := qc.gates[0].Condition(); !reflect.DeepEqual(bits, []int{3}) || value != 0 {
+			t.Errorf("Condition() = %v, %v, want [3], 0", bits, value)
+		}
+	})
+}
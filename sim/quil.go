@@ -0,0 +1,232 @@
+package sim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names of gates in Quil, as applied to their targets without controls
+var quilNames = map[GateName]string{
+	HADAMARD: "H", CH: "H",
+	PAULIX: "X", CX: "X", CCX: "X", MCX: "X",
+	PAULIY: "Y", CY: "Y",
+	PAULIZ: "Z", CZ: "Z",
+	SGATE: "S", SDAGGER: "DAGGER S", TGATE: "T", TDAGGER: "DAGGER T",
+	ROTX: "RX", CROTX: "RX",
+	ROTY: "RY", CROTY: "RY",
+	ROTZ: "RZ", CROTZ: "RZ",
+	PHASE: "PHASE", CPHASE: "PHASE",
+	SWAP: "SWAP", CSWAP: "SWAP",
+	ISWAP: "ISWAP",
+}
+
+// Standard Quil gates with controls, by uncontrolled name and number of controls
+var quilControlled = map[string][]string{
+	"X":     {1: "CNOT", 2: "CCNOT"},
+	"Z":     {1: "CZ"},
+	"PHASE": {1: "CPHASE"},
+	"SWAP":  {1: "CSWAP"},
+}
+
+// Identifiers DEFGATE names cannot take: the standard gates and instructions
+var quilReserved = map[string]bool{
+	"I": true, "X": true, "Y": true, "Z": true, "H": true, "S": true, "T": true, "PHASE": true,
+	"RX": true, "RY": true, "RZ": true, "CZ": true, "CNOT": true, "CCNOT": true, "CPHASE00": true,
+	"CPHASE01": true, "CPHASE10": true, "CPHASE": true, "SWAP": true, "CSWAP": true, "ISWAP": true,
+	"PSWAP": true, "CONTROLLED": true, "DAGGER": true, "FORKED": true, "DEFGATE": true, "DECLARE": true,
+	"MEASURE": true, "RESET": true, "JUMP": true, "JUMP-WHEN": true, "JUMP-UNLESS": true, "LABEL": true,
+	"HALT": true, "NOP": true, "PRAGMA": true, "AS": true, "MATRIX": true,
+}
+
+// Writes the circuit as a Quil program. Measurements write to the classical memory ro,
+// indexed like the circuit's classical bits. Custom unitaries and multi-controlled matrices
+// are written as DEFGATE definitions, and conditioned gates are skipped over with JUMP-WHEN
+// and JUMP-UNLESS. Returns an error for noise channels and compiled circuits, which Quil
+// cannot express.
+func (qc *QuantumCircuit) ToQuil() (string, error) {
+	w := &quilWriter{defined: map[string]Matrix{}}
+
+	for i := range qc.gates {
+		g := &qc.gates[i]
+		stmts, err := w.gate(g)
+		if err != nil {
+			return "", fmt.Errorf("gate %v: %v", i, err)
+		}
+		if g.cond == nil || len(stmts) == 0 {
+			w.body = append(w.body, stmts...)
+			continue
+		}
+
+		// Jump past the gate unless every bit of the condition has its value
+		label := fmt.Sprintf("@skip%v", w.labels)
+		w.labels++
+		for j, b := range g.cond.clbits {
+			jump := "JUMP-UNLESS"
+			if g.cond.value>>uint(j)&1 == 0 {
+				jump = "JUMP-WHEN"
+			}
+			w.body = append(w.body, fmt.Sprintf("%v %v ro[%v]", jump, label, b))
+		}
+		w.body = append(w.body, stmts...)
+		w.body = append(w.body, "LABEL "+label)
+	}
+
+	var b strings.Builder
+	if qc.numClbits > 0 {
+		fmt.Fprintf(&b, "DECLARE ro BIT[%v]\n", qc.numClbits)
+	}
+	for _, d := range w.defs {
+		b.WriteString(d + "\n")
+	}
+	for _, s := range w.body {
+		b.WriteString(s + "\n")
+	}
+	return b.String(), nil
+}
+
+type quilWriter struct {
+	defs    []string          // DEFGATE definitions, in order of first use
+	defined map[string]Matrix // Matrix of each defined gate
+	labels  int               // Number of jump labels used
+	body    []string
+}
+
+// Statements applying the gate, without its condition
+func (w *quilWriter) gate(g *Gate) ([]string, error) {
+	switch {
+	case g.kraus != nil:
+		return nil, fmt.Errorf("%v noise channel has no Quil equivalent", g.Name())
+	case g.targets == nil && g.name == WIRE:
+		return nil, nil
+	case g.targets == nil:
+		return nil, fmt.Errorf("%v acts on the whole register and has no Quil equivalent", g.Name())
+	}
+
+	switch g.name {
+	case MEASURE:
+		return []string{fmt.Sprintf("MEASURE %v ro[%v]", g.targets[0], g.clbits[0])}, nil
+	case RESET:
+		return []string{fmt.Sprintf("RESET %v", g.targets[0])}, nil
+	case U3:
+		// Equal to U3 up to a global phase, which is unobservable on an uncontrolled gate
+		theta, phi, lambda := g.params[0], g.params[1], g.params[2]
+		q := g.targets[0]
+		return []string{
+			fmt.Sprintf("RZ(%v) %v", formatAngle(lambda), q),
+			fmt.Sprintf("RY(%v) %v", formatAngle(theta), q),
+			fmt.Sprintf("RZ(%v) %v", formatAngle(phi), q),
+		}, nil
+	case CUSTOM:
+		name := w.define(g.label, g.Matrix)
+		return []string{name + " " + quilOperands(g.targets)}, nil
+	case MCU:
+		name := w.define("MCU", g.Matrix)
+		return w.controlled(g, name), nil
+	}
+
+	name, ok := quilNames[g.name]
+	if !ok {
+		return nil, fmt.Errorf("%v has no Quil equivalent", g.Name())
+	}
+	if len(g.params) > 0 {
+		name += "(" + formatAngle(g.params[0]) + ")"
+	}
+	if len(g.controls)+len(g.negControls) == 0 {
+		// Larger matrices act jointly on their targets, and 2x2 ones on each target
+		if g.Rows > 2 {
+			return []string{name + " " + quilOperands(g.targets)}, nil
+		}
+		var stmts []string
+		for _, t := range g.targets {
+			stmts = append(stmts, fmt.Sprintf("%v %v", name, t))
+		}
+		return stmts, nil
+	}
+	return w.controlled(g, name), nil
+}
+
+// Statements applying the named gate to the targets of g under its controls,
+// flipping negative controls with X gates around it
+func (w *quilWriter) controlled(g *Gate, name string) []string {
+	numControls := len(g.controls) + len(g.negControls)
+	base := strings.SplitN(name, "(", 2)
+	var stmt string
+	if names := quilControlled[base[0]]; numControls > 0 && numControls < len(names) && names[numControls] != "" {
+		stmt = names[numControls]
+		if len(base) > 1 {
+			stmt += "(" + base[1]
+		}
+	} else {
+		stmt = strings.Repeat("CONTROLLED ", numControls) + name
+	}
+	stmt += " " + quilOperands(g.Qubits())
+
+	var flips []string
+	for _, q := range g.negControls {
+		flips = append(flips, fmt.Sprintf("X %v", q))
+	}
+	return append(append(append([]string{}, flips...), stmt), flips...)
+}
+
+// Name of the DEFGATE for a matrix, defining it on first use. Labels are turned into
+// identifiers, and given a suffix if they are reserved or already define another matrix.
+func (w *quilWriter) define(label string, m Matrix) string {
+	name := []rune(label)
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			name[i] = '_'
+		}
+	}
+	base := string(name)
+	if base == "" {
+		base = "CUSTOM"
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		defined, used := w.defined[candidate]
+		if used && defined.Equals(m, complex(unitaryTolerance, unitaryTolerance)) {
+			return candidate
+		}
+		if !used && !quilReserved[candidate] {
+			break
+		}
+		candidate = fmt.Sprintf("%v_%v", base, i)
+	}
+
+	w.defined[candidate] = m
+	def := []string{"DEFGATE " + candidate + ":"}
+	for r := 0; r < m.Rows; r++ {
+		entries := make([]string, m.Cols)
+		for c := range entries {
+			entries[c] = quilComplex(m.Data[r*m.Stride+c])
+		}
+		def = append(def, "    "+strings.Join(entries, ", "))
+	}
+	w.defs = append(w.defs, strings.Join(def, "\n"))
+	return candidate
+}
+
+func quilOperands(qubits []int) string {
+	operands := make([]string, len(qubits))
+	for i, q := range qubits {
+		operands[i] = strconv.Itoa(q)
+	}
+	return strings.Join(operands, " ")
+}
+
+// Formats a matrix entry as a Quil expression, such as 0.5, -1i or 0.5+0.5i
+func quilComplex(z complex128) string {
+	re, im := real(z), imag(z)
+	format := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	switch {
+	case im == 0:
+		return format(re)
+	case re == 0:
+		return format(im) + "i"
+	case im < 0:
+		return format(re) + format(im) + "i"
+	}
+	return format(re) + "+" + format(im) + "i"
+}
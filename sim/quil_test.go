@@ -0,0 +1,88 @@
+package sim
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_ToQuil(t *testing.T) {
+	t.Run("Program", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 2})
+		qc.CX(0, 1)
+		qc.Sdg(2)
+		qc.CP(-math.Pi/2, 1, 2)
+		qc.CRX(0.5, 2, 0)
+		qc.MCX([]int{0, NegControl(1)}, 2)
+		qc.MCU([]int{0, 1}, H, []int{2})
+		qc.Unitary(X, []int{1}, "My gate")
+		qc.Unitary(Y, []int{0}, "X")
+		qc.Measure(0, 0)
+		qc.Measure(1, 1)
+		qc.IfRegister([]int{0, 1}, 1).Z(2)
+		qc.Reset(0)
+
+		got, err := qc.ToQuil()
+		if err != nil {
+			t.Fatalf("ToQuil() error = %v", err)
+		}
+		want := `DECLARE ro BIT[2]
+DEFGATE MCU:
+    0.7071067811865476, 0.7071067811865476
+    0.7071067811865476, -0.7071067811865476
+DEFGATE My_gate:
+    0, 1
+    1, 0
+DEFGATE X_2:
+    0, -1i
+    1i, 0
+H 0
+H 2
+CNOT 0 1
+DAGGER S 2
+CPHASE(-pi/2) 1 2
+CONTROLLED RX(0.5) 2 0
+X 1
+CCNOT 0 1 2
+X 1
+CONTROLLED CONTROLLED MCU 0 1 2
+My_gate 1
+X_2 0
+MEASURE 0 ro[0]
+MEASURE 1 ro[1]
+JUMP-UNLESS @skip0 ro[0]
+JUMP-WHEN @skip0 ro[1]
+Z 2
+LABEL @skip0
+RESET 0
+`
+		if got != want {
+			t.Errorf("ToQuil() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			build func(qc *QuantumCircuit)
+			want  string
+		}{
+			{"Noise channel", func(qc *QuantumCircuit) { qc.Depolarizing(0.1, 0) }, "noise channel"},
+			{"Compiled circuit", func(qc *QuantumCircuit) {
+				inner := NewQuantumCircuit(2)
+				inner.H([]int{0})
+				addCompiled(qc, inner)
+			}, "whole register"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				qc := NewQuantumCircuit(2)
+				tt.build(&qc)
+				if _, err := qc.ToQuil(); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("ToQuil() error = %v, want one containing %q", err, tt.want)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,572 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"math/rand"
+	"strings"
+)
+
+// Stabilizer state of n qubits as an Aaronson-Gottesman tableau: n destabilizer rows,
+// then n stabilizer rows, then one scratch row. Each row is a Pauli product with bits
+// x and z per qubit, packed 64 to a word, and a sign bit r.
+// Clifford gates update it in O(n) time and measurements in O(n^2), so circuits of
+// thousands of qubits can be simulated.
+type tableau struct {
+	numQubits int
+	words     int
+	x         []uint64
+	z         []uint64
+	r         []bool
+}
+
+// Creates the tableau of |0...0>, stabilized by each Z
+func newTableau(numQubits int) *tableau {
+	words := (numQubits + 63) / 64
+	rows := 2*numQubits + 1
+	t := &tableau{
+		numQubits: numQubits,
+		words:     words,
+		x:         make([]uint64, rows*words),
+		z:         make([]uint64, rows*words),
+		r:         make([]bool, rows),
+	}
+	for q := 0; q < numQubits; q++ {
+		t.setX(q, q, true)
+		t.setZ(numQubits+q, q, true)
+	}
+	return t
+}
+
+func (t *tableau) copy() *tableau {
+	return &tableau{
+		numQubits: t.numQubits,
+		words:     t.words,
+		x:         append([]uint64{}, t.x...),
+		z:         append([]uint64{}, t.z...),
+		r:         append([]bool{}, t.r...),
+	}
+}
+
+func (t *tableau) rows() int {
+	return 2*t.numQubits + 1
+}
+
+func (t *tableau) getX(row, q int) bool {
+	return t.x[row*t.words+q/64]&(1<<uint(q%64)) != 0
+}
+
+func (t *tableau) getZ(row, q int) bool {
+	return t.z[row*t.words+q/64]&(1<<uint(q%64)) != 0
+}
+
+func (t *tableau) setX(row, q int, v bool) {
+	if v {
+		t.x[row*t.words+q/64] |= 1 << uint(q%64)
+	} else {
+		t.x[row*t.words+q/64] &^= 1 << uint(q%64)
+	}
+}
+
+func (t *tableau) setZ(row, q int, v bool) {
+	if v {
+		t.z[row*t.words+q/64] |= 1 << uint(q%64)
+	} else {
+		t.z[row*t.words+q/64] &^= 1 << uint(q%64)
+	}
+}
+
+func (t *tableau) h(q int) {
+	for row := 0; row < t.rows(); row++ {
+		x, z := t.getX(row, q), t.getZ(row, q)
+		t.r[row] = t.r[row] != (x && z)
+		t.setX(row, q, z)
+		t.setZ(row, q, x)
+	}
+}
+
+func (t *tableau) s(q int) {
+	for row := 0; row < t.rows(); row++ {
+		x, z := t.getX(row, q), t.getZ(row, q)
+		t.r[row] = t.r[row] != (x && z)
+		t.setZ(row, q, z != x)
+	}
+}
+
+func (t *tableau) sdg(q int) {
+	t.z1(q)
+	t.s(q)
+}
+
+// Pauli X flips the sign of every row anticommuting with it, those with Z on q
+func (t *tableau) x1(q int) {
+	for row := 0; row < t.rows(); row++ {
+		t.r[row] = t.r[row] != t.getZ(row, q)
+	}
+}
+
+func (t *tableau) y1(q int) {
+	for row := 0; row < t.rows(); row++ {
+		t.r[row] = t.r[row] != (t.getX(row, q) != t.getZ(row, q))
+	}
+}
+
+func (t *tableau) z1(q int) {
+	for row := 0; row < t.rows(); row++ {
+		t.r[row] = t.r[row] != t.getX(row, q)
+	}
+}
+
+func (t *tableau) cx(control, target int) {
+	for row := 0; row < t.rows(); row++ {
+		xc, zc := t.getX(row, control), t.getZ(row, control)
+		xt, zt := t.getX(row, target), t.getZ(row, target)
+		t.r[row] = t.r[row] != (xc && zt && (xt == zc))
+		t.setX(row, target, xt != xc)
+		t.setZ(row, control, zc != zt)
+	}
+}
+
+func (t *tableau) cz(a, b int) {
+	t.h(b)
+	t.cx(a, b)
+	t.h(b)
+}
+
+func (t *tableau) cy(control, target int) {
+	t.sdg(target)
+	t.cx(control, target)
+	t.s(target)
+}
+
+func (t *tableau) swap(a, b int) {
+	t.cx(a, b)
+	t.cx(b, a)
+	t.cx(a, b)
+}
+
+// Multiplies row h by row i, tracking the sign of the product
+func (t *tableau) rowsum(h, i int) {
+	// Each qubit contributes a power of i to the product, counted as +1 or -1
+	pos, neg := 0, 0
+	for w := 0; w < t.words; w++ {
+		x1, z1 := t.x[i*t.words+w], t.z[i*t.words+w]
+		x2, z2 := t.x[h*t.words+w], t.z[h*t.words+w]
+
+		y := x1 & z1
+		xOnly := x1 &^ z1
+		zOnly := z1 &^ x1
+		pos += bits.OnesCount64(y&z2&^x2 | xOnly&z2&x2 | zOnly&x2&^z2)
+		neg += bits.OnesCount64(y&x2&^z2 | xOnly&z2&^x2 | zOnly&x2&z2)
+
+		t.x[h*t.words+w] = x1 ^ x2
+		t.z[h*t.words+w] = z1 ^ z2
+	}
+
+	phase := pos - neg
+	if t.r[h] {
+		phase += 2
+	}
+	if t.r[i] {
+		phase += 2
+	}
+	t.r[h] = ((phase%4)+4)%4 == 2
+}
+
+func (t *tableau) copyRow(dst, src int) {
+	copy(t.x[dst*t.words:(dst+1)*t.words], t.x[src*t.words:(src+1)*t.words])
+	copy(t.z[dst*t.words:(dst+1)*t.words], t.z[src*t.words:(src+1)*t.words])
+	t.r[dst] = t.r[src]
+}
+
+func (t *tableau) clearRow(row int) {
+	for w := 0; w < t.words; w++ {
+		t.x[row*t.words+w] = 0
+		t.z[row*t.words+w] = 0
+	}
+	t.r[row] = false
+}
+
+// Stabilizer row anticommuting with Z on the qubit, or -1 if its measurement is determined
+func (t *tableau) randomPivot(q int) int {
+	for row := t.numQubits; row < 2*t.numQubits; row++ {
+		if t.getX(row, q) {
+			return row
+		}
+	}
+	return -1
+}
+
+// Outcome of measuring a qubit whose outcome is determined, found in the scratch row
+func (t *tableau) determinedOutcome(q int) int {
+	scratch := 2 * t.numQubits
+	t.clearRow(scratch)
+	for row := 0; row < t.numQubits; row++ {
+		if t.getX(row, q) {
+			t.rowsum(scratch, row+t.numQubits)
+		}
+	}
+	if t.r[scratch] {
+		return 1
+	}
+	return 0
+}
+
+// Measures the qubit in the standard basis, collapsing the state.
+// u is a uniform random number in [0, 1) deciding the outcome if it is random.
+func (t *tableau) measure(q int, u float64) int {
+	p := t.randomPivot(q)
+	if p < 0 {
+		return t.determinedOutcome(q)
+	}
+
+	for row := 0; row < 2*t.numQubits; row++ {
+		if row != p && t.getX(row, q) {
+			t.rowsum(row, p)
+		}
+	}
+	t.copyRow(p-t.numQubits, p)
+	t.clearRow(p)
+	t.setZ(p, q, true)
+
+	outcome := 0
+	if u < 0.5 {
+		outcome = 1
+	}
+	t.r[p] = outcome == 1
+	return outcome
+}
+
+func (t *tableau) reset(q int, u float64) {
+	if t.measure(q, u) == 1 {
+		t.x1(q)
+	}
+}
+
+// Applies a Clifford gate, a measurement-free Pauli channel or returns an error
+// if the gate cannot be simulated on a tableau.
+// u is a uniform random number in [0, 1) picking the Pauli error of a channel.
+func (t *tableau) apply(g *Gate, u float64) error {
+	if err := checkClifford(g); err != nil {
+		return err
+	}
+
+	if g.kraus != nil {
+		p := g.params[0]
+		q := g.targets[0]
+		switch g.name {
+		case BITFLIP:
+			if u < p {
+				t.x1(q)
+			}
+		case PHASEFLIP:
+			if u < p {
+				t.z1(q)
+			}
+		case DEPOLARIZING:
+			switch {
+			case u < p/4:
+				t.x1(q)
+			case u < p/2:
+				t.y1(q)
+			case u < 3*p/4:
+				t.z1(q)
+			}
+		}
+		return nil
+	}
+
+	// Negative controls are turned into controls by flipping them around the gate
+	for _, c := range g.negControls {
+		t.x1(c)
+	}
+	defer func() {
+		for _, c := range g.negControls {
+			t.x1(c)
+		}
+	}()
+	controls := append(append([]int{}, g.controls...), g.negControls...)
+
+	switch g.name {
+	case HADAMARD, PAULIX, PAULIY, PAULIZ, SGATE, SDAGGER:
+		for _, q := range g.targets {
+			switch g.name {
+			case HADAMARD:
+				t.h(q)
+			case PAULIX:
+				t.x1(q)
+			case PAULIY:
+				t.y1(q)
+			case PAULIZ:
+				t.z1(q)
+			case SGATE:
+				t.s(q)
+			case SDAGGER:
+				t.sdg(q)
+			}
+		}
+	case CX, MCX:
+		if len(controls) == 0 {
+			t.x1(g.targets[0])
+		} else {
+			t.cx(controls[0], g.targets[0])
+		}
+	case CZ:
+		t.cz(controls[0], g.targets[0])
+	case CY:
+		t.cy(controls[0], g.targets[0])
+	case SWAP:
+		t.swap(g.targets[0], g.targets[1])
+	case ISWAP:
+		// iSWAP = SWAP CZ (S ⊗ S)
+		t.s(g.targets[0])
+		t.s(g.targets[1])
+		t.cz(g.targets[0], g.targets[1])
+		t.swap(g.targets[0], g.targets[1])
+	}
+	return nil
+}
+
+// Returns an error unless the gate can be simulated on a stabilizer tableau
+func checkClifford(g *Gate) error {
+	numControls := len(g.controls) + len(g.negControls)
+	switch g.name {
+	case WIRE, MEASURE, RESET:
+		return nil
+	case HADAMARD, PAULIX, PAULIY, PAULIZ, SGATE, SDAGGER, SWAP, ISWAP:
+		if numControls == 0 && g.targets != nil {
+			return nil
+		}
+	case CX, CZ, CY:
+		if numControls == 1 {
+			return nil
+		}
+	case MCX:
+		if numControls <= 1 {
+			return nil
+		}
+	case BITFLIP, PHASEFLIP, DEPOLARIZING:
+		return nil
+	}
+	return fmt.Errorf("%v is not a Clifford operation", g.Name())
+}
+
+// Tableau of the Clifford operator of a circuit of unitary gates: starting from the
+// tableau of |0...0>, row q holds the image of X on qubit q and row n+q that of Z, which
+// fix the operator up to global phase. Returns an error if a gate is not Clifford.
+func (qc *QuantumCircuit) cliffordTableau() (*tableau, error) {
+	t := newTableau(qc.numQubits)
+	for i := range qc.gates {
+		if err := t.apply(&qc.gates[i], 0); err != nil {
+			return nil, fmt.Errorf("gate %v: %v", i, err)
+		}
+	}
+	return t, nil
+}
+
+// Do the tableaux have the same destabilizer and stabilizer rows, signs included?
+// The scratch row is ignored.
+func (t *tableau) equals(o *tableau) bool {
+	if t.numQubits != o.numQubits {
+		return false
+	}
+	n := 2 * t.numQubits
+	for i := 0; i < n*t.words; i++ {
+		if t.x[i] != o.x[i] || t.z[i] != o.z[i] {
+			return false
+		}
+	}
+	for row := 0; row < n; row++ {
+		if t.r[row] != o.r[row] {
+			return false
+		}
+	}
+	return true
+}
+
+// Is every gate of the circuit a Clifford gate, measurement, reset or Pauli channel,
+// so that it can be executed with ExecStabilizer?
+func (qc *QuantumCircuit) IsClifford() bool {
+	for i := range qc.gates {
+		if checkClifford(&qc.gates[i]) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Stabilizer states a qubit may start in, and the gates preparing them from |0>
+var stabilizerInputs = []struct {
+	ket     Ket
+	prepare func(t *tableau, q int)
+}{
+	{ZeroKet, func(t *tableau, q int) {}},
+	{OneKet, func(t *tableau, q int) { t.x1(q) }},
+	{HPlusKet, func(t *tableau, q int) { t.h(q) }},
+	{HMinusKet, func(t *tableau, q int) { t.x1(q); t.h(q) }},
+	{NewKet(Matrix{Data: []complex128{1 / math.Sqrt2, 1i / math.Sqrt2}}), func(t *tableau, q int) { t.h(q); t.s(q) }},
+	{NewKet(Matrix{Data: []complex128{1 / math.Sqrt2, -1i / math.Sqrt2}}), func(t *tableau, q int) { t.h(q); t.sdg(q) }},
+}
+
+type StabilizerExecution struct {
+	tab    *tableau
+	clbits []int
+	noise  *NoiseModel
+}
+
+// Executes the circuit on a stabilizer tableau instead of a state vector, which takes
+// polynomial rather than exponential time and memory in the number of qubits.
+// Every gate must be a Clifford gate (H, X, Y, Z, S, Sdg, CX, CZ, CY, SWAP, ISWAP,
+// or MCX with at most one control),
+// measurement, reset or Pauli channel (BitFlip, PhaseFlip, Depolarizing), possibly
+// conditioned on classical bits, and every qubit must start in |0>, |1>, |+>, |->,
+// |+i> or |-i>; otherwise an error is returned.
+// The noise model of WithNoise is applied as with Exec, and WithDensityMatrix is ignored.
+func (qc *QuantumCircuit) ExecStabilizer(qubitStates []Ket, opts ...ExecOption) (*StabilizerExecution, error) {
+	if len(qubitStates) != qc.numQubits {
+		return nil, fmt.Errorf("cannot execute qubitStates of size %v on circuit with %v qubits", len(qubitStates), qc.numQubits)
+	}
+
+	cfg := &execConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	for i := range qc.gates {
+		g := &qc.gates[i]
+		if err := checkClifford(g); err != nil {
+			return nil, fmt.Errorf("gate %v: %v", i, err)
+		}
+		if cfg.noise != nil {
+			for _, e := range cfg.noise.errorsAfter(g, qc.numQubits) {
+				if err := checkClifford(&e); err != nil {
+					return nil, fmt.Errorf("noise after gate %v: %v", i, err)
+				}
+			}
+		}
+	}
+
+	t := newTableau(qc.numQubits)
+	for q, ket := range qubitStates {
+		prepared := false
+		for _, in := range stabilizerInputs {
+			overlap := Matrix(in.ket).Dagger().Mul(Matrix(ket)).Data[0]
+			if math.Abs(cmplx.Abs(overlap)-1) < unitaryTolerance {
+				in.prepare(t, q)
+				prepared = true
+				break
+			}
+		}
+		if !prepared {
+			return nil, fmt.Errorf("qubit %v starts in %v, which is not a stabilizer state", q, ket.Data)
+		}
+	}
+
+	clbits := make([]int, qc.numClbits)
+	for i := range qc.gates {
+		g := &qc.gates[i]
+		if g.cond != nil && !g.cond.holds(clbits) {
+			continue
+		}
+
+		switch g.name {
+		case MEASURE:
+			outcome := t.measure(g.targets[0], cfg.float64())
+			if cfg.noise != nil {
+				outcome = cfg.noise.read(g.targets[0], outcome, cfg.float64())
+			}
+			clbits[g.clbits[0]] = outcome
+		case RESET:
+			t.reset(g.targets[0], cfg.float64())
+		default:
+			if err := t.apply(g, cfg.float64()); err != nil {
+				return nil, err
+			}
+		}
+
+		if cfg.noise != nil {
+			for _, e := range cfg.noise.errorsAfter(g, qc.numQubits) {
+				if err := t.apply(&e, cfg.float64()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &StabilizerExecution{tab: t, clbits: clbits, noise: cfg.noise}, nil
+}
+
+// Number of qubits in the executed circuit
+func (se *StabilizerExecution) NumQubits() int {
+	return se.tab.numQubits
+}
+
+// Values of the classical bits after execution, each 0 or 1
+func (se *StabilizerExecution) Clbits() []int {
+	return se.clbits
+}
+
+// Probability that measuring the qubit in the standard basis will return ON,
+// which for a stabilizer state is always 0, 1/2 or 1
+func (se *StabilizerExecution) MeasureProbabilityOn(qubit int) float64 {
+	if se.tab.randomPivot(qubit) >= 0 {
+		return 0.5
+	}
+	return float64(se.tab.copy().determinedOutcome(qubit))
+}
+
+// Generators of the stabilizer group of the output state, such as "+XX" and "-ZZ",
+// with qubit 0 first
+func (se *StabilizerExecution) Stabilizers() []string {
+	t := se.tab
+	out := make([]string, t.numQubits)
+	for i := range out {
+		row := t.numQubits + i
+		var sb strings.Builder
+		if t.r[row] {
+			sb.WriteByte('-')
+		} else {
+			sb.WriteByte('+')
+		}
+		for q := 0; q < t.numQubits; q++ {
+			switch x, z := t.getX(row, q), t.getZ(row, q); {
+			case x && z:
+				sb.WriteByte('Y')
+			case x:
+				sb.WriteByte('X')
+			case z:
+				sb.WriteByte('Z')
+			default:
+				sb.WriteByte('I')
+			}
+		}
+		out[i] = sb.String()
+	}
+	return out
+}
+
+// Draws shots measurements of every qubit in the standard basis from the output state.
+// Returns how many times each outcome was seen, keyed by bitstring with qubit 0 first.
+// Results are reproducible for a given seed of rng; a nil rng uses the global source.
+// Readout errors of the noise model the circuit was executed with corrupt each shot.
+func (se *StabilizerExecution) Sample(shots int, rng *rand.Rand) map[string]int {
+	draw := (&execConfig{rng: rng}).float64
+	numQubits := se.tab.numQubits
+
+	counts := map[string]int{}
+	outcome := make([]byte, numQubits)
+	for s := 0; s < shots; s++ {
+		t := se.tab.copy()
+		for q := 0; q < numQubits; q++ {
+			bit := t.measure(q, draw())
+			if se.noise != nil {
+				bit = se.noise.read(q, bit, draw())
+			}
+			outcome[q] = byte('0' + bit)
+		}
+		counts[string(outcome)]++
+	}
+	return counts
+}
@@ -0,0 +1,235 @@
+package sim
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Expectation <psi|P|psi> of a signed Pauli string such as "-XZ" on a state vector
+func pauliExpectation(psi ColVec, pauli string) complex128 {
+	p := Identity(1)
+	for _, c := range pauli[1:] {
+		p = *p.Kronecker(map[rune]Matrix{'I': I, 'X': X, 'Y': Y, 'Z': Z}[c])
+	}
+	e := Matrix(psi).Dagger().Mul(*p.Mul(Matrix(psi))).Data[0]
+	if pauli[0] == '-' {
+		return -e
+	}
+	return e
+}
+
+func TestQuantumCircuit_ExecStabilizer(t *testing.T) {
+	t.Run("Bell state", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.CX(0, 1)
+
+		se, err := qc.ExecStabilizer([]Ket{ZeroKet, ZeroKet})
+		if err != nil {
+			t.Fatalf("ExecStabilizer() error = %v", err)
+		}
+		if got := strings.Join(se.Stabilizers(), ","); got != "+XX,+ZZ" {
+			t.Errorf("Stabilizers() = %v, want +XX,+ZZ", got)
+		}
+		if got := se.MeasureProbabilityOn(1); got != 0.5 {
+			t.Errorf("MeasureProbabilityOn(1) = %v, want 0.5", got)
+		}
+	})
+
+	t.Run("Matches the state vector on random Clifford circuits", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(8))
+		numQubits := 4
+		inputs := []Ket{ZeroKet, OneKet, HPlusKet, HMinusKet}
+		for trial := 0; trial < 30; trial++ {
+			qc := NewQuantumCircuit(numQubits)
+			for i := 0; i < 25; i++ {
+				a := rng.Intn(numQubits)
+				b := (a + 1 + rng.Intn(numQubits-1)) % numQubits
+				switch rng.Intn(12) {
+				case 0:
+					qc.H([]int{a})
+				case 1:
+					qc.S(a)
+				case 2:
+					qc.Sdg(a)
+				case 3:
+					qc.X(a)
+				case 4:
+					qc.Y(a)
+				case 5:
+					qc.Z(a)
+				case 6:
+					qc.CX(a, b)
+				case 7:
+					qc.CZ(a, b)
+				case 8:
+					qc.CY(a, b)
+				case 9:
+					qc.SWAP(a, b)
+				case 10:
+					qc.ISWAP(a, b)
+				case 11:
+					qc.MCX([]int{NegControl(a)}, b)
+				}
+			}
+			in := make([]Ket, numQubits)
+			for q := range in {
+				in[q] = inputs[rng.Intn(len(inputs))]
+			}
+
+			se, err := qc.ExecStabilizer(in)
+			if err != nil {
+				t.Fatalf("ExecStabilizer() error = %v", err)
+			}
+			psi := qc.Exec(in).out
+			for _, s := range se.Stabilizers() {
+				if e := pauliExpectation(psi, s); real(e) < 1-FloatEpsilon {
+					t.Fatalf("<psi|%v|psi> = %v, want 1", s, e)
+				}
+			}
+			for q := 0; q < numQubits; q++ {
+				if got, want := se.MeasureProbabilityOn(q), qc.Exec(in).MeasureProbabilityOn(q); !floatEqual(got, want, FloatEpsilon) {
+					t.Fatalf("MeasureProbabilityOn(%v) = %v, want %v", q, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("Large GHZ state", func(t *testing.T) {
+		numQubits := 1000
+		qc := NewQuantumCircuit(numQubits)
+		in := make([]Ket, numQubits)
+		for q := range in {
+			in[q] = ZeroKet
+		}
+		qc.H([]int{0})
+		for q := 1; q < numQubits; q++ {
+			qc.CX(q-1, q)
+		}
+
+		se, err := qc.ExecStabilizer(in)
+		if err != nil {
+			t.Fatalf("ExecStabilizer() error = %v", err)
+		}
+		counts := se.Sample(20, rand.New(rand.NewSource(1)))
+		zeros, ones := strings.Repeat("0", numQubits), strings.Repeat("1", numQubits)
+		if counts[zeros]+counts[ones] != 20 || counts[zeros] == 0 || counts[ones] == 0 {
+			t.Errorf("Sample() has %v all-zero and %v all-one outcomes of 20", counts[zeros], counts[ones])
+		}
+	})
+
+	t.Run("Measurement drives conditions", func(t *testing.T) {
+		// Measuring a parity qubit and correcting with a conditioned X always leaves it |0>
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0})
+		qc.CX(0, 2)
+		qc.CX(1, 2)
+		qc.Measure(2, 0)
+		qc.If(0, 1).X(2)
+		qc.Measure(2, 1)
+
+		rng := rand.New(rand.NewSource(3))
+		for shot := 0; shot < 20; shot++ {
+			se, err := qc.ExecStabilizer([]Ket{ZeroKet, OneKet, ZeroKet}, WithRand(rng))
+			if err != nil {
+				t.Fatalf("ExecStabilizer() error = %v", err)
+			}
+			if got := se.Clbits()[1]; got != 0 {
+				t.Fatalf("Clbits() = %v, want the corrected qubit to read 0", se.Clbits())
+			}
+		}
+	})
+
+	t.Run("Pauli noise", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.BitFlip(1, 0)
+		nm := NewNoiseModel()
+		if err := nm.AddGateError(BITFLIP, PhaseFlipChannel(1)); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+
+		se, err := qc.ExecStabilizer([]Ket{ZeroKet, ZeroKet}, WithNoise(nm))
+		if err != nil {
+			t.Fatalf("ExecStabilizer() error = %v", err)
+		}
+		if got := strings.Join(se.Stabilizers(), ","); got != "-ZI,+IZ" {
+			t.Errorf("Stabilizers() = %v, want -ZI,+IZ", got)
+		}
+	})
+
+	t.Run("Rejects non-Clifford circuits", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.T(0)
+		if qc.IsClifford() {
+			t.Errorf("IsClifford() = true with a T gate")
+		}
+		if _, err := qc.ExecStabilizer([]Ket{ZeroKet, ZeroKet}); err == nil {
+			t.Errorf("ExecStabilizer() with a T gate should fail")
+		}
+
+		cliff := NewQuantumCircuit(1)
+		cliff.H([]int{0})
+		if !cliff.IsClifford() {
+			t.Errorf("IsClifford() = false with only H")
+		}
+		if _, err := cliff.ExecStabilizer([]Ket{NewKet(Matrix{Data: []complex128{0.6, 0.8}})}); err == nil {
+			t.Errorf("ExecStabilizer() with a non-stabilizer input should fail")
+		}
+	})
+}
+
+func TestQuantumCircuit_Exec_Stabilizer(t *testing.T) {
+	numQubits := 1000
+	in := make([]Ket, numQubits)
+	for q := range in {
+		in[q] = ZeroKet
+	}
+
+	t.Run("Large Clifford circuit runs on a tableau", func(t *testing.T) {
+		qc := NewQuantumCircuit(numQubits)
+		qc.H([]int{0})
+		for q := 1; q < numQubits; q++ {
+			qc.CX(q-1, q)
+		}
+		qc.Measure(numQubits-1, 0)
+		qc.If(0, 1).X(0)
+
+		qce := qc.Exec(in, WithRand(rand.New(rand.NewSource(2))))
+		if got := qce.MeasureProbabilityOn(0); got != 0 {
+			t.Errorf("MeasureProbabilityOn(0) = %v, want 0 after the conditioned X", got)
+		}
+		want := strings.Repeat("0", numQubits)
+		if qce.Clbits()[0] == 1 {
+			want = "0" + strings.Repeat("1", numQubits-1)
+		}
+		if counts := qce.Sample(5, rand.New(rand.NewSource(1))); counts[want] != 5 {
+			t.Errorf("Sample() = %v outcomes of %v, want 5", counts[want], want)
+		}
+	})
+
+	panics := []struct {
+		name  string
+		build func(qc *QuantumCircuit)
+		run   func(qc *QuantumCircuit)
+		want  string
+	}{
+		{"Large non-Clifford circuit", func(qc *QuantumCircuit) { qc.T(0) },
+			func(qc *QuantumCircuit) { qc.Exec(in) }, "ExecStabilizer rejects it"},
+		{"Amplitudes of a tableau", func(qc *QuantumCircuit) { qc.H([]int{0}) },
+			func(qc *QuantumCircuit) { qc.Exec(in).MeasureProbabilities() }, "stabilizer tableau"},
+	}
+	for _, tt := range panics {
+		t.Run("Panic on "+tt.name, func(t *testing.T) {
+			qc := NewQuantumCircuit(numQubits)
+			tt.build(&qc)
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(r.(string), tt.want) {
+					t.Errorf("panic = %v, want one containing %q", r, tt.want)
+				}
+			}()
+			tt.run(&qc)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Tolerance on the norm of a state vector given as input
+const normTolerance = 1e-8
+
+// Executes the circuit with the register starting in an arbitrary state of 2^n
+// amplitudes, such as an entangled state that cannot be given to Exec as a list of kets.
+// The state is not modified. Panics unless it has 2^n amplitudes for the n qubits of
+// the circuit and is normalized, and on circuits of more than 30 qubits (15 with
+// WithDensityMatrix), which have no tableau fallback here as they do in Exec.
+func (qc *QuantumCircuit) ExecState(state ColVec, opts ...ExecOption) *QuantumCircuitExecution {
+	cfg := &execConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if qc.numQubits > cfg.maxQubits() {
+		panic(fmt.Sprintf("Cannot execute circuit of %v qubits as a state vector, the limit is %v",
+			qc.numQubits, cfg.maxQubits()))
+	}
+
+	if len(state.Data) != 1<<uint(qc.numQubits) {
+		panic(fmt.Sprintf("Cannot execute a state of %v amplitudes on circuit with %v qubits", len(state.Data), qc.numQubits))
+	}
+	if norm := stateNorm(state.Data); math.Abs(norm-1) > normTolerance {
+		panic(fmt.Sprintf("Cannot execute a state of norm %v", norm))
+	}
+
+	input := NewColVec(Matrix{Rows: len(state.Data), Cols: 1, Stride: 1, Data: append([]complex128{}, state.Data...)})
+	return qc.exec(nil, input, cfg)
+}
+
+func stateNorm(amps []complex128) float64 {
+	sum := 0.0
+	for _, amp := range amps {
+		sum += real(amp)*real(amp) + imag(amp)*imag(amp)
+	}
+	return math.Sqrt(sum)
+}
+
+// Creates the basis state given as a bitstring of 0s and 1s with qubit 0 first,
+// so "01" is |0>|1>
+func StateFromBitstring(bits string) (ColVec, error) {
+	if len(bits) == 0 {
+		return ColVec{}, fmt.Errorf("bitstring is empty")
+	}
+
+	index := 0
+	for i, b := range bits {
+		if b != '0' && b != '1' {
+			return ColVec{}, fmt.Errorf("bitstring %q has %q at position %v, want 0 or 1", bits, b, i)
+		}
+		index = index<<1 | int(b-'0')
+	}
+
+	dim := 1 << uint(len(bits))
+	data := make([]complex128, dim)
+	data[index] = 1
+	return NewColVec(Matrix{Rows: dim, Cols: 1, Stride: 1, Data: data}), nil
+}
+
+// Creates a state from its 2^n amplitudes, indexed with qubit 0 as the most significant bit.
+// Returns an error unless there are a power of two of them and they are normalized.
+func StateFromAmplitudes(amps []complex128) (ColVec, error) {
+	if len(amps) < 2 || len(amps)&(len(amps)-1) != 0 {
+		return ColVec{}, fmt.Errorf("state has %v amplitudes, not a power of two", len(amps))
+	}
+	if norm := stateNorm(amps); math.Abs(norm-1) > normTolerance {
+		return ColVec{}, fmt.Errorf("state has norm %v, not 1", norm)
+	}
+	return NewColVec(Matrix{Rows: len(amps), Cols: 1, Stride: 1, Data: append([]complex128{}, amps...)}), nil
+}
+
+// Draws a state of numQubits qubits uniformly at random from the Haar measure, by
+// normalizing a vector of independent complex Gaussian amplitudes.
+// Results are reproducible for a given seed of rng; a nil rng uses the global source.
+func RandomState(numQubits int, rng *rand.Rand) ColVec {
+	normal := rand.NormFloat64
+	if rng != nil {
+		normal = rng.NormFloat64
+	}
+
+	dim := 1 << uint(numQubits)
+	data := make([]complex128, dim)
+	for i := range data {
+		data[i] = complex(normal(), normal())
+	}
+	scale := complex(1/stateNorm(data), 0)
+	for i := range data {
+		data[i] *= scale
+	}
+	return NewColVec(Matrix{Rows: dim, Cols: 1, Stride: 1, Data: data})
+}
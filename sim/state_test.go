@@ -0,0 +1,143 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_ExecState(t *testing.T) {
+	t.Run("Matches Exec on product states", func(t *testing.T) {
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{1})
+		qc.CX(1, 0)
+		qc.RY(0.8, 2)
+
+		in := []Ket{ZeroKet, HMinusKet, OneKet}
+		want := qc.Exec(in).MeasureProbabilities()
+		if got := qc.ExecState(KronKets(in)).MeasureProbabilities(); !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Undoing a Bell pair", func(t *testing.T) {
+		bell, err := StateFromAmplitudes([]complex128{1 / math.Sqrt2, 0, 0, 1 / math.Sqrt2})
+		if err != nil {
+			t.Fatalf("StateFromAmplitudes() error = %v", err)
+		}
+		qc := NewQuantumCircuit(2)
+		qc.CX(0, 1)
+		qc.H([]int{0})
+
+		got := qc.ExecState(bell, WithDensityMatrix()).MeasureProbabilities()
+		if want := []float64{1, 0, 0, 0}; !floatsEqual(got, want, FloatEpsilon) {
+			t.Errorf("MeasureProbabilities() = %v, want %v", got, want)
+		}
+		if bell.Data[3] != 1/math.Sqrt2 {
+			t.Errorf("ExecState() modified its input to %v", bell)
+		}
+	})
+
+	t.Run("Panics on bad states", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		for _, state := range []ColVec{KronKets([]Ket{ZeroKet}), KronKets([]Ket{ZeroKet, ZeroKet, ZeroKet})} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("ExecState() of %v amplitudes did not panic", len(state.Data))
+					}
+				}()
+				qc.ExecState(state)
+			}()
+		}
+	})
+
+	t.Run("Panics above the density matrix limit", func(t *testing.T) {
+		qc := NewQuantumCircuit(maxStatevectorQubits/2 + 1)
+		defer func() {
+			r := recover()
+			if msg, ok := r.(string); !ok || !strings.Contains(msg, fmt.Sprintf("limit is %v", maxStatevectorQubits/2)) {
+				t.Errorf("ExecState() with WithDensityMatrix panics with %v, want the qubit limit", r)
+			}
+		}()
+		qc.ExecState(ColVec{}, WithDensityMatrix())
+	})
+}
+
+func TestStateFromBitstring(t *testing.T) {
+	tests := []struct {
+		bits    string
+		want    int
+		wantErr bool
+	}{
+		{bits: "0", want: 0},
+		{bits: "01", want: 1},
+		{bits: "0101", want: 5},
+		{bits: "110", want: 6},
+		{bits: "", wantErr: true},
+		{bits: "012", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bits, func(t *testing.T) {
+			got, err := StateFromBitstring(tt.bits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StateFromBitstring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.Data) != 1<<uint(len(tt.bits)) || got.Data[tt.want] != 1 || stateNorm(got.Data) != 1 {
+				t.Errorf("StateFromBitstring() = %v, want basis state %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateFromAmplitudes(t *testing.T) {
+	tests := []struct {
+		name    string
+		amps    []complex128
+		wantErr bool
+	}{
+		{name: "Plus", amps: []complex128{1 / math.Sqrt2, 1 / math.Sqrt2}},
+		{name: "Complex", amps: []complex128{0.5, 0.5i, -0.5, 0.5}},
+		{name: "Not normalized", amps: []complex128{1, 1}, wantErr: true},
+		{name: "Not a power of two", amps: []complex128{1, 0, 0}, wantErr: true},
+		{name: "Single amplitude", amps: []complex128{1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StateFromAmplitudes(tt.amps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StateFromAmplitudes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Data[1] != tt.amps[1] {
+				t.Errorf("StateFromAmplitudes() = %v, want %v", got, tt.amps)
+			}
+		})
+	}
+}
+
+func TestRandomState(t *testing.T) {
+	a := RandomState(4, rand.New(rand.NewSource(3)))
+	b := RandomState(4, rand.New(rand.NewSource(3)))
+	if len(a.Data) != 16 || !floatEqual(stateNorm(a.Data), 1, FloatEpsilon) {
+		t.Errorf("RandomState() = %v, want a normalized state of 16 amplitudes", a)
+	}
+	if !Matrix(a).Equals(Matrix(b), StdEpsilon) {
+		t.Errorf("RandomState() differs for the same seed")
+	}
+
+	// Haar random states of d amplitudes have E|<0|psi>|^2 = 1/d
+	rng := rand.New(rand.NewSource(4))
+	mean := 0.0
+	for i := 0; i < 2000; i++ {
+		amp := RandomState(2, rng).Data[0]
+		mean += (real(amp)*real(amp) + imag(amp)*imag(amp)) / 2000
+	}
+	if math.Abs(mean-0.25) > 0.02 {
+		t.Errorf("mean probability of |00> = %v, want 0.25", mean)
+	}
+}
@@ -0,0 +1,124 @@
+package sim
+
+import (
+	"math"
+)
+
+// Bit of a register index that holds the given qubit.
+// Qubit 0 is the most significant bit, matching the ordering used by KronKets.
+func qubitMask(qubit, numQubits int) int {
+	return 1 << uint(numQubits-1-qubit)
+}
+
+// Applies a gate in place to the state vector of a register of numQubits qubits.
+// Only the amplitudes the gate acts on are read and written, so memory stays at
+// the size of the register. Gates acting on the whole register fall back to
+// multiplying by their full matrix.
+func applyGate(state ColVec, numQubits int, g *Gate) {
+	if g.targets == nil {
+		copy(state.Data, g.Matrix.Mul(Matrix(state)).Data)
+		return
+	}
+
+	// A single-qubit operator with several targets is applied to each target in turn
+	if g.Rows == 2 && len(g.targets) > 1 {
+		for _, t := range g.targets {
+			applyKernel(state.Data, numQubits, g.Matrix, []int{t}, g.controls, g.negControls)
+		}
+		return
+	}
+
+	applyKernel(state.Data, numQubits, g.Matrix, g.targets, g.controls, g.negControls)
+}
+
+// Multiplies the amplitudes of the target qubits by the kernel, for every basis
+// state of the remaining qubits in which all the control qubits are ON and all
+// the negative control qubits are OFF.
+// The first target is the most significant qubit of the kernel.
+func applyKernel(amps []complex128, numQubits int, kernel Matrix, targets, controls, negControls []int) {
+	dim := 1 << uint(len(targets))
+
+	// Offset of each kernel basis state within a group of affected amplitudes
+	offsets := make([]int, dim)
+	for j := 0; j < dim; j++ {
+		for b, t := range targets {
+			if j&(1<<uint(len(targets)-1-b)) != 0 {
+				offsets[j] |= qubitMask(t, numQubits)
+			}
+		}
+	}
+	targetMask := offsets[dim-1]
+
+	// Controls must read controlValue under controlMask: ON for controls, OFF for negative controls
+	controlMask := 0
+	for _, c := range controls {
+		controlMask |= qubitMask(c, numQubits)
+	}
+	controlValue := controlMask
+	for _, c := range negControls {
+		controlMask |= qubitMask(c, numQubits)
+	}
+
+	in := make([]complex128, dim)
+	for base := 0; base < len(amps); base++ {
+		if base&targetMask != 0 || base&controlMask != controlValue {
+			continue
+		}
+
+		// Pairs for single-qubit kernels are common enough to skip the general loop
+		if dim == 2 {
+			a0, a1 := amps[base], amps[base|targetMask]
+			amps[base] = kernel.Data[0]*a0 + kernel.Data[1]*a1
+			amps[base|targetMask] = kernel.Data[kernel.Stride]*a0 + kernel.Data[kernel.Stride+1]*a1
+			continue
+		}
+
+		for j := range in {
+			in[j] = amps[base|offsets[j]]
+		}
+		for r := 0; r < dim; r++ {
+			var sum complex128
+			for c := 0; c < dim; c++ {
+				sum += kernel.Data[r*kernel.Stride+c] * in[c]
+			}
+			amps[base|offsets[r]] = sum
+		}
+	}
+}
+
+// Measures the qubit in the standard basis, collapsing the state vector in place.
+// u is a uniform random number in [0, 1) deciding the outcome, which is returned.
+func measureQubit(state ColVec, numQubits, qubit int, u float64) int {
+	mask := qubitMask(qubit, numQubits)
+
+	probOn := 0.0
+	for i, amp := range state.Data {
+		if i&mask != 0 {
+			probOn += real(amp)*real(amp) + imag(amp)*imag(amp)
+		}
+	}
+
+	outcome, prob := 0, 1-probOn
+	if u < probOn {
+		outcome, prob = 1, probOn
+	}
+
+	// Drop the amplitudes of the other outcome and renormalize the rest
+	norm := complex(1/math.Sqrt(prob), 0)
+	for i := range state.Data {
+		if (i&mask != 0) == (outcome == 1) {
+			state.Data[i] *= norm
+		} else {
+			state.Data[i] = 0
+		}
+	}
+
+	return outcome
+}
+
+// Resets the qubit to |0> by measuring it and flipping it if it read ON
+func resetQubit(state ColVec, numQubits, qubit int, u float64) {
+	if measureQubit(state, numQubits, qubit, u) == 1 {
+		applyKernel(state.Data, numQubits, X, []int{qubit}, nil, nil)
+	}
+}
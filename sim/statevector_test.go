@@ -0,0 +1,136 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyGate(t *testing.T) {
+	tests := []struct {
+		name      string
+		numQubits int
+		gates     []Gate
+		register  []Ket
+	}{
+		{
+			name:      "Hadamard on every qubit",
+			numQubits: 3,
+			gates:     []Gate{*createH([]int{0, 1, 2})},
+			register:  []Ket{OneKet, HPlusKet, HMinusKet},
+		},
+		{
+			name:      "X on a middle qubit",
+			numQubits: 3,
+			gates:     []Gate{*createX(1)},
+			register:  []Ket{ZeroKet, HPlusKet, OneKet},
+		},
+		{
+			name:      "CX skipping over qubits",
+			numQubits: 4,
+			gates:     []Gate{*createH([]int{0}), *createCX(0, 3)},
+			register:  []Ket{ZeroKet, OneKet, ZeroKet, ZeroKet},
+		},
+		{
+			name:      "CX with control below target",
+			numQubits: 3,
+			gates:     []Gate{*createH([]int{2}), *createCX(2, 0)},
+			register:  []Ket{ZeroKet, ZeroKet, ZeroKet},
+		},
+		{
+			name:      "Gate acting on the whole register",
+			numQubits: 2,
+			gates:     []Gate{*createH([]int{0, 1}), *Combine(55, 2, *createCX(0, 1), *createH([]int{0}))},
+			register:  []Ket{OneKet, ZeroKet},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := &QuantumCircuit{
+				numQubits: tt.numQubits,
+				gates:     tt.gates,
+			}
+			qc.Compile()
+			want := NewColVec(*qc.compiled.Matrix.Mul(Matrix(KronKets(tt.register))))
+
+			got := KronKets(tt.register)
+			for i := range tt.gates {
+				applyGate(got, tt.numQubits, &tt.gates[i])
+			}
+
+			if !Matrix(got).Equals(Matrix(want), StdEpsilon) {
+				t.Errorf("applyGate() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestQuantumCircuit_ExecLarge(t *testing.T) {
+	// A 2^20 x 2^20 unitary would never fit in memory, but the state vector does
+	numQubits := 20
+	qc := NewQuantumCircuit(numQubits)
+	qubits := make([]int, numQubits)
+	register := make([]Ket, numQubits)
+	for i := range qubits {
+		qubits[i] = i
+		register[i] = ZeroKet
+	}
+	qc.H(qubits)
+	qc.CX(0, numQubits-1)
+
+	out := qc.Exec(register)
+
+	want := 1 / math.Sqrt(math.Pow(2, float64(numQubits)))
+	for i, amp := range out.out.Data {
+		if !floatEqual(real(amp), want, FloatEpsilon) || !floatEqual(imag(amp), 0, FloatEpsilon) {
+			t.Fatalf("amplitude %v = %v, want %v", i, amp, want)
+		}
+	}
+}
+
+func TestMeasureQubit(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []Ket
+		qubit   int
+		u       float64
+		outcome int
+		want    []complex128
+	}{
+		{
+			name:    "Low draw on |+0> reads 1",
+			in:      []Ket{HPlusKet, ZeroKet},
+			qubit:   0,
+			u:       0.2,
+			outcome: 1,
+			want:    []complex128{0, 0, 1, 0},
+		},
+		{
+			name:    "High draw on |+0> reads 0",
+			in:      []Ket{HPlusKet, ZeroKet},
+			qubit:   0,
+			u:       0.7,
+			outcome: 0,
+			want:    []complex128{1, 0, 0, 0},
+		},
+		{
+			name:    "Certain outcome ignores the draw",
+			in:      []Ket{HMinusKet, OneKet},
+			qubit:   1,
+			u:       0.99,
+			outcome: 1,
+			want:    []complex128{0, 1 / math.Sqrt2, 0, -1 / math.Sqrt2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := KronKets(tt.in)
+			if got := measureQubit(state, len(tt.in), tt.qubit, tt.u); got != tt.outcome {
+				t.Errorf("measureQubit() = %v, want %v", got, tt.outcome)
+			}
+			want := NewColVec(Matrix{Rows: len(tt.want), Cols: 1, Stride: 1, Data: tt.want})
+			if !Matrix(state).Equals(Matrix(want), StdEpsilon) {
+				t.Errorf("state after measureQubit() = %v, want %v", state, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package sim
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// Thin singular value decomposition A = U diag(S) V† of a complex matrix, by one-sided
+// Jacobi rotations. For an m x n matrix with k = min(m, n), U is m x k and V is n x k,
+// both with orthonormal columns, and S holds the k singular values in decreasing order.
+// Columns belonging to zero singular values may be zero instead.
+func svd(a Matrix) (u Matrix, s []float64, v Matrix) {
+	if a.Cols > a.Rows {
+		// Orthogonalize the shorter dimension: A† = V S U†
+		v, s, u = svd(*a.Dagger())
+		return u, s, v
+	}
+
+	m, n := a.Rows, a.Cols
+	w := Matrix{Rows: m, Cols: n, Stride: n, Data: make([]complex128, m*n)}
+	for r := 0; r < m; r++ {
+		copy(w.Data[r*n:(r+1)*n], a.Data[r*a.Stride:r*a.Stride+n])
+	}
+	vAcc := Identity(n)
+
+	// Rotate pairs of columns of W = A V until every pair is orthogonal
+	const tolerance = 1e-15
+	for sweep := 0; sweep < 100; sweep++ {
+		rotated := false
+		for p := 0; p < n-1; p++ {
+			for q := p + 1; q < n; q++ {
+				var alpha, beta float64
+				var gamma complex128
+				for r := 0; r < m; r++ {
+					wp, wq := w.Data[r*n+p], w.Data[r*n+q]
+					alpha += real(wp)*real(wp) + imag(wp)*imag(wp)
+					beta += real(wq)*real(wq) + imag(wq)*imag(wq)
+					gamma += cmplx.Conj(wp) * wq
+				}
+				if cmplx.Abs(gamma) <= tolerance*math.Sqrt(alpha*beta) || cmplx.Abs(gamma) == 0 {
+					continue
+				}
+				rotated = true
+
+				// Phase making the overlap real, followed by a real rotation zeroing it
+				phase := cmplx.Conj(gamma) / complex(cmplx.Abs(gamma), 0)
+				zeta := (beta - alpha) / (2 * cmplx.Abs(gamma))
+				t := 1 / (math.Abs(zeta) + math.Sqrt(1+zeta*zeta))
+				if zeta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(1+t*t)
+				sn := c * t
+
+				rotate := func(mat Matrix, rows int) {
+					for r := 0; r < rows; r++ {
+						xp := mat.Data[r*mat.Stride+p]
+						xq := mat.Data[r*mat.Stride+q] * phase
+						mat.Data[r*mat.Stride+p] = complex(c, 0)*xp - complex(sn, 0)*xq
+						mat.Data[r*mat.Stride+q] = complex(sn, 0)*xp + complex(c, 0)*xq
+					}
+				}
+				rotate(w, m)
+				rotate(vAcc, n)
+			}
+		}
+		if !rotated {
+			break
+		}
+	}
+
+	// The singular values are the column norms of W, and U its normalized columns
+	norms := make([]float64, n)
+	order := make([]int, n)
+	for j := range norms {
+		for r := 0; r < m; r++ {
+			x := w.Data[r*n+j]
+			norms[j] += real(x)*real(x) + imag(x)*imag(x)
+		}
+		norms[j] = math.Sqrt(norms[j])
+		order[j] = j
+	}
+	sort.SliceStable(order, func(i, j int) bool { return norms[order[i]] > norms[order[j]] })
+
+	u = Matrix{Rows: m, Cols: n, Stride: n, Data: make([]complex128, m*n)}
+	v = Matrix{Rows: n, Cols: n, Stride: n, Data: make([]complex128, n*n)}
+	s = make([]float64, n)
+	for k, j := range order {
+		s[k] = norms[j]
+		for r := 0; r < n; r++ {
+			v.Data[r*n+k] = vAcc.Data[r*n+j]
+		}
+		if s[k] == 0 {
+			continue
+		}
+		for r := 0; r < m; r++ {
+			u.Data[r*n+k] = w.Data[r*n+j] / complex(s[k], 0)
+		}
+	}
+	return u, s, v
+}
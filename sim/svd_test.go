@@ -0,0 +1,54 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSVD(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	random := func(rows, cols int) Matrix {
+		m := Matrix{Rows: rows, Cols: cols, Stride: cols, Data: make([]complex128, rows*cols)}
+		for i := range m.Data {
+			m.Data[i] = complex(rng.NormFloat64(), rng.NormFloat64())
+		}
+		return m
+	}
+
+	tests := []struct {
+		name string
+		a    Matrix
+	}{
+		{name: "Square", a: random(4, 4)},
+		{name: "Tall", a: random(6, 3)},
+		{name: "Wide", a: random(2, 5)},
+		{name: "Rank one", a: *random(4, 1).Mul(random(1, 3))},
+		{name: "Zero", a: Matrix{Rows: 2, Cols: 2, Stride: 2, Data: make([]complex128, 4)}},
+		{name: "Swap", a: Swap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, s, v := svd(tt.a)
+			k := len(s)
+			if want := tt.a.Rows; tt.a.Cols < want {
+				want = tt.a.Cols
+				if k != want {
+					t.Fatalf("svd() returned %v singular values, want %v", k, want)
+				}
+			}
+			for i := 1; i < k; i++ {
+				if s[i] > s[i-1] || s[i] < 0 {
+					t.Fatalf("singular values %v are not decreasing and non-negative", s)
+				}
+			}
+
+			got := *scaleColumns(*u.Mul(Identity(k)), s).Mul(*v.Dagger())
+			if !got.Equals(tt.a, StdEpsilon) {
+				t.Errorf("U S V† = %v, want %v", got, tt.a)
+			}
+			if vv := *v.Dagger().Mul(v); !vv.Equals(Identity(k), StdEpsilon) && tt.a.Rows >= tt.a.Cols {
+				t.Errorf("V†V = %v, want identity", vv)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// Outcomes of running a circuit as many independent stochastic trajectories
+type TrajectoryResult struct {
+	shots       int
+	counts      map[string]int
+	clbitCounts map[string]int
+}
+
+// Number of trajectories that were run
+func (tr *TrajectoryResult) Shots() int {
+	return tr.shots
+}
+
+// How many trajectories ended with each outcome of measuring every qubit,
+// keyed by bitstring with qubit 0 first
+func (tr *TrajectoryResult) Counts() map[string]int {
+	return tr.counts
+}
+
+// How many trajectories ended with each value of the classical register,
+// keyed by bitstring with classical bit 0 first
+func (tr *TrajectoryResult) ClbitCounts() map[string]int {
+	return tr.clbitCounts
+}
+
+// Executes the circuit shots times on the state vector, each run sampling one Kraus
+// operator per noise channel and ending with a measurement of every qubit.
+// This unravels the noise without the squared memory of WithDensityMatrix.
+// Trajectories run concurrently, and trajectory i draws from its own source seeded
+// with seed+i, so results are reproducible for a given seed however they are scheduled.
+// WithDensityMatrix and WithRand are ignored.
+// Unlike Exec, there is no stabilizer tableau path: circuits of more than 30 qubits panic,
+// even Clifford ones, which can be run shot by shot with ExecStabilizer instead.
+func (qc *QuantumCircuit) ExecTrajectories(qubitStates []Ket, shots int, seed int64, opts ...ExecOption) *TrajectoryResult {
+	if len(qubitStates) != qc.numQubits {
+		panic(fmt.Sprintf("Cannot execute qubitStates of size %v on circuit with %v qubits", len(qubitStates), qc.numQubits))
+	}
+
+	cfg := execConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.density = false
+	if qc.numQubits > cfg.maxQubits() {
+		panic(fmt.Sprintf("Cannot execute trajectories of %v qubits on a state vector, the limit is %v; "+
+			"ExecTrajectories has no stabilizer tableau path, so run Clifford circuits with ExecStabilizer",
+			qc.numQubits, cfg.maxQubits()))
+	}
+	input := KronKets(qubitStates)
+
+	result := &TrajectoryResult{
+		shots:       shots,
+		counts:      map[string]int{},
+		clbitCounts: map[string]int{},
+	}
+	var mu sync.Mutex
+
+	next := make(chan int)
+	go func() {
+		for i := 0; i < shots; i++ {
+			next <- i
+		}
+		close(next)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Tally locally so workers only contend once at the end
+			counts := map[string]int{}
+			clbitCounts := map[string]int{}
+			for i := range next {
+				trajectory := cfg
+				trajectory.rng = rand.New(rand.NewSource(seed + int64(i)))
+				qce := qc.exec(qubitStates, input, &trajectory)
+
+				for outcome := range qce.Sample(1, trajectory.rng) {
+					counts[outcome]++
+				}
+				clbitCounts[clbitString(qce.clbits)]++
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for outcome, n := range counts {
+				result.counts[outcome] += n
+			}
+			for outcome, n := range clbitCounts {
+				result.clbitCounts[outcome] += n
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+// Formats the classical register as a bitstring with classical bit 0 first
+func clbitString(clbits []int) string {
+	b := make([]byte, len(clbits))
+	for i, c := range clbits {
+		b[i] = byte('0' + c)
+	}
+	return string(b)
+}
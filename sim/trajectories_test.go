@@ -0,0 +1,89 @@
+package sim
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestQuantumCircuit_ExecTrajectories(t *testing.T) {
+	t.Run("Matches the density matrix", func(t *testing.T) {
+		qc := NewQuantumCircuit(2)
+		qc.H([]int{0})
+		qc.CX(0, 1)
+		qc.AmplitudeDamping(0.4, 1)
+		in := []Ket{ZeroKet, ZeroKet}
+
+		shots := 4000
+		counts := qc.ExecTrajectories(in, shots, 7).Counts()
+		want := qc.Exec(in, WithDensityMatrix()).MeasureProbabilities()
+		for i, p := range want {
+			if got := float64(counts[bitString(i, 2)]) / float64(shots); math.Abs(got-p) > 0.03 {
+				t.Errorf("Counts() = %v, want frequencies %v", counts, want)
+				break
+			}
+		}
+	})
+
+	t.Run("Reproducible for a seed", func(t *testing.T) {
+		nm := NewNoiseModel()
+		if err := nm.AddGateError(HADAMARD, DepolarizingChannel(0.3)); err != nil {
+			t.Fatalf("AddGateError() error = %v", err)
+		}
+		if err := nm.AddReadoutError(2, ReadoutError(0.1, 0.1)); err != nil {
+			t.Fatalf("AddReadoutError() error = %v", err)
+		}
+		qc := NewQuantumCircuit(3)
+		qc.H([]int{0, 1, 2})
+		qc.CX(1, 2)
+		qc.Measure(0, 0)
+		in := []Ket{ZeroKet, ZeroKet, ZeroKet}
+
+		a := qc.ExecTrajectories(in, 500, 11, WithNoise(nm))
+		b := qc.ExecTrajectories(in, 500, 11, WithNoise(nm))
+		if len(a.Counts()) != len(b.Counts()) {
+			t.Fatalf("Counts() = %v and %v for the same seed", a.Counts(), b.Counts())
+		}
+		for outcome, n := range a.Counts() {
+			if b.Counts()[outcome] != n {
+				t.Fatalf("Counts() = %v and %v for the same seed", a.Counts(), b.Counts())
+			}
+		}
+		if a.ClbitCounts()["0"] != b.ClbitCounts()["0"] || a.ClbitCounts()["0"]+a.ClbitCounts()["1"] != 500 {
+			t.Errorf("ClbitCounts() = %v and %v, want equal and totalling 500", a.ClbitCounts(), b.ClbitCounts())
+		}
+	})
+
+	t.Run("Large noisy register", func(t *testing.T) {
+		numQubits := 16
+		qc := NewQuantumCircuit(numQubits)
+		in := make([]Ket, numQubits)
+		for q := 0; q < numQubits; q++ {
+			in[q] = ZeroKet
+			qc.X(q)
+			qc.BitFlip(1, q)
+		}
+
+		result := qc.ExecTrajectories(in, 20, 1)
+		if got := result.Counts()[bitString(0, numQubits)]; got != 20 || result.Shots() != 20 {
+			t.Errorf("Counts() = %v, want all zeros in every shot", result.Counts())
+		}
+	})
+
+	t.Run("No tableau path for Clifford circuits", func(t *testing.T) {
+		numQubits := maxStatevectorQubits + 1
+		qc := NewQuantumCircuit(numQubits)
+		qc.H([]int{0})
+		in := make([]Ket, numQubits)
+		for q := range in {
+			in[q] = ZeroKet
+		}
+		defer func() {
+			r := recover()
+			if msg, ok := r.(string); !ok || !strings.Contains(msg, "no stabilizer tableau path") {
+				t.Errorf("ExecTrajectories() panics with %v, want the missing tableau path", r)
+			}
+		}()
+		qc.ExecTrajectories(in, 1, 1)
+	})
+}